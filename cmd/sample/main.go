@@ -10,13 +10,21 @@ import (
 	"github.com/mojun2021/micro-server/pkg/metrics"
 
 	ctxhelp "github.com/mojun2021/micro-server/pkg/helpers/context"
+	"github.com/mojun2021/micro-server/pkg/helpers/routes"
 	"github.com/mojun2021/micro-server/pkg/server"
 )
 
-var appLog = logger.NewLogger(os.Stdout, "sample")
+var (
+	recentLogs = logger.NewRecentLogs(1000)
+	appLog     = logger.NewLoggerWithOptions(os.Stdout, "sample", logger.Options{
+		Redaction:  logger.DefaultRedactionPolicy(),
+		RecentLogs: recentLogs,
+	})
+)
 
 func main() {
 	logger.SetLogger(appLog)
+	defer func() { _ = logger.Flush() }()
 
 	p, err := metrics.NewPrometheusExporter()
 
@@ -32,6 +40,7 @@ func main() {
 		appLog.Error(err, "Failed")
 	}
 
+	routes.AddRecentLogs(s.Router(), recentLogs)
 	//	routes.AddDebugPanel(s.Router())
 
 	ctx, cancel := ctxhelp.WithCancelOnTermination(context.Background())
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+
+	logs "github.com/mojun2021/micro-server/pkg/logger/advanced"
+)
+
+// AddRecentLogs adds the recent logs routes to a given router:
+//
+// - ``/debug/logs`` returns the logs kept in memory as a JSON array
+//
+// - ``/debug/logs/tail`` streams the new logs as Server-Sent Events
+//
+// Both routes accept the ``level``, ``logger`` and ``q`` query parameters, i.e.
+// ``/debug/logs?level=warning&logger=sample.server&q=timeout``.
+//
+// ```eval_rst
+//
+// .. note::
+//    The logger must be created with the same ``RecentLogs``.
+//
+//    Example:
+//
+//    .. code-block:: go
+//
+//       recentLogs := logger.NewRecentLogs(1000)
+//       appLog := logger.NewLoggerWithOptions(os.Stdout, "app", logger.Options{RecentLogs: recentLogs})
+//
+//       AddRecentLogs(monitoringServer.Router(), recentLogs)
+// ```
+func AddRecentLogs(router *mux.Router, recentLogs *logs.RecentLogs) {
+	router.Path("/debug/logs").Methods("GET").Handler(recentLogs)
+	router.Path("/debug/logs/tail").Methods("GET").Handler(recentLogs.TailHandler())
+}
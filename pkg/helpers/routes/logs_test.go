@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	logs "github.com/mojun2021/micro-server/pkg/logger/advanced"
+	"github.com/mojun2021/micro-server/pkg/metrics"
+	"github.com/mojun2021/micro-server/pkg/server/middlewares"
+)
+
+func TestRecentLogsTail(t *testing.T) {
+	recentLogs := logs.NewRecentLogs(10)
+	log := logs.NewProductionLoggerWithOptions(nil, logs.Options{RecentLogs: recentLogs})
+
+	router := mux.NewRouter()
+	AddRecentLogs(router, recentLogs)
+
+	// the routes are wrapped as by the server, see ``server.Server.Run``
+	requestMetrics, err := middlewares.NewRequestMetrics(middlewares.RequestMetricsOptions{
+		Registerer: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("NewRequestMetrics() error = %v", err)
+	}
+	tracker, err := metrics.NewSLOTracker([]metrics.SLO{{Route: "/debug/logs/tail", Objective: 0.99}},
+		metrics.SLOTrackerOptions{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("NewSLOTracker() error = %v", err)
+	}
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, _ := route.GetPathTemplate()
+		handler := requestMetrics.Handler(route.GetHandler(), tpl)
+		handler = middlewares.SLOHandler(handler, tpl, tracker)
+		handler = middlewares.RequestDebugHandler(handler, middlewares.RequestDebugOptions{Tokens: []string{"secret"}})
+		route.Handler(handler)
+		return nil
+	})
+
+	// the stream outlives the server write timeout
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	for _, token := range []string{"", "secret"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/debug/logs/tail?q=Tail", nil)
+		if token != "" {
+			req.Header.Set(middlewares.DefaultDebugHeader, token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /debug/logs/tail error = %v", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("GET /debug/logs/tail = %d %q, want a 200 event stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+
+		time.Sleep(3 * server.Config.WriteTimeout)
+		log.Info("Other log")
+		log.Info("Tail log")
+
+		lines := make(chan string)
+		go func() {
+			defer close(lines)
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+		}()
+
+		var event []string
+	read:
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatalf("the stream ended, got %q", event)
+				}
+				if line == "" && len(event) > 0 {
+					break read
+				}
+				event = append(event, line)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("no event received, got %q", event)
+			}
+		}
+
+		if len(event) != 2 || event[0] != "event: log" || !strings.Contains(event[1], `"msg":"Tail log"`) {
+			t.Errorf("the stream got %q, want the tail log event", event)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+
+	"github.com/mojun2021/micro-server/pkg/metrics"
+)
+
+// AddSLO adds the SLO route to a given router:
+//
+// - ``/debug/slo`` returns the SLO reports as a JSON array: the error budget remaining over the period, the burn
+// rates by window and the burn rate alerts
+//
+// ```eval_rst
+//
+// .. note::
+//    The server must count its requests with the same ``SLOTracker``.
+//
+//    Example:
+//
+//    .. code-block:: go
+//
+//       tracker, err := metrics.NewSLOTracker([]metrics.SLO{
+//           {Route: "/api/orders", Objective: 0.999, Latency: 300 * time.Millisecond},
+//       }, metrics.SLOTrackerOptions{Registerer: exporter.Registry()})
+//       apiServer, err := server.NewBaseServer(":8080", server.Options{SLOTracker: tracker})
+//
+//       AddSLO(monitoringServer.Router(), tracker)
+// ```
+func AddSLO(router *mux.Router, tracker *metrics.SLOTracker) {
+	router.Path("/debug/slo").Methods("GET").Handler(tracker)
+}
@@ -0,0 +1,272 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.uber.org/zap/zapcore"
+)
+
+// OverflowPolicy defines what an asynchronous sink does with a log when its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the caller until the buffer has room for the log. The sink writer must not log through
+	// the sink: the background routine writing the logs would wait for itself. The rotation events of a
+	// ``FileWriter`` are logged from another routine.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the log.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest buffered log to make room for the log.
+	OverflowDropOldest
+)
+
+const (
+	// AsyncDefaultBufferSize defines the default number of logs an asynchronous sink buffers.
+	AsyncDefaultBufferSize = 4096
+	// AsyncDefaultFlushInterval defines the default interval between two flushes of an asynchronous sink.
+	AsyncDefaultFlushInterval = time.Second
+
+	asyncWriteBufferSize = 256 * 1024
+)
+
+// AsyncOptions defines how a sink writes its logs asynchronously.
+//
+// The logs are encoded by the caller and queued in a bounded buffer, a background routine writes them to the sink
+// writer. The servers flush the buffered logs when they shut down, see ``server.Server.Shutdown``. Otherwise call
+// ``Flush`` on application shutdown to guarantee that the buffered logs are written, or ``Close`` to also stop the
+// background routines, i.e. in the tests creating loggers repeatedly.
+//
+// The number of queued logs is measured by ``LogAsyncQueueDepthView`` on every flush interval, and as soon as the
+// buffer is full.
+type AsyncOptions struct {
+	// BufferSize defines the number of logs the sink buffers. It defaults to ``AsyncDefaultBufferSize``.
+	BufferSize int
+	// FlushInterval defines the maximum time a log stays in the sink write buffer. It defaults to
+	// ``AsyncDefaultFlushInterval``.
+	FlushInterval time.Duration
+	// Overflow defines what the sink does with a log when its buffer is full. It defaults to ``OverflowBlock``.
+	Overflow OverflowPolicy
+}
+
+func setAsyncOptionsDefaults(options *AsyncOptions) {
+	if options.BufferSize <= 0 {
+		options.BufferSize = AsyncDefaultBufferSize
+	}
+
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = AsyncDefaultFlushInterval
+	}
+}
+
+var (
+	asyncWritersMu sync.Mutex
+	asyncWriters   []*asyncWriter
+)
+
+// Flush writes the logs buffered by all the asynchronous sinks and waits for them to be synced. Call it on
+// application shutdown.
+func Flush() error {
+	asyncWritersMu.Lock()
+	writers := append([]*asyncWriter(nil), asyncWriters...)
+	asyncWritersMu.Unlock()
+
+	var err error
+	for _, w := range writers {
+		if syncErr := w.Sync(); err == nil {
+			err = syncErr
+		}
+	}
+	return err
+}
+
+// Close writes the logs buffered by all the asynchronous sinks and the last summaries of the sampled logs, and stops
+// their background routines. The sinks keep working once closed: they write their logs synchronously, and summarize
+// the sampled logs when synced.
+func Close() error {
+	// The last summaries are written before the asynchronous sinks are closed.
+	stopSamplingSummaries()
+
+	asyncWritersMu.Lock()
+	writers := append([]*asyncWriter(nil), asyncWriters...)
+	asyncWritersMu.Unlock()
+
+	var err error
+	for _, w := range writers {
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// asyncWriter is a zapcore.WriteSyncer queuing the logs in a bounded buffer written by a background routine.
+type asyncWriter struct {
+	ws      zapcore.WriteSyncer
+	options AsyncOptions
+	queue   chan []byte
+	syncs   chan chan error
+	stop    chan chan error
+
+	// mu guards closed: the logs are queued with the read lock held, so that none is queued once closed.
+	mu     sync.RWMutex
+	closed bool
+	// wsMu serializes the synchronous writes once closed.
+	wsMu sync.Mutex
+}
+
+func newAsyncWriter(ws zapcore.WriteSyncer, options AsyncOptions) *asyncWriter {
+	setAsyncOptionsDefaults(&options)
+
+	w := &asyncWriter{
+		ws:      ws,
+		options: options,
+		queue:   make(chan []byte, options.BufferSize),
+		syncs:   make(chan chan error),
+		stop:    make(chan chan error),
+	}
+
+	asyncWritersMu.Lock()
+	asyncWriters = append(asyncWriters, w)
+	asyncWritersMu.Unlock()
+
+	go w.run()
+
+	return w
+}
+
+// Write queues a copy of the log, applying the overflow policy when the buffer is full. The log is written
+// synchronously once the writer is closed.
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		w.wsMu.Lock()
+		defer w.wsMu.Unlock()
+		return w.ws.Write(p)
+	}
+
+	log := append([]byte(nil), p...)
+
+	if len(w.queue) == cap(w.queue) {
+		w.recordQueueDepth()
+	}
+
+	switch w.options.Overflow {
+	case OverflowDropNewest:
+		select {
+		case w.queue <- log:
+		default:
+			w.dropped()
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case w.queue <- log:
+				return len(p), nil
+			default:
+			}
+
+			select {
+			case <-w.queue:
+				w.dropped()
+			default:
+			}
+		}
+	default:
+		w.queue <- log
+	}
+
+	return len(p), nil
+}
+
+// Sync waits for the buffered logs to be written and syncs the sink writer.
+func (w *asyncWriter) Sync() error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		w.wsMu.Lock()
+		defer w.wsMu.Unlock()
+		return w.ws.Sync()
+	}
+
+	done := make(chan error, 1)
+	w.syncs <- done
+	return <-done
+}
+
+// Close writes the buffered logs, stops the background routine and removes the writer from the writers flushed by
+// ``Flush``. It can be called several times.
+func (w *asyncWriter) Close() error {
+	// The lock waits for the logs being queued, the background routine still writing them.
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	done := make(chan error, 1)
+	w.stop <- done
+	err := <-done
+
+	asyncWritersMu.Lock()
+	for i, writer := range asyncWriters {
+		if writer == w {
+			asyncWriters = append(asyncWriters[:i], asyncWriters[i+1:]...)
+			break
+		}
+	}
+	asyncWritersMu.Unlock()
+
+	return err
+}
+
+func (w *asyncWriter) dropped() {
+	stats.Record(context.Background(), mAsyncDroppedLogs.M(1))
+}
+
+func (w *asyncWriter) recordQueueDepth() {
+	stats.Record(context.Background(), mAsyncQueueDepth.M(int64(len(w.queue))))
+}
+
+func (w *asyncWriter) run() {
+	buf := bufio.NewWriterSize(w.ws, asyncWriteBufferSize)
+
+	ticker := time.NewTicker(w.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case log := <-w.queue:
+			_, _ = buf.Write(log)
+		case <-ticker.C:
+			_ = buf.Flush()
+			w.recordQueueDepth()
+		case done := <-w.syncs:
+			done <- w.drain(buf)
+		case done := <-w.stop:
+			done <- w.drain(buf)
+			return
+		}
+	}
+}
+
+// drain writes the logs queued so far, flushes the write buffer and syncs the sink writer.
+func (w *asyncWriter) drain(buf *bufio.Writer) error {
+	for n := len(w.queue); n > 0; n-- {
+		_, _ = buf.Write(<-w.queue)
+	}
+
+	err := buf.Flush()
+	if syncErr := w.ws.Sync(); err == nil {
+		err = syncErr
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"go.uber.org/zap/zapcore"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// gateWriter is a writer blocking the writes until it is opened.
+type gateWriter struct {
+	w       io.Writer
+	writing chan struct{}
+	open    chan struct{}
+}
+
+func newGateWriter(w io.Writer) *gateWriter {
+	return &gateWriter{w: w, writing: make(chan struct{}, 1), open: make(chan struct{})}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	select {
+	case w.writing <- struct{}{}:
+	default:
+	}
+	<-w.open
+
+	return w.w.Write(p)
+}
+
+func (w *gateWriter) Sync() error { return nil }
+
+func TestAsyncWriterFlush(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewProductionLoggerWithOptions(&buf, Options{Async: &AsyncOptions{FlushInterval: time.Hour}})
+
+	for i := 0; i < 100; i++ {
+		log.Info("Request", "i", i)
+	}
+
+	if err := Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	logs := decodeLogs(t, &buf)
+	if len(logs) != 100 {
+		t.Fatalf("got %d logs, want 100", len(logs))
+	}
+	for i, l := range logs {
+		if l["i"] != float64(i) {
+			t.Fatalf("log %d is %v, want the logs in order", i, l)
+		}
+	}
+}
+
+func TestAsyncWriterOverflow(t *testing.T) {
+	if err := view.Register(LogAsyncQueueDepthView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(LogAsyncQueueDepthView)
+
+	// the logs larger than the write buffer are written at once
+	big := strings.Repeat("x", asyncWriteBufferSize+1) + "\n"
+
+	for _, tt := range []struct {
+		overflow OverflowPolicy
+		want     string
+	}{
+		{OverflowDropNewest, big + "a\nb\n"},
+		{OverflowDropOldest, big + "b\nc\n"},
+	} {
+		t.Run(fmt.Sprint(tt.overflow), func(t *testing.T) {
+			var buf bytes.Buffer
+			ws := newGateWriter(&buf)
+			w := newAsyncWriter(ws, AsyncOptions{BufferSize: 2, FlushInterval: time.Hour, Overflow: tt.overflow})
+			defer w.Close()
+
+			// the background routine is writing the first log when the others are queued
+			_, _ = w.Write([]byte(big))
+			<-ws.writing
+			for _, log := range []string{"a\n", "b\n", "c\n"} {
+				_, _ = w.Write([]byte(log))
+			}
+
+			// the queue depth is measured once the buffer is full
+			rows, err := view.RetrieveData(LogAsyncQueueDepthView.Name)
+			if err != nil || len(rows) != 1 || rows[0].Data.(*view.LastValueData).Value != 2 {
+				t.Errorf("the queue depth is %v (%v), want 2", rows, err)
+			}
+
+			close(ws.open)
+			if err := w.Sync(); err != nil {
+				t.Fatalf("Sync() error = %v", err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("the sink got %q, want %q", strings.TrimPrefix(got, big), strings.TrimPrefix(tt.want, big))
+			}
+		})
+	}
+}
+
+func TestAsyncWriterClose(t *testing.T) {
+	var buf bytes.Buffer
+	w := newAsyncWriter(zapcore.AddSync(&buf), AsyncOptions{FlushInterval: time.Hour})
+
+	_, _ = w.Write([]byte("queued\n"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// the logs are written synchronously once closed
+	_, _ = w.Write([]byte("synchronous\n"))
+	if got := buf.String(); got != "queued\nsynchronous\n" {
+		t.Errorf("the sink got %q, want the queued then the synchronous logs", got)
+	}
+}
+
+func TestAsyncWriterFileRotation(t *testing.T) {
+	fw, dir := newTestFileWriter(t, FileOptions{MaxSize: asyncWriteBufferSize})
+	_, _ = fw.Write([]byte("first\n"))
+
+	// the rotation event of the file is logged to the file itself, through the full buffer of the sink
+	ws := newGateWriter(fw)
+	log := NewProductionLoggerWithOptions(nil, Options{
+		Sinks: []Sink{{
+			Writer: ws,
+			Level:  zapcore.InfoLevel,
+			Async:  &AsyncOptions{BufferSize: 1, FlushInterval: time.Hour, Overflow: OverflowBlock},
+		}},
+	})
+	logf.SetLogger(log)
+	t.Cleanup(func() { _ = Close() })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// the logs larger than the write buffer are written at once, the file rotates while the buffer is full
+		log.Info("Request", "payload", strings.Repeat("x", asyncWriteBufferSize))
+		<-ws.writing
+		log.Info("Request")
+		close(ws.open)
+		_ = Flush()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the logger is blocked")
+	}
+
+	if len(backups(t, dir)) == 0 {
+		t.Error("the log file is not rotated")
+	}
+}
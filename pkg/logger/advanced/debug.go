@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DebugRequestKey defines the key marking the logs of the loggers returned by ``WithDebug``.
+const DebugRequestKey = "debug_request"
+
+// debugMarker is the value of the ``DebugRequestKey`` field, it is logged as ``true``.
+type debugMarker struct{}
+
+func (debugMarker) String() string { return "true" }
+
+// WithDebug returns a logger writing its debug logs whatever the level of the sinks, without sampling. Its logs are
+// marked with ``DebugRequestKey``. Use it to log the diagnostics of a single request without changing the logger
+// level.
+//
+// The logger must be created by this package, other loggers only add the marker to their logs.
+func WithDebug(log logr.Logger) logr.Logger {
+	return log.WithValues(DebugRequestKey, debugMarker{})
+}
+
+// isDebugField returns true when the specified field is the marker added by ``WithDebug``.
+func isDebugField(f zapcore.Field) bool {
+	_, ok := f.Interface.(debugMarker)
+	return ok && f.Key == DebugRequestKey
+}
+
+// debugCore is a zapcore.Core forcing the debug logs of the loggers returned by ``WithDebug``.
+type debugCore struct {
+	zapcore.Core
+	forced bool
+}
+
+func newDebugCore(core zapcore.Core) zapcore.Core {
+	return &debugCore{Core: core}
+}
+
+func (c *debugCore) Enabled(level zapcore.Level) bool {
+	return (c.forced && level >= zapcore.DebugLevel) || c.Core.Enabled(level)
+}
+
+func (c *debugCore) With(fields []zapcore.Field) zapcore.Core {
+	marked := false
+	for i, f := range fields {
+		if isDebugField(f) {
+			if !marked {
+				fields = append([]zapcore.Field(nil), fields...)
+				marked = true
+			}
+			fields[i] = zap.Bool(DebugRequestKey, true)
+		}
+	}
+
+	return &debugCore{Core: c.Core.With(fields), forced: c.forced || marked}
+}
+
+func (c *debugCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.forced && ent.Level >= zapcore.DebugLevel {
+		return ce.AddCore(ent, c)
+	}
+	return c.Core.Check(ent, ce)
+}
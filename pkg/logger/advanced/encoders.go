@@ -0,0 +1,406 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// LogfmtEncoding writes ``key=value`` pairs, see https://brandur.org/logfmt.
+	LogfmtEncoding Encoding = "logfmt"
+	// ECSEncoding writes JSON following the Elastic Common Schema, see
+	// https://www.elastic.co/guide/en/ecs/current/index.html.
+	ECSEncoding Encoding = "ecs"
+	// GoogleCloudEncoding writes JSON following the Google Cloud Logging structured payload, see
+	// https://cloud.google.com/logging/docs/structured-logging.
+	GoogleCloudEncoding Encoding = "gcp"
+	// OpenTelemetryEncoding writes JSON following the OpenTelemetry log data model, see
+	// https://opentelemetry.io/docs/reference/specification/logs/data-model/.
+	OpenTelemetryEncoding Encoding = "otel"
+
+	// EncodingEnvironmentVariable defines the name of the environment variable to set in order to define the
+	// encoding of the logs. The available encodings are ``json``, ``console``, ``logfmt``, ``ecs``, ``gcp`` and
+	// ``otel``.
+	EncodingEnvironmentVariable = "USGO_LOG_ENCODING"
+	// GoogleCloudProjectEnvironmentVariable defines the name of the environment variable holding the Google Cloud
+	// project. It is used to build the trace resource name of the ``gcp`` encoding.
+	GoogleCloudProjectEnvironmentVariable = "GOOGLE_CLOUD_PROJECT"
+
+	// ecsVersion is the version of the Elastic Common Schema the ``ecs`` encoding follows.
+	ecsVersion = "1.6.0"
+	// errorKey is the key of the error logged by ``logr.Logger.Error``.
+	errorKey = "error"
+)
+
+// needsCaller returns true when the default sink, when written, or one of the sinks of the options uses an encoding
+// writing the caller location: the loggers then add the caller to their logs.
+func needsCaller(defaultSink Sink, options Options) bool {
+	encodings := make([]Encoding, 0, len(options.Sinks)+1)
+	if defaultSink.Writer != nil {
+		encodings = append(encodings, defaultSink.Encoding)
+	}
+	for _, sink := range options.Sinks {
+		if sink.Encoding == "" {
+			sink.Encoding = defaultSink.Encoding
+		}
+		encodings = append(encodings, sink.Encoding)
+	}
+
+	for _, encoding := range encodings {
+		if encoding == ECSEncoding || encoding == GoogleCloudEncoding {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaEncoder is a JSON zapcore.Encoder mapping the micro-server fields to the fields of a given schema.
+type schemaEncoder struct {
+	zapcore.Encoder
+	schema *encoderSchema
+}
+
+// encoderSchema defines how the fields are mapped by a schemaEncoder.
+type encoderSchema struct {
+	// keys maps the micro-server keys to the schema keys.
+	keys map[string]string
+	// values transforms the values of the mapped keys.
+	values func(key, value string) string
+	// entryFields returns the entry fields the schema defines, such as the caller location.
+	entryFields func(ent zapcore.Entry) []zapcore.Field
+}
+
+func (s *encoderSchema) key(key string) string {
+	if mapped, ok := s.keys[key]; ok {
+		return mapped
+	}
+	return key
+}
+
+func (s *encoderSchema) field(f zapcore.Field) zapcore.Field {
+	mapped, ok := s.keys[f.Key]
+	if !ok {
+		return f
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		if s.values != nil {
+			f.String = s.values(f.Key, f.String)
+		}
+	case zapcore.ErrorType:
+		// The schema defines its own key for the error message, the verbose error is dropped.
+		if err, _ := f.Interface.(error); err != nil {
+			return zap.String(mapped, safeString(err))
+		}
+	}
+
+	f.Key = mapped
+	return f
+}
+
+func (e *schemaEncoder) AddString(key, value string) {
+	if e.schema.values != nil {
+		if _, ok := e.schema.keys[key]; ok {
+			value = e.schema.values(key, value)
+		}
+	}
+	e.Encoder.AddString(e.schema.key(key), value)
+}
+
+func (e *schemaEncoder) Clone() zapcore.Encoder {
+	return &schemaEncoder{Encoder: e.Encoder.Clone(), schema: e.schema}
+}
+
+func (e *schemaEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	var mapped []zapcore.Field
+	if e.schema.entryFields != nil {
+		mapped = e.schema.entryFields(ent)
+	}
+
+	for _, f := range fields {
+		mapped = append(mapped, e.schema.field(f))
+	}
+
+	return e.Encoder.EncodeEntry(ent, mapped)
+}
+
+// newECSEncoder creates an encoder following the Elastic Common Schema.
+func newECSEncoder() zapcore.Encoder {
+	encCfg := zapcore.EncoderConfig{
+		TimeKey:        "@timestamp",
+		LevelKey:       "log.level",
+		NameKey:        "log.logger",
+		MessageKey:     "message",
+		StacktraceKey:  "error.stack_trace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.NanosDurationEncoder,
+	}
+
+	if DisableLogTime {
+		encCfg.TimeKey = ""
+	}
+
+	enc := &schemaEncoder{
+		Encoder: zapcore.NewJSONEncoder(encCfg),
+		schema: &encoderSchema{
+			keys: map[string]string{
+				TraceIDKey: "trace.id",
+				SpanIDKey:  "span.id",
+				errorKey:   "error.message",
+			},
+			entryFields: func(ent zapcore.Entry) []zapcore.Field {
+				if !ent.Caller.Defined {
+					return nil
+				}
+
+				return []zapcore.Field{
+					zap.String("log.origin.file.name", ent.Caller.File),
+					zap.Int("log.origin.file.line", ent.Caller.Line),
+					zap.String("log.origin.function", ent.Caller.Function),
+				}
+			},
+		},
+	}
+	enc.Encoder.AddString("ecs.version", ecsVersion)
+
+	return enc
+}
+
+// googleCloudSeverity encodes a level as a Google Cloud Logging severity.
+func googleCloudSeverity(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch {
+	case level >= zapcore.FatalLevel:
+		enc.AppendString("EMERGENCY")
+	case level >= zapcore.PanicLevel:
+		enc.AppendString("ALERT")
+	case level >= zapcore.DPanicLevel:
+		enc.AppendString("CRITICAL")
+	case level >= zapcore.ErrorLevel:
+		enc.AppendString("ERROR")
+	case level >= zapcore.WarnLevel:
+		enc.AppendString("WARNING")
+	case level >= zapcore.InfoLevel:
+		enc.AppendString("INFO")
+	default:
+		enc.AppendString("DEBUG")
+	}
+}
+
+// sourceLocation is the Google Cloud Logging source location of an entry.
+type sourceLocation zapcore.EntryCaller
+
+func (l sourceLocation) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("file", l.File)
+	enc.AddString("line", strconv.Itoa(l.Line))
+	enc.AddString("function", l.Function)
+	return nil
+}
+
+// newGoogleCloudEncoder creates an encoder following the Google Cloud Logging structured payload.
+func newGoogleCloudEncoder() zapcore.Encoder {
+	encCfg := zapcore.EncoderConfig{
+		TimeKey:        "time",
+		LevelKey:       "severity",
+		NameKey:        "logger",
+		MessageKey:     "message",
+		StacktraceKey:  "stack_trace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    googleCloudSeverity,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	}
+
+	if DisableLogTime {
+		encCfg.TimeKey = ""
+	}
+
+	project := os.Getenv(GoogleCloudProjectEnvironmentVariable)
+
+	return &schemaEncoder{
+		Encoder: zapcore.NewJSONEncoder(encCfg),
+		schema: &encoderSchema{
+			keys: map[string]string{
+				TraceIDKey: "logging.googleapis.com/trace",
+				SpanIDKey:  "logging.googleapis.com/spanId",
+			},
+			values: func(key, value string) string {
+				// The trace is identified by its resource name.
+				if key == TraceIDKey && project != "" {
+					return "projects/" + project + "/traces/" + value
+				}
+				return value
+			},
+			entryFields: func(ent zapcore.Entry) []zapcore.Field {
+				if !ent.Caller.Defined {
+					return nil
+				}
+				return []zapcore.Field{zap.Object("logging.googleapis.com/sourceLocation", sourceLocation(ent.Caller))}
+			},
+		},
+	}
+}
+
+// openTelemetrySeverityNumber returns the OpenTelemetry severity number of a level. The logr verbosity levels
+// beyond debug are mapped to the trace severities.
+func openTelemetrySeverityNumber(level zapcore.Level) int {
+	switch {
+	case level >= zapcore.FatalLevel:
+		return 21
+	case level >= zapcore.DPanicLevel:
+		return 21 - int(zapcore.FatalLevel-level)
+	case level >= zapcore.ErrorLevel:
+		return 17
+	case level >= zapcore.WarnLevel:
+		return 13
+	case level >= zapcore.InfoLevel:
+		return 9
+	case level == zapcore.DebugLevel:
+		return 5
+	case level >= zapcore.DebugLevel-4:
+		return 4 - int(zapcore.DebugLevel-level-1)
+	default:
+		return 1
+	}
+}
+
+// openTelemetrySeverityText encodes a level as an OpenTelemetry severity text.
+func openTelemetrySeverityText(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch n := openTelemetrySeverityNumber(level); {
+	case n >= 21:
+		enc.AppendString("FATAL")
+	case n >= 17:
+		enc.AppendString("ERROR")
+	case n >= 13:
+		enc.AppendString("WARN")
+	case n >= 9:
+		enc.AppendString("INFO")
+	case n >= 5:
+		enc.AppendString("DEBUG")
+	default:
+		enc.AppendString("TRACE")
+	}
+}
+
+// openTelemetryEncoder is a zapcore.Encoder following the OpenTelemetry log data model. The fields are written as
+// the record attributes, except the trace context which is written at the record level.
+type openTelemetryEncoder struct {
+	// Encoder accumulates the attributes added by the logger context.
+	zapcore.Encoder
+	record  zapcore.Encoder
+	traceID string
+	spanID  string
+}
+
+func newOpenTelemetryEncoder() zapcore.Encoder {
+	recordCfg := zapcore.EncoderConfig{
+		TimeKey:        "Timestamp",
+		LevelKey:       "SeverityText",
+		MessageKey:     "Body",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    openTelemetrySeverityText,
+		EncodeTime:     zapcore.EpochNanosTimeEncoder,
+		EncodeDuration: zapcore.NanosDurationEncoder,
+	}
+
+	if DisableLogTime {
+		recordCfg.TimeKey = ""
+	}
+
+	// The attributes encoder only writes the fields and the stack trace.
+	attributesCfg := zapcore.EncoderConfig{
+		StacktraceKey:  "exception.stacktrace",
+		EncodeDuration: zapcore.NanosDurationEncoder,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+	}
+
+	return &openTelemetryEncoder{
+		Encoder: &schemaEncoder{
+			Encoder: zapcore.NewJSONEncoder(attributesCfg),
+			schema:  &encoderSchema{keys: map[string]string{errorKey: "exception.message"}},
+		},
+		record: zapcore.NewJSONEncoder(recordCfg),
+	}
+}
+
+func (e *openTelemetryEncoder) AddString(key, value string) {
+	switch key {
+	case TraceIDKey:
+		e.traceID = value
+	case SpanIDKey:
+		e.spanID = value
+	default:
+		e.Encoder.AddString(key, value)
+	}
+}
+
+func (e *openTelemetryEncoder) Clone() zapcore.Encoder {
+	return &openTelemetryEncoder{
+		Encoder: e.Encoder.Clone(),
+		record:  e.record,
+		traceID: e.traceID,
+		spanID:  e.spanID,
+	}
+}
+
+func (e *openTelemetryEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	traceID, spanID := e.traceID, e.spanID
+
+	attributeFields := make([]zapcore.Field, 0, len(fields))
+	for _, f := range fields {
+		switch {
+		case f.Key == TraceIDKey && f.Type == zapcore.StringType:
+			traceID = f.String
+		case f.Key == SpanIDKey && f.Type == zapcore.StringType:
+			spanID = f.String
+		default:
+			attributeFields = append(attributeFields, f)
+		}
+	}
+
+	attributes, err := e.Encoder.EncodeEntry(zapcore.Entry{Stack: ent.Stack}, attributeFields)
+	if err != nil {
+		return nil, err
+	}
+	defer attributes.Free()
+
+	recordFields := []zapcore.Field{
+		zap.Int("SeverityNumber", openTelemetrySeverityNumber(ent.Level)),
+	}
+
+	if traceID != "" {
+		recordFields = append(recordFields, zap.String("TraceId", traceID))
+	}
+
+	if spanID != "" {
+		recordFields = append(recordFields, zap.String("SpanId", spanID))
+	}
+
+	if ent.LoggerName != "" {
+		recordFields = append(recordFields, zap.Object("InstrumentationScope", instrumentationScope(ent.LoggerName)))
+	}
+
+	recordFields = append(recordFields, zap.Reflect(
+		"Attributes",
+		json.RawMessage(strings.TrimSuffix(attributes.String(), zapcore.DefaultLineEnding)),
+	))
+
+	ent.Stack = ""
+	return e.record.EncodeEntry(ent, recordFields)
+}
+
+// instrumentationScope is the OpenTelemetry instrumentation scope of an entry, its logger name.
+type instrumentationScope string
+
+func (s instrumentationScope) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("Name", string(s))
+	return nil
+}
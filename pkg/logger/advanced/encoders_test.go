@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestEncodersGolden(t *testing.T) {
+	t.Setenv(GoogleCloudProjectEnvironmentVariable, "my-project")
+
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Unix(1625482753, 0).UTC(),
+		LoggerName: "app.users",
+		Message:    "Request failed",
+		Caller:     zapcore.EntryCaller{Defined: true, File: "/src/app/users.go", Line: 42, Function: "app.(*Users).Get"},
+	}
+
+	for _, tt := range []struct {
+		encoding Encoding
+		want     string
+	}{
+		{JSONEncoding, `{"level":"warn","ts":1625482753,"logger":"app.users","caller":"app/users.go:42","msg":"Request failed",` +
+			`"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","error":"not found","status":404,` +
+			`"request":{"method":"GET"}}`},
+		{LogfmtEncoding, `ts=2021-07-05T10:59:13Z level=warn logger=app.users caller=app/users.go:42 msg="Request failed" ` +
+			`trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7 error="not found" status=404 request.method=GET`},
+		{ECSEncoding, `{"log.level":"warn","@timestamp":"2021-07-05T10:59:13.000Z","log.logger":"app.users",` +
+			`"message":"Request failed","ecs.version":"1.6.0","trace.id":"4bf92f3577b34da6a3ce929d0e0e4736",` +
+			`"span.id":"00f067aa0ba902b7","log.origin.file.name":"/src/app/users.go","log.origin.file.line":42,` +
+			`"log.origin.function":"app.(*Users).Get","error.message":"not found","status":404,"request":{"method":"GET"}}`},
+		{GoogleCloudEncoding, `{"severity":"WARNING","time":"2021-07-05T10:59:13Z","logger":"app.users","message":"Request failed",` +
+			`"logging.googleapis.com/trace":"projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736",` +
+			`"logging.googleapis.com/spanId":"00f067aa0ba902b7","logging.googleapis.com/sourceLocation":` +
+			`{"file":"/src/app/users.go","line":"42","function":"app.(*Users).Get"},"error":"not found","status":404,` +
+			`"request":{"method":"GET"}}`},
+		{OpenTelemetryEncoding, `{"SeverityText":"WARN","Timestamp":1625482753000000000,"Body":"Request failed",` +
+			`"SeverityNumber":13,"TraceId":"4bf92f3577b34da6a3ce929d0e0e4736","SpanId":"00f067aa0ba902b7",` +
+			`"InstrumentationScope":{"Name":"app.users"},"Attributes":{"exception.message":"not found","status":404,` +
+			`"request":{"method":"GET"}}}`},
+	} {
+		t.Run(string(tt.encoding), func(t *testing.T) {
+			enc, err := newEncoder(tt.encoding)
+			if err != nil {
+				t.Fatalf("newEncoder() error = %v", err)
+			}
+
+			// the trace context is added by the logger, see ``WithTraceContext``
+			enc = enc.Clone()
+			zap.String(TraceIDKey, "4bf92f3577b34da6a3ce929d0e0e4736").AddTo(enc)
+			zap.String(SpanIDKey, "00f067aa0ba902b7").AddTo(enc)
+
+			buf, err := enc.EncodeEntry(ent, []zapcore.Field{
+				zap.Error(errors.New("not found")),
+				zap.Int("status", 404),
+				zap.Object("request", objectFields{zap.String("method", "GET")}),
+			})
+			if err != nil {
+				t.Fatalf("EncodeEntry() error = %v", err)
+			}
+
+			if got := buf.String(); got != tt.want+"\n" {
+				t.Errorf("EncodeEntry() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogfmtEncoderContextOnly(t *testing.T) {
+	enc := newLogfmtEncoder(zapcore.EncoderConfig{})
+	enc.AddString("service", "users")
+
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "ignored"}, []zapcore.Field{zap.Int("status", 200)})
+	if err != nil {
+		t.Fatalf("EncodeEntry() error = %v", err)
+	}
+
+	if got := buf.String(); got != "service=users status=200\n" {
+		t.Errorf("EncodeEntry() = %q, want the fields without leading space", got)
+	}
+}
+
+func TestEncodersCaller(t *testing.T) {
+	for _, tt := range []struct {
+		encoding Encoding
+		key      string
+	}{
+		{ECSEncoding, `"log.origin.file.name":"`},
+		{GoogleCloudEncoding, `"logging.googleapis.com/sourceLocation":{"file":"`},
+	} {
+		t.Run(string(tt.encoding), func(t *testing.T) {
+			// the caller is added when a sink writes it
+			var json, sink bytes.Buffer
+			log := NewProductionLoggerWithOptions(&json, Options{Sinks: []Sink{{Writer: &sink, Encoding: tt.encoding}}})
+			log.Info("Request")
+
+			if !strings.Contains(sink.String(), tt.key) || !strings.Contains(sink.String(), "encoders_test.go") {
+				t.Errorf("the sink got %s, want the caller location", sink.String())
+			}
+		})
+	}
+
+	// the caller is not added otherwise
+	var json bytes.Buffer
+	NewProductionLoggerWithOptions(&json, Options{}).Info("Request")
+	if strings.Contains(json.String(), "caller") {
+		t.Errorf("the logger got %s, want no caller", json.String())
+	}
+}
+
+func TestWithTraceContext(t *testing.T) {
+	otelContext := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa},
+	}))
+	ocContext, span := octrace.StartSpan(context.Background(), "test")
+	defer span.End()
+
+	for name, tt := range map[string]struct {
+		ctx         context.Context
+		wantTraceID string
+		wantSpanID  string
+	}{
+		"opentelemetry": {otelContext, "4bf92f35000000000000000000000000", "00f067aa00000000"},
+		"opencensus":    {ocContext, span.SpanContext().TraceID.String(), span.SpanContext().SpanID.String()},
+		"none":          {context.Background(), "", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			WithTraceContext(tt.ctx, NewProductionLoggerWithOptions(&buf, Options{})).Info("Request")
+
+			logs := decodeLogs(t, &buf)
+			traceID, _ := logs[0][TraceIDKey].(string)
+			spanID, _ := logs[0][SpanIDKey].(string)
+			if traceID != tt.wantTraceID || spanID != tt.wantSpanID {
+				t.Errorf("the log is %v, want the trace id %q and span id %q", logs[0], tt.wantTraceID, tt.wantSpanID)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// ErrorChainKeySuffix defines the suffix of the key of the causes of a logged error, i.e. ``error.chain``. Each
+	// cause is logged with its message and type.
+	ErrorChainKeySuffix = ".chain"
+	// ErrorStackKeySuffix defines the suffix of the key of the stack trace of the origin of a logged error, i.e.
+	// ``error.stack``. It is only logged when an error of the chain carries a stack trace.
+	ErrorStackKeySuffix = ".stack"
+	// ErrorFieldsKeySuffix defines the suffix of the key of the fields contributed by the errors of the chain of a
+	// logged error through ``ErrorFields``, i.e. ``error.fields``.
+	ErrorFieldsKeySuffix = ".fields"
+
+	// errorChainMaxDepth defines the maximum number of causes of a logged error, it protects from cyclic chains.
+	errorChainMaxDepth = 32
+)
+
+// ErrorFields is implemented by the errors contributing structured fields to their logs.
+//
+// Example:
+//
+//    func (e *QuotaError) ErrorFields() []interface{} {
+//        return []interface{}{"tenant", e.Tenant, "limit", e.Limit}
+//    }
+type ErrorFields interface {
+	// ErrorFields returns the fields of the error as key and value pairs.
+	ErrorFields() []interface{}
+}
+
+// errorCore is a zapcore.Core logging the errors with their causes, origin stack trace and fields.
+type errorCore struct {
+	zapcore.Core
+}
+
+func newErrorCore(core zapcore.Core) zapcore.Core {
+	return &errorCore{Core: core}
+}
+
+func (c *errorCore) With(fields []zapcore.Field) zapcore.Core {
+	return &errorCore{Core: c.Core.With(expandErrorFields(fields))}
+}
+
+func (c *errorCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *errorCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, expandErrorFields(fields))
+}
+
+// expandErrorFields replaces the error fields by the error message, its causes, origin stack trace and fields. The
+// fields are returned as is when they hold no error.
+func expandErrorFields(fields []zapcore.Field) []zapcore.Field {
+	var expanded []zapcore.Field
+	for i, f := range fields {
+		err, ok := f.Interface.(error)
+		if f.Type != zapcore.ErrorType || !ok || err == nil {
+			if expanded != nil {
+				expanded = append(expanded, f)
+			}
+			continue
+		}
+
+		if expanded == nil {
+			expanded = append(make([]zapcore.Field, 0, len(fields)+3), fields[:i]...)
+		}
+		expanded = appendErrorFields(expanded, f.Key, err)
+	}
+
+	if expanded == nil {
+		return fields
+	}
+	return expanded
+}
+
+// appendErrorFields appends the fields describing the specified error.
+func appendErrorFields(fields []zapcore.Field, key string, err error) []zapcore.Field {
+	chain := errorChain(err)
+
+	fields = append(fields, zap.String(key, safeString(err)))
+
+	if len(chain) > 1 {
+		fields = append(fields, zap.Array(key+ErrorChainKeySuffix, errorCauses(chain)))
+	}
+
+	// The deepest stack trace is the closest to the origin of the error.
+	for i := len(chain) - 1; i >= 0; i-- {
+		if stack := errorStack(chain[i]); stack != "" {
+			fields = append(fields, zap.String(key+ErrorStackKeySuffix, stack))
+			break
+		}
+	}
+
+	var errorFields objectFields
+	for _, cause := range chain {
+		if fielder, ok := cause.(ErrorFields); ok {
+			keysAndValues := fielder.ErrorFields()
+			for i := 0; i+1 < len(keysAndValues); i += 2 {
+				errorFields = append(errorFields, zap.Any(fmt.Sprint(keysAndValues[i]), keysAndValues[i+1]))
+			}
+		}
+	}
+
+	if len(errorFields) > 0 {
+		fields = append(fields, zap.Object(key+ErrorFieldsKeySuffix, errorFields))
+	}
+
+	return fields
+}
+
+// errorChain returns the specified error followed by its causes, depth first. Both ``Unwrap() error`` and
+// ``Unwrap() []error`` are followed.
+func errorChain(err error) []error {
+	var chain []error
+
+	var walk func(err error)
+	walk = func(err error) {
+		if err == nil || len(chain) >= errorChainMaxDepth {
+			return
+		}
+		chain = append(chain, err)
+
+		switch wrapper := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(wrapper.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, cause := range wrapper.Unwrap() {
+				walk(cause)
+			}
+		}
+	}
+	walk(err)
+
+	return chain
+}
+
+// errorStack returns the stack trace carried by the specified error, or an empty string. The errors carry their
+// stack trace through either:
+//
+// - a ``StackTrace()`` method, whatever its result type, i.e. ``github.com/pkg/errors``
+//
+// - a ``Callers() []uintptr`` method
+func errorStack(err error) string {
+	if callers, ok := err.(interface{ Callers() []uintptr }); ok {
+		return formatCallers(callers.Callers())
+	}
+
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return ""
+	}
+
+	stack := method.Call(nil)[0]
+	if (stack.Kind() == reflect.Slice || stack.Kind() == reflect.Ptr) && stack.IsNil() {
+		return ""
+	}
+
+	if callers, ok := stack.Interface().([]uintptr); ok {
+		return formatCallers(callers)
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%+v", stack.Interface()), "\n")
+}
+
+// formatCallers formats the specified program counters as the zap stack traces.
+func formatCallers(callers []uintptr) string {
+	var b strings.Builder
+
+	frames := runtime.CallersFrames(callers)
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			fmt.Fprintf(&b, "%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+		}
+
+		if !more {
+			return b.String()
+		}
+	}
+}
+
+// errorCauses encodes the causes of an error.
+type errorCauses []error
+
+func (c errorCauses) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, cause := range c {
+		if err := enc.AppendObject(errorCause{cause}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errorCause encodes a cause of an error.
+type errorCause struct {
+	err error
+}
+
+func (c errorCause) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("message", safeString(c.err))
+	enc.AddString("type", fmt.Sprintf("%T", c.err))
+	return nil
+}
@@ -0,0 +1,194 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// quotaError is an error contributing fields to its logs.
+type quotaError struct {
+	tenant string
+	limit  int
+	err    error
+}
+
+func (e *quotaError) Error() string { return fmt.Sprintf("quota of %s exceeded: %v", e.tenant, e.err) }
+
+func (e *quotaError) Unwrap() error { return e.err }
+
+func (e *quotaError) ErrorFields() []interface{} {
+	return []interface{}{"tenant", e.tenant, "limit", e.limit, "ignored"}
+}
+
+// stackError is an error carrying the stack trace of its origin.
+type stackError struct {
+	callers []uintptr
+}
+
+func newStackError() *stackError {
+	callers := make([]uintptr, 32)
+	return &stackError{callers: callers[:runtime.Callers(1, callers)]}
+}
+
+func (e *stackError) Error() string { return "origin" }
+
+func (e *stackError) Callers() []uintptr { return e.callers }
+
+// tracedError is an error carrying its stack trace through a ``StackTrace()`` method.
+type tracedError struct{}
+
+func (tracedError) Error() string { return "traced" }
+
+func (tracedError) StackTrace() fmt.Stringer { return stackTrace("main.handle\n\tmain.go:12") }
+
+type stackTrace string
+
+func (s stackTrace) String() string { return string(s) }
+
+// cyclicError is an error whose chain never ends.
+type cyclicError struct{}
+
+func (e *cyclicError) Error() string { return "cyclic" }
+
+func (e *cyclicError) Unwrap() error { return e }
+
+// nilError is an error whose Error method panics with a nil receiver.
+type nilError struct {
+	message string
+}
+
+func (e *nilError) Error() string { return e.message }
+
+func TestErrorCoreChain(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewProductionLoggerWithOptions(&buf, Options{})
+
+	err := fmt.Errorf("failed to create the order: %w", &quotaError{tenant: "acme", limit: 10, err: io.EOF})
+	log.Error(err, "Request failed")
+
+	logs := decodeLogs(t, &buf)
+	if len(logs) != 1 {
+		t.Fatalf("got %d logs, want 1", len(logs))
+	}
+
+	if got := logs[0]["error"]; got != err.Error() {
+		t.Errorf("error = %v, want %q", got, err.Error())
+	}
+
+	chain, _ := logs[0]["error"+ErrorChainKeySuffix].([]interface{})
+	var got []string
+	for _, cause := range chain {
+		cause, _ := cause.(map[string]interface{})
+		got = append(got, fmt.Sprintf("%v (%v)", cause["message"], cause["type"]))
+	}
+	want := []string{
+		err.Error() + " (*fmt.wrapError)",
+		"quota of acme exceeded: EOF (*logger.quotaError)",
+		"EOF (*errors.errorString)",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("error.chain = %q, want %q", got, want)
+	}
+
+	// the odd key of the error fields is ignored
+	fields, _ := logs[0]["error"+ErrorFieldsKeySuffix].(map[string]interface{})
+	if len(fields) != 2 || fields["tenant"] != "acme" || fields["limit"] != 10.0 {
+		t.Errorf("error.fields = %v, want the tenant and the limit", fields)
+	}
+
+	if _, ok := logs[0]["error"+ErrorStackKeySuffix]; ok {
+		t.Errorf("error.stack = %v, want none without stack trace", logs[0]["error"+ErrorStackKeySuffix])
+	}
+}
+
+func TestErrorCoreSingleError(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewProductionLoggerWithOptions(&buf, Options{})
+
+	log.Error(io.EOF, "Read failed")
+	log.Error(nil, "No error")
+	log.Error((*nilError)(nil), "Nil error")
+
+	logs := decodeLogs(t, &buf)
+	if len(logs) != 3 {
+		t.Fatalf("got %d logs, want 3", len(logs))
+	}
+
+	// an error without causes is logged with its message only
+	if logs[0]["error"] != "EOF" || len(logs[0]) != len(logs[1])+1 {
+		t.Errorf("got the log %v, want the error message only", logs[0])
+	}
+	if _, ok := logs[1]["error"]; ok {
+		t.Errorf("got the log %v, want no error", logs[1])
+	}
+	if logs[2]["error"] != "<nil>" {
+		t.Errorf("error = %v, want <nil>", logs[2]["error"])
+	}
+}
+
+func TestErrorCoreStack(t *testing.T) {
+	origin := newStackError()
+
+	for name, tt := range map[string]struct {
+		err  error
+		want string
+	}{
+		"callers":     {fmt.Errorf("wrapped: %w", origin), "advanced.newStackError\n\t"},
+		"stack trace": {fmt.Errorf("wrapped: %w", tracedError{}), "main.handle\n\tmain.go:12"},
+		"joined":      {errors.Join(errors.New("first"), fmt.Errorf("second: %w", origin)), "advanced.newStackError\n\t"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := NewProductionLoggerWithOptions(&buf, Options{})
+			log.Error(tt.err, "Request failed")
+
+			stack, _ := decodeLogs(t, &buf)[0]["error"+ErrorStackKeySuffix].(string)
+			if !strings.Contains(stack, tt.want) {
+				t.Errorf("error.stack = %q, want it to contain %q", stack, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCoreDepth(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewProductionLoggerWithOptions(&buf, Options{})
+	log.Error(&cyclicError{}, "Request failed")
+
+	// the cyclic chains are cut
+	chain, _ := decodeLogs(t, &buf)[0]["error"+ErrorChainKeySuffix].([]interface{})
+	if len(chain) != errorChainMaxDepth {
+		t.Errorf("got %d causes, want %d", len(chain), errorChainMaxDepth)
+	}
+}
+
+func TestErrorCoreWith(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewProductionLoggerWithOptions(nil, Options{
+		Sinks: []Sink{{Writer: &buf, Level: zapcore.WarnLevel}},
+	})
+
+	// the errors of the logger values are expanded as well
+	err := fmt.Errorf("connection lost: %w", &quotaError{tenant: "acme", limit: 10, err: io.EOF})
+	log = log.WithValues("cause", err)
+	log.Info("Below the sink level")
+	log.Error(nil, "Request failed")
+
+	logs := decodeLogs(t, &buf)
+	if len(logs) != 1 {
+		t.Fatalf("got %d logs, want the error only", len(logs))
+	}
+
+	fields, _ := logs[0]["cause"+ErrorFieldsKeySuffix].(map[string]interface{})
+	chain, _ := logs[0]["cause"+ErrorChainKeySuffix].([]interface{})
+	if logs[0]["cause"] != err.Error() || len(chain) != 3 || fields["tenant"] != "acme" {
+		t.Errorf("got the log %v, want the expanded cause", logs[0])
+	}
+}
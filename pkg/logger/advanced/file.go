@@ -0,0 +1,467 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// FileDefaultMaxSize defines the default size, in bytes, a log file reaches before being rotated.
+	FileDefaultMaxSize = 100 * 1024 * 1024
+
+	backupTimeFormat   = "2006-01-02T15-04-05.000"
+	compressedFileExt  = ".gz"
+	defaultFileMode    = 0644
+	defaultFileDirMode = 0755
+)
+
+var fileLog = logf.Log.WithName("logger").WithName("file")
+
+// FileOptions defines a log file and its rotation policy.
+type FileOptions struct {
+	// Filename is the path of the log file. Rotated files are kept in the same directory.
+	Filename string
+	// MaxSize defines the size, in bytes, the file reaches before being rotated. It defaults to
+	// ``FileDefaultMaxSize``.
+	MaxSize int64
+	// MaxAge defines how long the file is written before being rotated. The file is not rotated on its age when 0.
+	MaxAge time.Duration
+	// MaxBackups defines the number of rotated files to keep. All the rotated files are kept when 0.
+	MaxBackups int
+	// Compress enables the gzip compression of the rotated files.
+	Compress bool
+	// DisableReopenOnSignal disables the reopening of the file when a ``SIGHUP`` is received. The reopening allows
+	// an external tool such as logrotate to move the file.
+	DisableReopenOnSignal bool
+}
+
+func setFileOptionsDefaults(options *FileOptions) {
+	if options.MaxSize == 0 {
+		options.MaxSize = FileDefaultMaxSize
+	}
+}
+
+// FileWriter is a zapcore.WriteSyncer writing the logs to a file rotated on its size and age.
+//
+// Rotated files are renamed with their rotation time, i.e. ``app.log`` is rotated as
+// ``app-2021-07-05T10-59-13.000.log``, and optionally compressed. Rotation events are reported through the
+// micro-server logger.
+type FileWriter struct {
+	options FileOptions
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+
+	millMu   sync.Mutex
+	millWg   sync.WaitGroup
+	reportWg sync.WaitGroup
+	signals  chan os.Signal
+	stopOnce sync.Once
+}
+
+// NewFileWriter opens, or creates, the log file defined by the options.
+func NewFileWriter(options FileOptions) (*FileWriter, error) {
+	setFileOptionsDefaults(&options)
+
+	if options.Filename == "" {
+		return nil, fmt.Errorf("log file name not defined")
+	}
+
+	w := &FileWriter{options: options}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	if !options.DisableReopenOnSignal {
+		w.signals = make(chan os.Signal, 1)
+		signal.Notify(w.signals, syscall.SIGHUP)
+
+		go w.reopenOnSignal()
+	}
+
+	return w, nil
+}
+
+// NewFileSink creates a JSON sink writing to the log file defined by the options.
+func NewFileSink(options FileOptions) (Sink, error) {
+	w, err := NewFileWriter(options)
+	if err != nil {
+		return Sink{}, err
+	}
+
+	return Sink{Writer: w, Encoding: JSONEncoding}, nil
+}
+
+// Write implements io.Writer. The file is rotated beforehand when the write would exceed its maximum size or when
+// it is older than its maximum age. When the file fails to be reopened by a rotation, the error is reported once and
+// the file is opened again by the next writes, the rotations or the ``SIGHUP`` signals.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+
+	if w.closed {
+		w.mu.Unlock()
+		return 0, fmt.Errorf("log file %s closed", w.options.Filename)
+	}
+
+	var report func()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			w.mu.Unlock()
+			return 0, fmt.Errorf("failed to reopen the log file %s: %w", w.options.Filename, err)
+		}
+		report = func() { fileLog.Info("Reopened the log file", "filename", w.options.Filename) }
+	} else if w.shouldRotate(int64(len(p))) {
+		backup, err := w.rotate()
+		report = func() { w.reportRotation(backup, err) }
+	}
+
+	var n int
+	var err error
+	if w.file != nil {
+		n, err = w.file.Write(p)
+		w.size += int64(n)
+	} else {
+		err = fmt.Errorf("failed to reopen the log file %s after its rotation", w.options.Filename)
+	}
+
+	// Report from another routine as the report may be written to this very file, by the routine of an asynchronous
+	// sink that would wait for itself, see ``OverflowBlock``. The report is added with the lock held so that Close
+	// waits for it.
+	if report != nil {
+		w.reportWg.Add(1)
+	}
+	w.mu.Unlock()
+
+	if report != nil {
+		go func() {
+			defer w.reportWg.Done()
+			report()
+		}()
+	}
+
+	return n, err
+}
+
+// Sync implements zapcore.WriteSyncer.
+func (w *FileWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Rotate rotates the file immediately. It fails once the writer is closed.
+func (w *FileWriter) Rotate() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return fmt.Errorf("log file %s closed", w.options.Filename)
+	}
+	backup, err := w.rotate()
+	w.mu.Unlock()
+
+	w.reportRotation(backup, err)
+
+	return err
+}
+
+// Reopen closes and reopens the file, so that an external tool can rotate it. It fails once the writer is closed.
+func (w *FileWriter) Reopen() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return fmt.Errorf("log file %s closed", w.options.Filename)
+	}
+	err := w.reopen()
+	w.mu.Unlock()
+
+	if err != nil {
+		fileLog.Error(err, "Failed to reopen the log file", "filename", w.options.Filename)
+		return err
+	}
+
+	fileLog.Info("Reopened the log file", "filename", w.options.Filename)
+	return nil
+}
+
+// Close closes the file and waits for the pending compressions and rotation reports to complete.
+func (w *FileWriter) Close() error {
+	w.stopOnce.Do(func() {
+		if w.signals != nil {
+			signal.Stop(w.signals)
+			close(w.signals)
+		}
+	})
+
+	w.mu.Lock()
+	var err error
+	w.closed = true
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+
+	w.millWg.Wait()
+	w.reportWg.Wait()
+
+	return err
+}
+
+func (w *FileWriter) reopenOnSignal() {
+	for range w.signals {
+		_ = w.Reopen()
+	}
+}
+
+func (w *FileWriter) shouldRotate(writeLen int64) bool {
+	if w.size > 0 && w.size+writeLen > w.options.MaxSize {
+		return true
+	}
+
+	return w.options.MaxAge > 0 && time.Since(w.openedAt) >= w.options.MaxAge
+}
+
+func (w *FileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.options.Filename), defaultFileDirMode); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(w.options.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = time.Now()
+
+	return nil
+}
+
+func (w *FileWriter) reopen() error {
+	if w.file != nil {
+		err := w.file.Close()
+		// The file cannot be written once closed, even when the close fails: it is reopened in any case.
+		w.file = nil
+		if err != nil {
+			if openErr := w.open(); openErr != nil {
+				return openErr
+			}
+			return err
+		}
+	}
+
+	return w.open()
+}
+
+// rotate renames the current file to a backup and opens a new file. It must be called with the lock held.
+func (w *FileWriter) rotate() (string, error) {
+	var closeErr error
+	if w.file != nil {
+		// The file cannot be written once closed, even when the close fails: the rotation goes on.
+		closeErr = w.file.Close()
+		w.file = nil
+	}
+
+	backup := w.backupName(time.Now())
+	if err := os.Rename(w.options.Filename, backup); err != nil && !os.IsNotExist(err) {
+		// Keep writing to the current file rather than losing the logs.
+		if openErr := w.open(); openErr != nil {
+			return "", openErr
+		}
+		return "", err
+	}
+
+	if err := w.open(); err != nil {
+		return "", err
+	}
+
+	w.millWg.Add(1)
+	go w.mill(backup)
+
+	return backup, closeErr
+}
+
+func (w *FileWriter) reportRotation(backup string, err error) {
+	if err != nil {
+		fileLog.Error(err, "Failed to rotate the log file", "filename", w.options.Filename, "backup", backup)
+		return
+	}
+
+	if backup != "" {
+		fileLog.Info("Rotated the log file", "filename", w.options.Filename, "backup", backup)
+	}
+}
+
+// backupName returns the name of a backup rotated at the specified time. A counter is appended to the time when a
+// backup of the same millisecond exists, i.e. ``app-2021-07-05T10-59-13.000-1.log``.
+func (w *FileWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(w.options.Filename)
+	prefix := strings.TrimSuffix(w.options.Filename, ext)
+	stamp := t.Format(backupTimeFormat)
+
+	name := fmt.Sprintf("%s-%s%s", prefix, stamp, ext)
+	for i := 1; backupExists(name); i++ {
+		name = fmt.Sprintf("%s-%s-%d%s", prefix, stamp, i, ext)
+	}
+	return name
+}
+
+// backupExists returns true when the specified backup exists, compressed or not.
+func backupExists(name string) bool {
+	for _, n := range []string{name, name + compressedFileExt} {
+		if _, err := os.Lstat(n); err == nil || !os.IsNotExist(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBackupStamp parses the rotation time and counter of a backup from its stamp, see ``backupName``.
+func parseBackupStamp(stamp string) (time.Time, int, bool) {
+	if len(stamp) < len(backupTimeFormat) {
+		return time.Time{}, 0, false
+	}
+
+	t, err := time.Parse(backupTimeFormat, stamp[:len(backupTimeFormat)])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+
+	counter := 0
+	if rest := stamp[len(backupTimeFormat):]; rest != "" {
+		if !strings.HasPrefix(rest, "-") {
+			return time.Time{}, 0, false
+		}
+		if counter, err = strconv.Atoi(rest[1:]); err != nil || counter <= 0 {
+			return time.Time{}, 0, false
+		}
+	}
+
+	return t, counter, true
+}
+
+// mill compresses the specified backup and removes the extra backups.
+func (w *FileWriter) mill(backup string) {
+	defer w.millWg.Done()
+
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+
+	if w.options.Compress {
+		if err := compressFile(backup); err != nil {
+			fileLog.Error(err, "Failed to compress the rotated log file", "backup", backup)
+		}
+	}
+
+	if w.options.MaxBackups > 0 {
+		w.removeExtraBackups()
+	}
+}
+
+func (w *FileWriter) removeExtraBackups() {
+	ext := filepath.Ext(w.options.Filename)
+	prefix := strings.TrimSuffix(filepath.Base(w.options.Filename), ext) + "-"
+	dir := filepath.Dir(w.options.Filename)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fileLog.Error(err, "Failed to list the rotated log files", "directory", dir)
+		return
+	}
+
+	type backupFile struct {
+		name    string
+		time    time.Time
+		counter int
+	}
+
+	var backups []backupFile
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), compressedFileExt)
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		if t, counter, ok := parseBackupStamp(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)); ok {
+			backups = append(backups, backupFile{name: entry.Name(), time: t, counter: counter})
+		}
+	}
+
+	// The most recent backups come last.
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.Before(backups[j].time)
+		}
+		return backups[i].counter < backups[j].counter
+	})
+
+	for len(backups) > w.options.MaxBackups {
+		name := filepath.Join(dir, backups[0].name)
+		if err := os.Remove(name); err != nil {
+			fileLog.Error(err, "Failed to remove the rotated log file", "backup", name)
+		} else {
+			fileLog.Info("Removed the rotated log file", "backup", name)
+		}
+		backups = backups[1:]
+	}
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+compressedFileExt, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, defaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = gz.Close()
+		_ = dst.Close()
+		_ = os.Remove(name + compressedFileExt)
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(name + compressedFileExt)
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	_ = src.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,226 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFileWriter creates a file writer of the app.log file of a temporary directory.
+func newTestFileWriter(t *testing.T, options FileOptions) (*FileWriter, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	options.Filename = filepath.Join(dir, "app.log")
+	options.DisableReopenOnSignal = true
+
+	w, err := NewFileWriter(options)
+	if err != nil {
+		t.Fatalf("NewFileWriter() error = %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+
+	return w, dir
+}
+
+// backups returns the sorted names of the backups of app.log.
+func backups(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Name() != "app.log" {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFileWriterRotateOnSize(t *testing.T) {
+	w, dir := newTestFileWriter(t, FileOptions{MaxSize: 100})
+
+	first := strings.Repeat("a", 60) + "\n"
+	second := strings.Repeat("b", 60) + "\n"
+	for _, line := range []string{first, second} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	names := backups(t, dir)
+	if len(names) != 1 || !strings.HasPrefix(names[0], "app-") || !strings.HasSuffix(names[0], ".log") {
+		t.Fatalf("backups = %v, want one app-<time>.log backup", names)
+	}
+
+	if got := readFile(t, filepath.Join(dir, names[0])); got != first {
+		t.Errorf("backup content = %q, want %q", got, first)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.log")); got != second {
+		t.Errorf("file content = %q, want %q", got, second)
+	}
+}
+
+func TestFileWriterRotateOnAge(t *testing.T) {
+	w, dir := newTestFileWriter(t, FileOptions{MaxAge: time.Millisecond})
+
+	_, _ = w.Write([]byte("first\n"))
+	time.Sleep(5 * time.Millisecond)
+	_, _ = w.Write([]byte("second\n"))
+	_ = w.Close()
+
+	if names := backups(t, dir); len(names) != 1 {
+		t.Errorf("backups = %v, want one backup once the file is too old", names)
+	}
+}
+
+func TestFileWriterRetention(t *testing.T) {
+	w, dir := newTestFileWriter(t, FileOptions{MaxBackups: 2, Compress: true})
+
+	for _, line := range []string{"1\n", "2\n", "3\n", "4\n"} {
+		_, _ = w.Write([]byte(line))
+		if err := w.Rotate(); err != nil {
+			t.Fatalf("Rotate() error = %v", err)
+		}
+	}
+	_ = w.Close()
+
+	// the backups rotated within the same millisecond are kept in order
+	names := backups(t, dir)
+	if len(names) != 2 {
+		t.Fatalf("backups = %v, want the 2 most recent", names)
+	}
+
+	var contents []string
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".log.gz") {
+			t.Errorf("backup %s is not compressed", name)
+			continue
+		}
+
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(gz)
+		f.Close()
+		contents = append(contents, string(data))
+	}
+
+	sort.Strings(contents)
+	if strings.Join(contents, "") != "3\n4\n" {
+		t.Errorf("kept backups hold %q, want the 2 most recent", contents)
+	}
+}
+
+func TestFileWriterBackupName(t *testing.T) {
+	w, dir := newTestFileWriter(t, FileOptions{})
+
+	now := time.Date(2021, 7, 5, 10, 59, 13, 0, time.UTC)
+	name := w.backupName(now)
+	if want := filepath.Join(dir, "app-2021-07-05T10-59-13.000.log"); name != want {
+		t.Fatalf("backupName() = %s, want %s", name, want)
+	}
+
+	if err := os.WriteFile(name+compressedFileExt, nil, defaultFileMode); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.backupName(now), filepath.Join(dir, "app-2021-07-05T10-59-13.000-1.log"); got != want {
+		t.Errorf("backupName() = %s, want %s when the backup exists", got, want)
+	}
+
+	if _, counter, ok := parseBackupStamp("2021-07-05T10-59-13.000-1"); !ok || counter != 1 {
+		t.Errorf("parseBackupStamp() = %d, %v, want 1, true", counter, ok)
+	}
+	if _, _, ok := parseBackupStamp("2021-07-05T10-59-13.000x"); ok {
+		t.Error("parseBackupStamp() accepts an invalid stamp")
+	}
+}
+
+func TestFileWriterClosed(t *testing.T) {
+	w, dir := newTestFileWriter(t, FileOptions{})
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "app.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("log\n")); err == nil {
+		t.Error("Write() succeeds once closed")
+	}
+	if err := w.Rotate(); err == nil {
+		t.Error("Rotate() succeeds once closed")
+	}
+	if err := w.Reopen(); err == nil {
+		t.Error("Reopen() succeeds once closed")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "app.log")); !os.IsNotExist(err) {
+		t.Error("the file is reopened once closed")
+	}
+}
+
+func TestFileWriterReopenAfterRotation(t *testing.T) {
+	w, dir := newTestFileWriter(t, FileOptions{})
+	filename := filepath.Join(dir, "app.log")
+
+	// the rotation failed to reopen the file, a directory being in the way
+	w.mu.Lock()
+	_ = w.file.Close()
+	w.file = nil
+	w.mu.Unlock()
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filename, defaultFileDirMode); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Error("Write() succeeds without file")
+	}
+
+	// the file is reopened by the next write
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("log\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := readFile(t, filename); got != "log\n" {
+		t.Errorf("file content = %q, want %q", got, "log\n")
+	}
+}
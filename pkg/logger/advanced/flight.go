@@ -0,0 +1,362 @@
+package logger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// FlightRecorderDefaultSize defines the default number of logs buffered per logger by the flight recorder.
+	FlightRecorderDefaultSize = 256
+	// FlightRecorderDefaultMaxAge defines the default maximum age of the logs flushed by the flight recorder.
+	FlightRecorderDefaultMaxAge = 2 * time.Minute
+	// FlightRecorderDefaultBurstWindow defines the default window the errors are counted over to detect a burst.
+	FlightRecorderDefaultBurstWindow = 10 * time.Second
+	// FlightRecorderDefaultCoolDown defines the default duration the verbosity stays raised after an error burst.
+	FlightRecorderDefaultCoolDown = 5 * time.Minute
+	// FlightRecorderDefaultMaxLoggers defines the default number of loggers the flight recorder buffers the logs of.
+	FlightRecorderDefaultMaxLoggers = 64
+
+	// FlightRecorderKey defines the key marking the logs written by the flight recorder when an error is logged.
+	FlightRecorderKey = "flight_recorder"
+
+	flightRecorderBurstMessage = "Raising the log verbosity on error burst"
+)
+
+// FlightRecorderOptions defines how a sink keeps the context of the errors.
+//
+// The logs below the sink level, down to ``Level``, are buffered per logger instead of being dropped. When a logger
+// logs an error, its buffered logs are written before the error, marked with ``FlightRecorderKey``. The buffered
+// levels are enabled for the callers, i.e. ``log.V(1).Enabled()`` is true when Level is debug, as their logs must be
+// produced to be buffered: no level is buffered unless Level is set.
+//
+// When the sink logs ``BurstThreshold`` errors within ``BurstWindow``, its level is lowered to ``BurstLevel`` for
+// the ``CoolDown`` duration.
+//
+// The buffered logs are encoded by the sink encoder when logged, and written as is on error. The sinks created by
+// ``Sink.NewCore`` do not buffer their logs, the flight recorder only raises their verbosity on error bursts.
+type FlightRecorderOptions struct {
+	// Disabled disables the flight recorder of the sink.
+	Disabled bool
+	// Level defines the minimum level of the buffered logs, i.e. ``zapcore.DebugLevel``. The logs are only buffered
+	// below the sink level: none is buffered by an info sink when zero.
+	Level zapcore.Level
+	// Size defines the number of logs buffered per logger. It defaults to ``FlightRecorderDefaultSize``.
+	Size int
+	// MaxLoggers defines the number of loggers the logs are buffered of, the buffer of the least recently written
+	// logger is dropped for a new logger. It defaults to ``FlightRecorderDefaultMaxLoggers``.
+	MaxLoggers int
+	// MaxAge defines the maximum age of the buffered logs written on error. It defaults to
+	// ``FlightRecorderDefaultMaxAge``.
+	MaxAge time.Duration
+	// BurstThreshold defines the number of errors within BurstWindow raising the verbosity. The verbosity is never
+	// raised when 0.
+	BurstThreshold int
+	// BurstWindow defines the window the errors are counted over. It defaults to ``FlightRecorderDefaultBurstWindow``.
+	BurstWindow time.Duration
+	// BurstLevel defines the level of the sink while the verbosity is raised. It defaults to Level when nil.
+	BurstLevel *zapcore.Level
+	// CoolDown defines how long the verbosity stays raised after the last burst. It defaults to
+	// ``FlightRecorderDefaultCoolDown``.
+	CoolDown time.Duration
+}
+
+func setFlightRecorderOptionsDefaults(options *FlightRecorderOptions) {
+	if options.Size <= 0 {
+		options.Size = FlightRecorderDefaultSize
+	}
+
+	if options.MaxLoggers <= 0 {
+		options.MaxLoggers = FlightRecorderDefaultMaxLoggers
+	}
+
+	if options.MaxAge <= 0 {
+		options.MaxAge = FlightRecorderDefaultMaxAge
+	}
+
+	if options.BurstWindow <= 0 {
+		options.BurstWindow = FlightRecorderDefaultBurstWindow
+	}
+
+	if options.BurstLevel == nil {
+		level := options.Level
+		options.BurstLevel = &level
+	}
+
+	if options.CoolDown <= 0 {
+		options.CoolDown = FlightRecorderDefaultCoolDown
+	}
+}
+
+// flightRecorder holds the buffered logs and the error burst state of a sink.
+type flightRecorder struct {
+	options FlightRecorderOptions
+	level   zapcore.LevelEnabler
+	// raisedUntil is the time, in Unix nanoseconds, the verbosity stays raised until.
+	raisedUntil int64
+
+	mu          sync.Mutex
+	buffers     map[string]*flightBuffer
+	windowStart time.Time
+	errors      int
+}
+
+// flightEntry is a buffered log. The log is encoded when buffered rather than when the buffer is written, after the
+// caller may have changed its fields.
+type flightEntry struct {
+	time time.Time
+	log  []byte
+}
+
+// flightBuffer is the ring buffer of the logs of a logger.
+type flightBuffer struct {
+	entries []flightEntry
+	next    int
+	full    bool
+	// last is the time of the last buffered log.
+	last time.Time
+}
+
+func newFlightRecorder(level zapcore.LevelEnabler, options FlightRecorderOptions) *flightRecorder {
+	setFlightRecorderOptionsDefaults(&options)
+
+	return &flightRecorder{
+		options: options,
+		level:   level,
+		buffers: make(map[string]*flightBuffer),
+	}
+}
+
+// raised returns true while the verbosity is raised by an error burst.
+func (r *flightRecorder) raised() bool {
+	return time.Now().UnixNano() < atomic.LoadInt64(&r.raisedUntil)
+}
+
+// Enabled is the level of the sink core: the sink level, lowered to the burst level while the verbosity is raised.
+func (r *flightRecorder) Enabled(level zapcore.Level) bool {
+	return r.level.Enabled(level) || (level >= *r.options.BurstLevel && r.raised())
+}
+
+func (r *flightRecorder) add(loggerName string, entry flightEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buffer, ok := r.buffers[loggerName]
+	if !ok {
+		if len(r.buffers) >= r.options.MaxLoggers {
+			r.prune(entry.time)
+		}
+
+		buffer = &flightBuffer{entries: make([]flightEntry, r.options.Size)}
+		r.buffers[loggerName] = buffer
+	}
+
+	buffer.last = entry.time
+	buffer.entries[buffer.next] = entry
+	buffer.next++
+	if buffer.next == len(buffer.entries) {
+		buffer.next = 0
+		buffer.full = true
+	}
+}
+
+// prune drops the buffers whose logs are all too old, or the buffer of the least recently written logger when none
+// is. It must be called with the lock held.
+func (r *flightRecorder) prune(now time.Time) {
+	oldest := now.Add(-r.options.MaxAge)
+
+	var lru *string
+	var lruLast time.Time
+	for name, buffer := range r.buffers {
+		if buffer.last.Before(oldest) {
+			delete(r.buffers, name)
+			continue
+		}
+
+		if lru == nil || buffer.last.Before(lruLast) {
+			name := name
+			lru, lruLast = &name, buffer.last
+		}
+	}
+
+	if lru != nil && len(r.buffers) >= r.options.MaxLoggers {
+		delete(r.buffers, *lru)
+	}
+}
+
+// take removes and returns the buffered logs of the specified logger that are not too old, from the oldest.
+func (r *flightRecorder) take(loggerName string, now time.Time) []flightEntry {
+	r.mu.Lock()
+	buffer, ok := r.buffers[loggerName]
+	delete(r.buffers, loggerName)
+	r.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	entries := buffer.entries[:buffer.next]
+	if buffer.full {
+		entries = append(buffer.entries[buffer.next:], buffer.entries[:buffer.next]...)
+	}
+
+	oldest := now.Add(-r.options.MaxAge)
+	for len(entries) > 0 && entries[0].time.Before(oldest) {
+		entries = entries[1:]
+	}
+	return entries
+}
+
+// countError counts an error and returns true when it starts a burst.
+func (r *flightRecorder) countError(now time.Time) bool {
+	if r.options.BurstThreshold <= 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.windowStart) > r.options.BurstWindow {
+		r.windowStart = now
+		r.errors = 0
+	}
+
+	r.errors++
+	if r.errors < r.options.BurstThreshold {
+		return false
+	}
+
+	r.windowStart = now
+	r.errors = 0
+
+	wasRaised := r.raised()
+	atomic.StoreInt64(&r.raisedUntil, now.Add(r.options.CoolDown).UnixNano())
+	return !wasRaised
+}
+
+// flightCore is a zapcore.Core buffering the logs below the sink level and writing them when an error is logged.
+type flightCore struct {
+	// core is the sink core, its level is the flight recorder.
+	core zapcore.Core
+	// buffer encodes the logs below the sink level into the recorder buffers, nil when the sink cannot write encoded
+	// logs.
+	buffer   zapcore.Core
+	ws       zapcore.WriteSyncer
+	recorder *flightRecorder
+}
+
+// newFlightCore wraps the specified sink core with the flight recorder. The buffered logs are encoded by the buffer
+// core, see ``newFlightBufferCore``, and written to ws on error.
+func newFlightCore(core, buffer zapcore.Core, ws zapcore.WriteSyncer, recorder *flightRecorder) zapcore.Core {
+	return &flightCore{core: core, buffer: buffer, ws: ws, recorder: recorder}
+}
+
+// buffers returns true when the logs of the specified level are buffered.
+func (c *flightCore) buffers(level zapcore.Level) bool {
+	return c.buffer != nil && level >= c.recorder.options.Level
+}
+
+func (c *flightCore) Enabled(level zapcore.Level) bool {
+	return c.core.Enabled(level) || c.buffers(level)
+}
+
+func (c *flightCore) With(fields []zapcore.Field) zapcore.Core {
+	buffer := c.buffer
+	if buffer != nil {
+		buffer = buffer.With(fields)
+	}
+	return &flightCore{core: c.core.With(fields), buffer: buffer, ws: c.ws, recorder: c.recorder}
+}
+
+func (c *flightCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.core.Enabled(ent.Level) {
+		if c.buffers(ent.Level) {
+			return ce.AddCore(ent, c)
+		}
+		return ce
+	}
+
+	if ent.Level >= zapcore.ErrorLevel {
+		c.recordError(ent)
+	}
+
+	return c.core.Check(ent, ce)
+}
+
+// Write buffers the logs below the sink level.
+func (c *flightCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.buffer.Write(ent, fields)
+}
+
+func (c *flightCore) Sync() error {
+	return c.core.Sync()
+}
+
+// recordError writes the buffered logs of the logger of the specified error, and raises the verbosity on bursts.
+func (c *flightCore) recordError(ent zapcore.Entry) {
+	now := time.Now()
+
+	if c.buffer != nil {
+		for _, entry := range c.recorder.take(ent.LoggerName, now) {
+			_, _ = c.ws.Write(entry.log)
+		}
+	}
+
+	if c.recorder.countError(now) {
+		_ = c.core.Write(
+			zapcore.Entry{Level: zapcore.WarnLevel, Time: now, LoggerName: ent.LoggerName, Message: flightRecorderBurstMessage},
+			[]zapcore.Field{
+				zap.Stringer("burst_level", *c.recorder.options.BurstLevel),
+				zap.Duration("cool_down", c.recorder.options.CoolDown),
+			},
+		)
+	}
+}
+
+// flightBufferCore is a zapcore.Core encoding the logs into the flight recorder buffers, marked with
+// ``FlightRecorderKey``. Wrap it with the cores transforming the fields of the sink logs, i.e. the redaction.
+type flightBufferCore struct {
+	enc      zapcore.Encoder
+	recorder *flightRecorder
+}
+
+func newFlightBufferCore(enc zapcore.Encoder, recorder *flightRecorder) zapcore.Core {
+	return &flightBufferCore{enc: enc, recorder: recorder}
+}
+
+func (c *flightBufferCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *flightBufferCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(enc)
+	}
+	return &flightBufferCore{enc: enc, recorder: c.recorder}
+}
+
+func (c *flightBufferCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c *flightBufferCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	fields = append(fields[:len(fields):len(fields)], zap.Bool(FlightRecorderKey, true))
+
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	c.recorder.add(ent.LoggerName, flightEntry{time: ent.Time, log: append([]byte(nil), buf.Bytes()...)})
+	return nil
+}
+
+func (c *flightBufferCore) Sync() error {
+	return nil
+}
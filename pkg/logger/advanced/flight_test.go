@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// messages returns the messages of the specified logs, with a star when written by the flight recorder.
+func messages(logs []map[string]interface{}) []string {
+	msgs := make([]string, 0, len(logs))
+	for _, log := range logs {
+		msg, _ := log["msg"].(string)
+		if log[FlightRecorderKey] == true {
+			msg += "*"
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestFlightRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewProductionLoggerWithOptions(&buf, Options{
+		FlightRecorder: &FlightRecorderOptions{Level: zapcore.DebugLevel, Size: 2},
+		Redaction:      &RedactionPolicy{Keys: []string{"password"}},
+	})
+	users, orders := log.WithName("users"), log.WithName("orders")
+
+	// the buffered logs are encoded when logged
+	value := &mutableStringer{value: "logged"}
+	users.V(1).Info("Dropped by the ring buffer")
+	users.WithValues("user", "alice").V(1).Info("Authenticating", "value", value, "password", "secret")
+	value.value = "changed"
+	users.V(1).Info("Authenticated")
+	orders.V(1).Info("Listing the orders")
+	users.V(2).Info("Below the recorder level")
+	users.Info("Logged")
+
+	if got := messages(decodeLogs(t, &buf)); len(got) != 1 || got[0] != "Logged" {
+		t.Fatalf("got the logs %q before the error, want the info log only", got)
+	}
+	buf.Reset()
+
+	// the last buffered logs of the logger are written before its error
+	users.Error(nil, "Authentication failed")
+	logs := decodeLogs(t, &buf)
+	if got := messages(logs); len(got) != 3 || got[0] != "Authenticating*" || got[1] != "Authenticated*" || got[2] != "Authentication failed" {
+		t.Fatalf("got the logs %q, want the buffered logs of the logger then the error", got)
+	}
+	if logs[0]["level"] != "debug" || logs[0]["user"] != "alice" || logs[0]["value"] != "logged" || logs[0]["password"] == "secret" {
+		t.Errorf("the buffered log is %v, want the values when logged, redacted", logs[0])
+	}
+	buf.Reset()
+
+	// the buffer is written once
+	users.Error(nil, "Authentication failed")
+	if got := messages(decodeLogs(t, &buf)); len(got) != 1 {
+		t.Errorf("got the logs %q, want the error only", got)
+	}
+}
+
+func TestFlightRecorderBurst(t *testing.T) {
+	info := zapcore.InfoLevel
+
+	for _, tt := range []struct {
+		name       string
+		burstLevel *zapcore.Level
+		want       []string
+	}{
+		{"default", nil, []string{"Raised debug", "Raised info"}},
+		{"info", &info, []string{"Raised info"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := NewProductionLoggerWithOptions(nil, Options{
+				Sinks: []Sink{{
+					Writer: &buf,
+					Level:  zapcore.WarnLevel,
+					FlightRecorder: &FlightRecorderOptions{
+						Level:          zapcore.DebugLevel,
+						BurstThreshold: 2,
+						BurstLevel:     tt.burstLevel,
+						CoolDown:       100 * time.Millisecond,
+					},
+				}},
+			})
+
+			log.Error(nil, "First failure")
+			log.Error(nil, "Second failure")
+			buf.Reset()
+
+			// the verbosity is raised for the cool down duration after the burst
+			log.V(1).Info("Raised debug")
+			log.Info("Raised info")
+			if got := messages(decodeLogs(t, &buf)); len(got) != len(tt.want) || got[0] != tt.want[0] {
+				t.Errorf("got the logs %q while raised, want %q", got, tt.want)
+			}
+			buf.Reset()
+
+			time.Sleep(150 * time.Millisecond)
+			log.V(1).Info("Decayed debug")
+			log.Info("Decayed info")
+			if got := messages(decodeLogs(t, &buf)); len(got) != 0 {
+				t.Errorf("got the logs %q after the cool down, want none", got)
+			}
+		})
+	}
+}
+
+func TestFlightRecorderBurstMessage(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewProductionLoggerWithOptions(&buf, Options{
+		FlightRecorder: &FlightRecorderOptions{Level: zapcore.DebugLevel, BurstThreshold: 2},
+	})
+
+	log.Error(nil, "First failure")
+	log.Error(nil, "Second failure")
+	log.Error(nil, "Third failure")
+
+	logs := decodeLogs(t, &buf)
+	if got := messages(logs); len(got) != 4 || got[1] != flightRecorderBurstMessage || got[2] != "Second failure" {
+		t.Fatalf("got the logs %q, want the burst warning once, before the second failure", got)
+	}
+	if logs[1]["level"] != "warn" || logs[1]["burst_level"] != "debug" || logs[1]["cool_down"] != FlightRecorderDefaultCoolDown.Seconds() {
+		t.Errorf("the burst warning is %v", logs[1])
+	}
+}
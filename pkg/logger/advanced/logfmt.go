@@ -0,0 +1,223 @@
+package logger
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder is a zapcore.Encoder writing the logs as ``key=value`` pairs, see https://brandur.org/logfmt.
+//
+// Nested objects are flattened with dotted keys. Arrays and reflected values are written as quoted JSON.
+type logfmtEncoder struct {
+	cfg        *zapcore.EncoderConfig
+	buf        *buffer.Buffer
+	namespaces []string
+}
+
+func newLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{cfg: &cfg, buf: logfmtPool.Get()}
+}
+
+func (e *logfmtEncoder) addKey(key string) {
+	if e.buf.Len() > 0 {
+		e.buf.AppendByte(' ')
+	}
+
+	for _, namespace := range e.namespaces {
+		e.appendKey(namespace)
+		e.buf.AppendByte('.')
+	}
+
+	e.appendKey(key)
+	e.buf.AppendByte('=')
+}
+
+// appendKey writes a key, replacing the characters a logfmt key cannot hold.
+func (e *logfmtEncoder) appendKey(key string) {
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			r = '_'
+		}
+		e.buf.AppendString(string(r))
+	}
+}
+
+// appendString writes a value, quoting it when needed.
+func (e *logfmtEncoder) appendString(value string) {
+	if value == "" || strings.IndexFunc(value, needsLogfmtQuote) >= 0 {
+		e.buf.AppendString(strconv.Quote(value))
+		return
+	}
+	e.buf.AppendString(value)
+}
+
+func needsLogfmtQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f
+}
+
+func (e *logfmtEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	values := zapcore.NewMapObjectEncoder()
+	if err := values.AddArray(key, marshaler); err != nil {
+		return err
+	}
+	return e.AddReflected(key, values.Fields[key])
+}
+
+func (e *logfmtEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	nested := &logfmtEncoder{cfg: e.cfg, buf: e.buf, namespaces: append(e.namespaces[:len(e.namespaces):len(e.namespaces)], key)}
+	return marshaler.MarshalLogObject(nested)
+}
+
+func (e *logfmtEncoder) AddBinary(key string, value []byte) {
+	e.AddString(key, base64.StdEncoding.EncodeToString(value))
+}
+
+func (e *logfmtEncoder) AddByteString(key string, value []byte) { e.AddString(key, string(value)) }
+
+func (e *logfmtEncoder) AddBool(key string, value bool) {
+	e.addKey(key)
+	e.buf.AppendBool(value)
+}
+
+func (e *logfmtEncoder) AddComplex128(key string, value complex128) {
+	e.addKey(key)
+	e.appendString(strconv.FormatComplex(value, 'g', -1, 128))
+}
+
+func (e *logfmtEncoder) AddComplex64(key string, value complex64) {
+	e.addKey(key)
+	e.appendString(strconv.FormatComplex(complex128(value), 'g', -1, 64))
+}
+
+func (e *logfmtEncoder) AddDuration(key string, value time.Duration) {
+	e.AddString(key, value.String())
+}
+
+func (e *logfmtEncoder) AddFloat64(key string, value float64) { e.addFloat(key, value, 64) }
+func (e *logfmtEncoder) AddFloat32(key string, value float32) { e.addFloat(key, float64(value), 32) }
+
+func (e *logfmtEncoder) addFloat(key string, value float64, bitSize int) {
+	e.addKey(key)
+	switch {
+	case math.IsNaN(value):
+		e.buf.AppendString("NaN")
+	case math.IsInf(value, 1):
+		e.buf.AppendString("+Inf")
+	case math.IsInf(value, -1):
+		e.buf.AppendString("-Inf")
+	default:
+		e.buf.AppendFloat(value, bitSize)
+	}
+}
+
+func (e *logfmtEncoder) AddInt(key string, value int)     { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt32(key string, value int32) { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt16(key string, value int16) { e.AddInt64(key, int64(value)) }
+func (e *logfmtEncoder) AddInt8(key string, value int8)   { e.AddInt64(key, int64(value)) }
+
+func (e *logfmtEncoder) AddInt64(key string, value int64) {
+	e.addKey(key)
+	e.buf.AppendInt(value)
+}
+
+func (e *logfmtEncoder) AddString(key, value string) {
+	e.addKey(key)
+	e.appendString(value)
+}
+
+func (e *logfmtEncoder) AddTime(key string, value time.Time) {
+	e.AddString(key, value.Format(time.RFC3339Nano))
+}
+
+func (e *logfmtEncoder) AddUint(key string, value uint)       { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint32(key string, value uint32)   { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint16(key string, value uint16)   { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUint8(key string, value uint8)     { e.AddUint64(key, uint64(value)) }
+func (e *logfmtEncoder) AddUintptr(key string, value uintptr) { e.AddUint64(key, uint64(value)) }
+
+func (e *logfmtEncoder) AddUint64(key string, value uint64) {
+	e.addKey(key)
+	e.buf.AppendUint(value)
+}
+
+func (e *logfmtEncoder) AddReflected(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	// Strings are written as is rather than as quoted JSON.
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		e.AddString(key, s)
+		return nil
+	}
+
+	e.AddString(key, string(data))
+	return nil
+}
+
+func (e *logfmtEncoder) OpenNamespace(key string) {
+	e.namespaces = append(e.namespaces[:len(e.namespaces):len(e.namespaces)], key)
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get(), namespaces: e.namespaces}
+	_, _ = clone.buf.Write(e.buf.Bytes())
+	return clone
+}
+
+func (e *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := &logfmtEncoder{cfg: e.cfg, buf: logfmtPool.Get()}
+
+	if e.cfg.TimeKey != "" {
+		final.AddTime(e.cfg.TimeKey, ent.Time)
+	}
+
+	if e.cfg.LevelKey != "" {
+		final.AddString(e.cfg.LevelKey, ent.Level.String())
+	}
+
+	if e.cfg.NameKey != "" && ent.LoggerName != "" {
+		final.AddString(e.cfg.NameKey, ent.LoggerName)
+	}
+
+	if e.cfg.CallerKey != "" && ent.Caller.Defined {
+		final.AddString(e.cfg.CallerKey, ent.Caller.TrimmedPath())
+	}
+
+	if e.cfg.MessageKey != "" {
+		final.AddString(e.cfg.MessageKey, ent.Message)
+	}
+
+	if e.buf.Len() > 0 {
+		if final.buf.Len() > 0 {
+			final.buf.AppendByte(' ')
+		}
+		_, _ = final.buf.Write(e.buf.Bytes())
+	}
+
+	final.namespaces = e.namespaces
+	for i := range fields {
+		fields[i].AddTo(final)
+	}
+	final.namespaces = nil
+
+	if e.cfg.StacktraceKey != "" && ent.Stack != "" {
+		final.AddString(e.cfg.StacktraceKey, ent.Stack)
+	}
+
+	final.buf.AppendString(zapcore.DefaultLineEnding)
+
+	return final.buf, nil
+}
@@ -58,7 +58,8 @@ var (
 	//
 
 	// ProductionLoggerSamplerEnabled enables the production logger log sampler. For more information on logger sampler
-	// see https://godoc.org/go.uber.org/zap/zapcore#NewSampler.
+	// see https://godoc.org/go.uber.org/zap/zapcore#NewSampler. The production logger sampler globals are the defaults
+	// of ``Options.Sampling``.
 	ProductionLoggerSamplerEnabled = true
 	// ProductionLoggerSamplerPeriod defines the production logger sampling period. i.e.
 	// The period of time the sampling parameter are based on.
@@ -84,15 +85,6 @@ func simpleTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format("15:04:05"))
 }
 
-func productionLoggerSamplerCore(core zapcore.Core) zapcore.Core {
-	return zapcore.NewSamplerWithOptions(
-		core,
-		ProductionLoggerSamplerPeriod,
-		ProductionLoggerSamplerFirst,
-		ProductionLoggerSamplerThereAfter,
-	)
-}
-
 // NewProductionLogger creates a new logger to use within a cluster.
 //
 // A production logger is a JSON formatted logger with a default log level set to ``info``. It defines a log sampler :
@@ -101,41 +93,46 @@ func productionLoggerSamplerCore(core zapcore.Core) zapcore.Core {
 // each tick (``ProductionLoggerSamplerPeriod``). If more Entries with the same level and message are seen during
 // the same interval, every Mth message (``ProductionLoggerSamplerThereAfter``) is logged and the rest are dropped.
 //
-// You can deactivate the sampler with ``ProductionLoggerSamplerEnabled``
+// The sampler is configured through ``Options.Sampling`` and the ``USGO_LOG_SAMPLING*`` environment variables, the
+// ``ProductionLoggerSampler*`` globals define its defaults. Errors are never sampled.
 //
 // Example:
 //
 //    {"level":"info","ts":1542211325.6108115,"logger":"sample.server","msg":"Starting the HTTP server","endpoint":":8080","url":"http://MTL-BH846:8080"}
 func NewProductionLogger(destWriter io.Writer) logr.Logger {
-	sink := zapcore.AddSync(destWriter)
-
-	encCfg := zap.NewProductionEncoderConfig()
+	return NewProductionLoggerWithOptions(destWriter, Options{})
+}
 
-	// Production logger doesn't use the EncodeTime methods as the Development logger. To disable the
-	// time within log we have to clear the TimeKey.
-	if DisableLogTime {
-		encCfg.TimeKey = ""
-	}
+// NewProductionLoggerWithOptions creates a new production logger configured with the specified options. See
+// ``NewProductionLogger``.
+func NewProductionLoggerWithOptions(destWriter io.Writer, options Options) logr.Logger {
+	setOptionsDefaults(&options)
 
 	// Production logger use JSON format
-	enc := zapcore.NewJSONEncoder(encCfg)
+	sink := Sink{
+		Writer:         destWriter,
+		Encoding:       JSONEncoding,
+		Level:          getLoggerLevel(ProductionLoggerDefaultLevel),
+		Async:          options.Async,
+		FlightRecorder: options.FlightRecorder,
+	}
 
-	options := []zap.Option{
-		zap.AddStacktrace(LoggerStackTraceLevel),
-		zap.AddCallerSkip(1),
-		zap.ErrorOutput(sink),
+	if options.Sampling != nil {
+		sink.Sampling = samplingOptionsFromEnvironment(options.Sampling)
+	} else {
+		sink.Sampling = samplingOptionsFromEnvironment(productionLoggerSamplingOptions())
 	}
 
-	if ProductionLoggerSamplerEnabled {
-		options = append(options, zap.WrapCore(productionLoggerSamplerCore))
+	if options.Encoding != "" {
+		sink.Encoding = options.Encoding
 	}
 
-	core := zapcore.NewCore(
-		enc,
-		sink,
-		getLoggerLevel(ProductionLoggerDefaultLevel),
+	log := zap.New(
+		newTeeCore(sink, options),
+		zap.AddStacktrace(LoggerStackTraceLevel),
+		zap.ErrorOutput(errorOutput(sink)),
+		zap.WithCaller(needsCaller(sink, options)),
 	)
-	log := zap.New(core, options...)
 
 	// return ToMonitoredLogger(zapr.NewLogger(log))
 	return zapr.NewLogger(log)
@@ -149,20 +146,34 @@ func NewProductionLogger(destWriter io.Writer) logr.Logger {
 //
 //    10:59:13        INFO    sample.server   Starting the HTTP server        {"endpoint": ":8080", "url": "http://MTL-BH846:8080"}
 func NewDevelopmentLogger(destWriter io.Writer) logr.Logger {
-	sink := zapcore.AddSync(destWriter)
+	return NewDevelopmentLoggerWithOptions(destWriter, Options{})
+}
 
-	encCfg := zap.NewDevelopmentEncoderConfig()
-	encCfg.EncodeTime = makeTimeEncoder(simpleTimeEncoder)
+// NewDevelopmentLoggerWithOptions creates a new development logger configured with the specified options. See
+// ``NewDevelopmentLogger``.
+func NewDevelopmentLoggerWithOptions(destWriter io.Writer, options Options) logr.Logger {
+	setOptionsDefaults(&options)
 
 	// Development logger use Console encoder
-	enc := zapcore.NewConsoleEncoder(encCfg)
+	sink := Sink{
+		Writer:         destWriter,
+		Encoding:       ConsoleEncoding,
+		Level:          getLoggerLevel(DevelopmentLoggerDefaultLevel),
+		Sampling:       samplingOptionsFromEnvironment(options.Sampling),
+		Async:          options.Async,
+		FlightRecorder: options.FlightRecorder,
+	}
+
+	if options.Encoding != "" {
+		sink.Encoding = options.Encoding
+	}
 
 	log := zap.New(
-		zapcore.NewCore(enc, sink, getLoggerLevel(DevelopmentLoggerDefaultLevel)),
+		newTeeCore(sink, options),
 		zap.Development(),
 		zap.AddStacktrace(LoggerStackTraceLevel),
-		zap.AddCallerSkip(1),
-		zap.ErrorOutput(sink),
+		zap.ErrorOutput(errorOutput(sink)),
+		zap.WithCaller(needsCaller(sink, options)),
 	)
 
 	//	return ToMonitoredLogger(zapr.NewLogger(log))
@@ -16,6 +16,12 @@ const (
 var (
 	// Counts the number of error logs.
 	mLogs = stats.Int64("micro-server/logs", "The number of logs encountered", "1")
+	// Counts the number of logs dropped by the samplers.
+	mSampledOutLogs = stats.Int64("micro-server/logs_sampled_out", "The number of logs dropped by the samplers", "1")
+	// Measures the number of logs queued by the asynchronous sinks.
+	mAsyncQueueDepth = stats.Int64("micro-server/logs_async_queue_depth", "The number of logs queued by the asynchronous sinks", "1")
+	// Counts the number of logs dropped by the asynchronous sinks on overflow.
+	mAsyncDroppedLogs = stats.Int64("micro-server/logs_async_dropped", "The number of logs dropped by the asynchronous sinks", "1")
 
 	nameKey, _  = tag.NewKey(NameLogTagKeyName)
 	levelKey, _ = tag.NewKey(LevelLogTagKeyName)
@@ -28,4 +34,30 @@ var (
 		Aggregation: view.Count(),
 		TagKeys:     []tag.Key{nameKey, levelKey},
 	}
+
+	// LogSampledOutCountView is the number of logs dropped by the samplers view. It has 2 tags, ``level`` and
+	// ``logger_name``.
+	LogSampledOutCountView = &view.View{
+		Name:        "micro-server/logs_sampled_out",
+		Measure:     mSampledOutLogs,
+		Description: "The number of logs dropped by the samplers",
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{nameKey, levelKey},
+	}
+
+	// LogAsyncQueueDepthView is the number of logs queued by the asynchronous sinks view.
+	LogAsyncQueueDepthView = &view.View{
+		Name:        "micro-server/logs_async_queue_depth",
+		Measure:     mAsyncQueueDepth,
+		Description: "The number of logs queued by the asynchronous sinks",
+		Aggregation: view.LastValue(),
+	}
+
+	// LogAsyncDroppedCountView is the number of logs dropped by the asynchronous sinks view.
+	LogAsyncDroppedCountView = &view.View{
+		Name:        "micro-server/logs_async_dropped",
+		Measure:     mAsyncDroppedLogs,
+		Description: "The number of logs dropped by the asynchronous sinks",
+		Aggregation: view.Count(),
+	}
 )
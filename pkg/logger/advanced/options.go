@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Options represents the logger configuration options.
+type Options struct {
+	// Redaction defines which values are masked before being encoded. When nil, the values are logged as they are
+	// unless ``USGO_LOG_REDACTION`` is set to ``enabled``, or ``USGO_LOG_REDACT_KEYS`` or ``USGO_LOG_REDACT_VALUES``
+	// is set, in which case ``DefaultRedactionPolicy`` is used. The policy is always completed by these last two
+	// environment variables, and disabled altogether when ``USGO_LOG_REDACTION`` is set to ``disabled``.
+	Redaction *RedactionPolicy
+	// Sinks lists additional destinations of the logs. Each sink has its own encoding, minimum level and sampling.
+	// The logger destination writer can be nil when the logs only go to these sinks.
+	Sinks []Sink
+	// Encoding defines the format of the logs written to the logger destination writer, and to the sinks that do
+	// not define their own. It defaults to the ``USGO_LOG_ENCODING`` environment variable, or to the logger
+	// encoding: JSON for the production logger and console for the development logger. An unknown encoding is
+	// reported on the standard error and replaced by the logger encoding.
+	Encoding Encoding
+	// Sampling defines how the logs written to the logger destination writer are sampled. It defaults to the
+	// ``ProductionLoggerSampler*`` globals for the production logger, the development logger does not sample its logs.
+	// It is always completed by the ``USGO_LOG_SAMPLING*`` environment variables.
+	Sampling *SamplingOptions
+	// Async enables the asynchronous writing of the logs to the logger destination writer, see ``AsyncOptions``.
+	// The logs are written synchronously when nil.
+	Async *AsyncOptions
+	// FlightRecorder buffers the logs below the logger level and writes them when an error is logged, and raises the
+	// verbosity on error bursts, see ``FlightRecorderOptions``. It applies to every sink that does not define its own.
+	FlightRecorder *FlightRecorderOptions
+	// RecentLogs keeps the last logs in memory, at the level of the logger, in order to serve them over HTTP. See
+	// ``RecentLogs`` and ``routes.AddRecentLogs``.
+	RecentLogs *RecentLogs
+}
+
+func setOptionsDefaults(options *Options) {
+	if options != nil {
+		options.Redaction = redactionPolicyFromEnvironment(options.Redaction)
+
+		if options.Encoding == "" {
+			options.Encoding = Encoding(strings.ToLower(os.Getenv(EncodingEnvironmentVariable)))
+		}
+
+		if options.Encoding != "" {
+			if _, err := newEncoder(options.Encoding); err != nil {
+				fmt.Fprintf(os.Stderr, "Ignoring invalid log encoding: %v\n", err)
+				options.Encoding = ""
+			}
+		}
+	}
+}
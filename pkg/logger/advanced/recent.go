@@ -0,0 +1,362 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// RecentLogsDefaultSize defines the default number of logs kept by ``RecentLogs``.
+	RecentLogsDefaultSize = 1000
+
+	// recentLogsSubscriberBufferSize defines the number of logs buffered for a tail subscriber, the logs are dropped
+	// for the subscriber when it is full.
+	recentLogsSubscriberBufferSize = 256
+	// recentLogsKeepAliveInterval defines the interval between two keep alive comments of a tail stream.
+	recentLogsKeepAliveInterval = 15 * time.Second
+)
+
+// RecentEntry is a log kept by ``RecentLogs``.
+type RecentEntry struct {
+	Time       time.Time              `json:"ts"`
+	Level      zapcore.Level          `json:"level"`
+	Logger     string                 `json:"logger,omitempty"`
+	Caller     string                 `json:"caller,omitempty"`
+	Message    string                 `json:"msg"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+	Stacktrace string                 `json:"stacktrace,omitempty"`
+
+	// encoded is the JSON encoding of the entry, used to serve and search the entry.
+	encoded []byte
+}
+
+// RecentLogsFilter selects the logs returned by ``RecentLogs``.
+type RecentLogsFilter struct {
+	// Level defines the minimum level of the logs.
+	Level zapcore.Level
+	// Logger selects the logs of the loggers whose name starts with it.
+	Logger string
+	// Contains selects the logs containing it, in their message or fields.
+	Contains string
+}
+
+func (f RecentLogsFilter) match(entry *RecentEntry) bool {
+	if entry.Level < f.Level {
+		return false
+	}
+
+	if f.Logger != "" && !strings.HasPrefix(entry.Logger, f.Logger) {
+		return false
+	}
+
+	if f.Contains != "" && !strings.Contains(string(entry.encoded), f.Contains) {
+		return false
+	}
+
+	return true
+}
+
+// RecentLogs keeps the last logs of a logger in memory, see ``Options.RecentLogs``. It serves them over HTTP:
+//
+// - ``ServeHTTP`` returns the kept logs as a JSON array
+//
+// - ``TailHandler`` streams the new logs as Server-Sent Events
+//
+// Both handlers accept the ``level``, ``logger`` and ``q`` query parameters to filter the logs by minimum level,
+// logger name prefix and substring. ``ServeHTTP`` also accepts ``limit`` to return only the most recent logs.
+//
+// The logs are redacted as the logs written to the sinks.
+type RecentLogs struct {
+	mu          sync.Mutex
+	entries     []*RecentEntry
+	next        int
+	full        bool
+	subscribers map[chan *RecentEntry]RecentLogsFilter
+}
+
+// NewRecentLogs creates an in-memory buffer keeping the specified number of logs. The size defaults to
+// ``RecentLogsDefaultSize``.
+func NewRecentLogs(size int) *RecentLogs {
+	if size <= 0 {
+		size = RecentLogsDefaultSize
+	}
+
+	return &RecentLogs{
+		entries:     make([]*RecentEntry, size),
+		subscribers: make(map[chan *RecentEntry]RecentLogsFilter),
+	}
+}
+
+func (r *RecentLogs) add(entry *RecentEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = entry
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+
+	for subscriber, filter := range r.subscribers {
+		if !filter.match(entry) {
+			continue
+		}
+
+		// Slow subscribers miss logs rather than slowing down the logger.
+		select {
+		case subscriber <- entry:
+		default:
+		}
+	}
+}
+
+// Entries returns the kept logs matching the filter, from the oldest to the most recent.
+func (r *RecentLogs) Entries(filter RecentLogsFilter) []RecentEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ordered := r.entries[:r.next]
+	if r.full {
+		ordered = append(append([]*RecentEntry(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+	}
+
+	entries := make([]RecentEntry, 0, len(ordered))
+	for _, entry := range ordered {
+		if filter.match(entry) {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries
+}
+
+// Subscribe returns a channel receiving the new logs matching the filter, and the function to call to stop the
+// subscription.
+func (r *RecentLogs) Subscribe(filter RecentLogsFilter) (<-chan RecentEntry, func()) {
+	subscriber := make(chan *RecentEntry, recentLogsSubscriberBufferSize)
+	entries := make(chan RecentEntry)
+	done := make(chan struct{})
+
+	r.mu.Lock()
+	r.subscribers[subscriber] = filter
+	r.mu.Unlock()
+
+	go func() {
+		defer close(entries)
+		for {
+			select {
+			case entry := <-subscriber:
+				select {
+				case entries <- *entry:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return entries, func() {
+		once.Do(func() {
+			r.mu.Lock()
+			delete(r.subscribers, subscriber)
+			r.mu.Unlock()
+			close(done)
+		})
+	}
+}
+
+// ServeHTTP returns the kept logs matching the query filters as a JSON array.
+func (r *RecentLogs) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	filter, err := parseRecentLogsFilter(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries := r.Entries(filter)
+
+	if v := req.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", v), http.StatusBadRequest)
+			return
+		}
+
+		if limit < len(entries) {
+			entries = entries[len(entries)-limit:]
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, _ = w.Write([]byte{'['})
+	for i, entry := range entries {
+		if i > 0 {
+			_, _ = w.Write([]byte{','})
+		}
+		_, _ = w.Write(entry.encoded)
+	}
+	_, _ = w.Write([]byte("]\n"))
+}
+
+// TailHandler returns the handler streaming the new logs matching the query filters as Server-Sent Events, one
+// ``log`` event per log.
+func (r *RecentLogs) TailHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		filter, err := parseRecentLogsFilter(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The response controller reaches the server response writer through the middleware writers, and lifts the
+		// server write timeout that would otherwise end the stream.
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		entries, unsubscribe := r.Subscribe(filter)
+		defer unsubscribe()
+
+		keepAlive := time.NewTicker(recentLogsKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case entry := <-entries:
+				if _, err := fmt.Fprintf(w, "event: log\ndata: %s\n\n", entry.encoded); err != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			case <-req.Context().Done():
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// parseRecentLogsFilter parses the ``level``, ``logger`` and ``q`` query parameters of the request.
+func parseRecentLogsFilter(req *http.Request) (RecentLogsFilter, error) {
+	query := req.URL.Query()
+
+	filter := RecentLogsFilter{
+		Level:    zapcore.Level(math.MinInt8),
+		Logger:   query.Get("logger"),
+		Contains: query.Get("q"),
+	}
+
+	if v := strings.ToLower(query.Get("level")); v != "" {
+		if v == "warning" {
+			v = "warn"
+		}
+
+		if err := filter.Level.UnmarshalText([]byte(v)); err != nil {
+			return filter, fmt.Errorf("invalid level %q", v)
+		}
+	}
+
+	return filter, nil
+}
+
+// recentCore is a zapcore.Core keeping the logs in a ``RecentLogs``.
+type recentCore struct {
+	zapcore.LevelEnabler
+	recent *RecentLogs
+	fields []zapcore.Field
+}
+
+func newRecentCore(recent *RecentLogs, level zapcore.LevelEnabler) zapcore.Core {
+	return &recentCore{LevelEnabler: level, recent: recent}
+}
+
+func (c *recentCore) With(fields []zapcore.Field) zapcore.Core {
+	return &recentCore{
+		LevelEnabler: c.LevelEnabler,
+		recent:       c.recent,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *recentCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *recentCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(enc)
+	}
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	entry := &RecentEntry{
+		Time:       ent.Time,
+		Level:      ent.Level,
+		Logger:     ent.LoggerName,
+		Message:    ent.Message,
+		Fields:     enc.Fields,
+		Stacktrace: ent.Stack,
+	}
+
+	if ent.Caller.Defined {
+		entry.Caller = ent.Caller.TrimmedPath()
+	}
+
+	if len(entry.Fields) == 0 {
+		entry.Fields = nil
+	}
+
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		// Keep the log with the fields JSON cannot encode formatted as strings.
+		for key, value := range entry.Fields {
+			if _, err := json.Marshal(value); err != nil {
+				entry.Fields[key] = fmt.Sprintf("%+v", value)
+			}
+		}
+
+		if encoded, err = json.Marshal(entry); err != nil {
+			return err
+		}
+	}
+	entry.encoded = encoded
+
+	c.recent.add(entry)
+	return nil
+}
+
+func (c *recentCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,449 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// RedactionEnvironmentVariable defines the name of the environment variable to set in order to enable or disable
+	// the redaction of sensitive values. Set it to ``enabled`` to apply ``DefaultRedactionPolicy`` when the options
+	// define no policy, or to ``disabled`` to log values as they are.
+	// The redaction is opt-in: the values are logged as they are unless a policy or one of these environment
+	// variables is set.
+	RedactionEnvironmentVariable = "USGO_LOG_REDACTION"
+	// RedactKeysEnvironmentVariable defines the name of the environment variable listing additional key patterns to
+	// redact. Patterns are separated by commas, i.e. ``USGO_LOG_REDACT_KEYS=session,*_pin``.
+	RedactKeysEnvironmentVariable = "USGO_LOG_REDACT_KEYS"
+	// RedactValuesEnvironmentVariable defines the name of the environment variable listing additional value regular
+	// expressions to redact. Expressions are separated by white spaces, use ``\s`` to match a space.
+	RedactValuesEnvironmentVariable = "USGO_LOG_REDACT_VALUES"
+
+	// RedactedValue is the mask used in place of a redacted value.
+	RedactedValue = "[REDACTED]"
+)
+
+var (
+	// DefaultRedactedKeys defines the key patterns redacted by default. Patterns use the ``path.Match`` syntax and
+	// are matched against lower cased keys.
+	DefaultRedactedKeys = []string{
+		"password",
+		"*_password",
+		"token",
+		"*_token",
+		"authorization",
+		"cookie",
+		"secret",
+		"*_secret",
+	}
+
+	// DefaultRedactedValues defines the value regular expressions redacted by default: bearer tokens, JSON Web
+	// Tokens and card numbers. The card numbers are only redacted when they pass the Luhn check, so that the
+	// timestamps and identifiers of the same length are kept.
+	DefaultRedactedValues = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`),
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		cardNumberPattern,
+	}
+
+	// cardNumberPattern matches the candidate card numbers, of 13 to 19 digits with optional separators.
+	cardNumberPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+)
+
+// Redacted wraps a value that must never reach the logs. Whatever the redaction policy, it is always encoded as
+// ``RedactedValue``, including when formatted with the ``fmt`` package.
+//
+// Example:
+//
+//    log.Info("User logged in", "user", name, "password", logs.Redact(password))
+type Redacted struct {
+	value interface{}
+}
+
+// Redact wraps the specified value so that it is masked when logged.
+func Redact(value interface{}) Redacted { return Redacted{value: value} }
+
+// String implements fmt.Stringer.
+func (Redacted) String() string { return RedactedValue }
+
+// GoString implements fmt.GoStringer.
+func (Redacted) GoString() string { return RedactedValue }
+
+// Format implements fmt.Formatter.
+func (Redacted) Format(f fmt.State, _ rune) { _, _ = f.Write([]byte(RedactedValue)) }
+
+// MarshalJSON implements json.Marshaler.
+func (Redacted) MarshalJSON() ([]byte, error) { return []byte(`"` + RedactedValue + `"`), nil }
+
+// RedactionPolicy defines which logged values are masked before being encoded.
+//
+// The policy applies to the keys and values of the fields, to the nested objects and arrays implementing the zap
+// marshaler interfaces and to the maps of strings such as http.Header. The other values logged through reflection,
+// i.e. structs, are encoded as they are: implement ``zapcore.ObjectMarshaler`` or wrap their sensitive fields with
+// ``Redact`` to mask them.
+type RedactionPolicy struct {
+	// Keys lists the patterns of the keys whose values are always masked. Patterns use the ``path.Match`` syntax
+	// and are matched against lower cased keys.
+	Keys []string
+	// Values lists the regular expressions matched against string values. Matching parts are masked.
+	Values []*regexp.Regexp
+	// Mask replaces the redacted values. It defaults to ``RedactedValue``.
+	Mask string
+}
+
+// DefaultRedactionPolicy returns a new policy made of ``DefaultRedactedKeys`` and ``DefaultRedactedValues``.
+func DefaultRedactionPolicy() *RedactionPolicy {
+	return &RedactionPolicy{
+		Keys:   append([]string(nil), DefaultRedactedKeys...),
+		Values: append([]*regexp.Regexp(nil), DefaultRedactedValues...),
+	}
+}
+
+// redactionPolicyFromEnvironment completes the specified policy with the environment configuration. It returns nil
+// when the redaction is disabled, or when neither the policy nor the environment enables it.
+func redactionPolicyFromEnvironment(policy *RedactionPolicy) *RedactionPolicy {
+	keys, values := os.Getenv(RedactKeysEnvironmentVariable), os.Getenv(RedactValuesEnvironmentVariable)
+
+	switch strings.ToLower(os.Getenv(RedactionEnvironmentVariable)) {
+	case "disabled":
+		return nil
+	case "enabled":
+	default:
+		if policy == nil && keys == "" && values == "" {
+			return nil
+		}
+	}
+
+	if policy == nil {
+		policy = DefaultRedactionPolicy()
+	} else {
+		copied := *policy
+		policy = &copied
+	}
+
+	for _, key := range strings.Split(keys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			policy.Keys = append(policy.Keys, key)
+		}
+	}
+
+	for _, expr := range strings.Fields(values) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring invalid %s expression %q: %v\n", RedactValuesEnvironmentVariable, expr, err)
+			continue
+		}
+		policy.Values = append(policy.Values, re)
+	}
+
+	return policy
+}
+
+func (p *RedactionPolicy) mask() string {
+	if p.Mask == "" {
+		return RedactedValue
+	}
+	return p.Mask
+}
+
+func (p *RedactionPolicy) matchKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range p.Keys {
+		if matched, _ := path.Match(strings.ToLower(pattern), key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString masks the parts of the specified value matching the policy expressions. It returns whether the
+// value was changed.
+func (p *RedactionPolicy) redactString(value string) (string, bool) {
+	redacted := value
+	for _, re := range p.Values {
+		if re == cardNumberPattern {
+			redacted = re.ReplaceAllStringFunc(redacted, func(match string) string {
+				if luhnValid(match) {
+					return p.mask()
+				}
+				return match
+			})
+			continue
+		}
+		redacted = re.ReplaceAllLiteralString(redacted, p.mask())
+	}
+	return redacted, redacted != value
+}
+
+// luhnValid returns true when the digits of the specified number pass the Luhn checksum of the card numbers, the
+// other characters being ignored.
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+
+		digit := int(c - '0')
+		if double {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// redactField returns the specified field with its sensitive values masked.
+func (p *RedactionPolicy) redactField(f zapcore.Field) zapcore.Field {
+	switch f.Type {
+	case zapcore.NamespaceType, zapcore.SkipType:
+		return f
+	}
+
+	if p.matchKey(f.Key) {
+		return zap.String(f.Key, p.mask())
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		f.String, _ = p.redactString(f.String)
+	case zapcore.ByteStringType:
+		if s, ok := p.redactString(string(f.Interface.([]byte))); ok {
+			return zap.String(f.Key, s)
+		}
+	case zapcore.StringerType:
+		if _, ok := f.Interface.(Redacted); ok {
+			return zap.String(f.Key, p.mask())
+		}
+		if s, ok := p.redactString(safeString(f.Interface.(fmt.Stringer))); ok {
+			return zap.String(f.Key, s)
+		}
+	case zapcore.ErrorType:
+		if err, _ := f.Interface.(error); err != nil {
+			if s, ok := p.redactString(safeString(err)); ok {
+				return zap.String(f.Key, s)
+			}
+		}
+	case zapcore.ObjectMarshalerType:
+		f.Interface = redactingObject{marshaler: f.Interface.(zapcore.ObjectMarshaler), policy: p}
+	case zapcore.ArrayMarshalerType:
+		f.Interface = redactingArray{marshaler: f.Interface.(zapcore.ArrayMarshaler), policy: p}
+	case zapcore.ReflectType:
+		if _, ok := f.Interface.(Redacted); ok {
+			return zap.String(f.Key, p.mask())
+		}
+		if v := reflect.ValueOf(f.Interface); v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+			return zap.Object(f.Key, redactingMap{value: v, policy: p})
+		}
+	}
+
+	return f
+}
+
+func (p *RedactionPolicy) redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i := range fields {
+		redacted[i] = p.redactField(fields[i])
+	}
+	return redacted
+}
+
+// safeString calls the String or Error method of the specified value, recovering from panics raised by nil
+// receivers.
+func safeString(v interface{}) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			s = "<nil>"
+		}
+	}()
+
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return ""
+}
+
+// redactingCore is a zapcore.Core masking the sensitive values of the fields before they reach the encoder.
+type redactingCore struct {
+	zapcore.Core
+	policy *RedactionPolicy
+}
+
+// newRedactingCore wraps the specified core with the redaction policy. The core is returned as is when the policy
+// is nil.
+func newRedactingCore(core zapcore.Core, policy *RedactionPolicy) zapcore.Core {
+	if policy == nil {
+		return core
+	}
+	return &redactingCore{Core: core, policy: policy}
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(c.policy.redactFields(fields)), policy: c.policy}
+}
+
+func (c *redactingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.policy.redactFields(fields))
+}
+
+// redactingObject masks the sensitive values of a nested object.
+type redactingObject struct {
+	marshaler zapcore.ObjectMarshaler
+	policy    *RedactionPolicy
+}
+
+func (o redactingObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.marshaler.MarshalLogObject(&redactingObjectEncoder{ObjectEncoder: enc, policy: o.policy})
+}
+
+// redactingArray masks the sensitive values of a nested array.
+type redactingArray struct {
+	marshaler zapcore.ArrayMarshaler
+	policy    *RedactionPolicy
+}
+
+func (a redactingArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	return a.marshaler.MarshalLogArray(&redactingArrayEncoder{ArrayEncoder: enc, policy: a.policy})
+}
+
+// redactingMap masks the sensitive values of a map logged through reflection, such as a http.Header.
+type redactingMap struct {
+	value  reflect.Value
+	policy *RedactionPolicy
+}
+
+func (m redactingMap) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc = &redactingObjectEncoder{ObjectEncoder: enc, policy: m.policy}
+
+	iter := m.value.MapRange()
+	for iter.Next() {
+		key, value := iter.Key().String(), iter.Value()
+
+		switch {
+		case value.Kind() == reflect.String:
+			enc.AddString(key, value.String())
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+			if err := enc.AddArray(key, redactingStrings{value: value}); err != nil {
+				return err
+			}
+		default:
+			if err := enc.AddReflected(key, value.Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// redactingStrings encodes a slice of strings, relying on the array encoder to mask the values.
+type redactingStrings struct {
+	value reflect.Value
+}
+
+func (s redactingStrings) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for i := 0; i < s.value.Len(); i++ {
+		enc.AppendString(s.value.Index(i).String())
+	}
+	return nil
+}
+
+// redactingObjectEncoder is a zapcore.ObjectEncoder masking the sensitive string values of nested objects.
+type redactingObjectEncoder struct {
+	zapcore.ObjectEncoder
+	policy *RedactionPolicy
+}
+
+func (e *redactingObjectEncoder) AddString(key, value string) {
+	if e.policy.matchKey(key) {
+		e.ObjectEncoder.AddString(key, e.policy.mask())
+		return
+	}
+	value, _ = e.policy.redactString(value)
+	e.ObjectEncoder.AddString(key, value)
+}
+
+func (e *redactingObjectEncoder) AddByteString(key string, value []byte) {
+	e.AddString(key, string(value))
+}
+
+func (e *redactingObjectEncoder) AddBinary(key string, value []byte) {
+	if e.policy.matchKey(key) {
+		e.ObjectEncoder.AddString(key, e.policy.mask())
+		return
+	}
+	e.ObjectEncoder.AddBinary(key, value)
+}
+
+func (e *redactingObjectEncoder) AddReflected(key string, value interface{}) error {
+	f := e.policy.redactField(zap.Reflect(key, value))
+	f.AddTo(e.ObjectEncoder)
+	return nil
+}
+
+func (e *redactingObjectEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	if e.policy.matchKey(key) {
+		e.ObjectEncoder.AddString(key, e.policy.mask())
+		return nil
+	}
+	return e.ObjectEncoder.AddObject(key, redactingObject{marshaler: marshaler, policy: e.policy})
+}
+
+func (e *redactingObjectEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	if e.policy.matchKey(key) {
+		e.ObjectEncoder.AddString(key, e.policy.mask())
+		return nil
+	}
+	return e.ObjectEncoder.AddArray(key, redactingArray{marshaler: marshaler, policy: e.policy})
+}
+
+// redactingArrayEncoder is a zapcore.ArrayEncoder masking the sensitive string values of nested arrays.
+type redactingArrayEncoder struct {
+	zapcore.ArrayEncoder
+	policy *RedactionPolicy
+}
+
+func (e *redactingArrayEncoder) AppendString(value string) {
+	value, _ = e.policy.redactString(value)
+	e.ArrayEncoder.AppendString(value)
+}
+
+func (e *redactingArrayEncoder) AppendByteString(value []byte) {
+	e.AppendString(string(value))
+}
+
+func (e *redactingArrayEncoder) AppendReflected(value interface{}) error {
+	if _, ok := value.(Redacted); ok {
+		e.ArrayEncoder.AppendString(e.policy.mask())
+		return nil
+	}
+	return e.ArrayEncoder.AppendReflected(value)
+}
+
+func (e *redactingArrayEncoder) AppendObject(marshaler zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(redactingObject{marshaler: marshaler, policy: e.policy})
+}
+
+func (e *redactingArrayEncoder) AppendArray(marshaler zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(redactingArray{marshaler: marshaler, policy: e.policy})
+}
@@ -0,0 +1,432 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// SamplingEnvironmentVariable defines the name of the environment variable to set in order to enable or
+	// disable the log sampling. The available values are ``enabled`` and ``disabled``.
+	SamplingEnvironmentVariable = "USGO_LOG_SAMPLING"
+	// SamplingPeriodEnvironmentVariable defines the name of the environment variable overriding the sampling
+	// period, i.e. ``USGO_LOG_SAMPLING_PERIOD=5s``.
+	SamplingPeriodEnvironmentVariable = "USGO_LOG_SAMPLING_PERIOD"
+	// SamplingFirstEnvironmentVariable defines the name of the environment variable overriding the number of logs
+	// taken during the sampling period before the sampler starts to sample logs.
+	SamplingFirstEnvironmentVariable = "USGO_LOG_SAMPLING_FIRST"
+	// SamplingThereAfterEnvironmentVariable defines the name of the environment variable overriding the sampling
+	// rate once the first logs are taken.
+	SamplingThereAfterEnvironmentVariable = "USGO_LOG_SAMPLING_THEREAFTER"
+
+	// SamplingDefaultSummaryInterval defines the default interval between two summaries of the dropped logs.
+	SamplingDefaultSummaryInterval = time.Minute
+
+	samplingSummaryMessage = "Dropped sampled logs"
+)
+
+// SamplingPolicy defines how the logs of a given level are sampled.
+type SamplingPolicy struct {
+	// Disabled disables the sampling of the level: all its logs are kept.
+	Disabled bool
+	// First defines the number of logs with a given message that are taken during the sampling period before the
+	// sampler starts to sample logs.
+	First int
+	// ThereAfter defines that every Mth log is taken once the number of logs defined by First is reached for the
+	// remaining of the sampling period. No log is taken once First is reached when 0.
+	ThereAfter int
+}
+
+// SamplingOptions defines how a sink samples its logs. For more information on logger sampler see
+// https://godoc.org/go.uber.org/zap/zapcore#NewSampler.
+//
+// The logs dropped by the sampler are counted by ``LogSampledOutCountView`` and summarized by a log every summary
+// interval, written by a background routine running while logs are dropped. ``Close`` writes the last summaries.
+type SamplingOptions struct {
+	// Disabled disables the sampling altogether.
+	Disabled bool
+	// Period defines the period of time the sampling parameters are based on.
+	Period time.Duration
+	// First defines the number of logs with a given level and message that are taken during the sampling period
+	// before the sampler starts to sample logs.
+	First int
+	// ThereAfter defines that every Mth log is taken once the number of logs defined by First is reached for the
+	// remaining of the sampling period. No log is taken once First is reached when 0.
+	ThereAfter int
+	// Levels overrides the sampling policy of some levels. It defaults to ``DefaultSamplingLevels``.
+	Levels map[zapcore.Level]SamplingPolicy
+	// SummaryInterval defines the interval between two summaries of the dropped logs, no summary is written when no
+	// log is dropped. It defaults to ``SamplingDefaultSummaryInterval``.
+	SummaryInterval time.Duration
+}
+
+// DefaultSamplingLevels defines the default per level sampling policies: errors are never sampled.
+var DefaultSamplingLevels = map[zapcore.Level]SamplingPolicy{
+	zapcore.ErrorLevel:  {Disabled: true},
+	zapcore.DPanicLevel: {Disabled: true},
+	zapcore.PanicLevel:  {Disabled: true},
+	zapcore.FatalLevel:  {Disabled: true},
+}
+
+func setSamplingOptionsDefaults(options *SamplingOptions) {
+	if options.Levels == nil {
+		options.Levels = DefaultSamplingLevels
+	}
+
+	if options.SummaryInterval == 0 {
+		options.SummaryInterval = SamplingDefaultSummaryInterval
+	}
+}
+
+// productionLoggerSamplingOptions returns the sampling options defined by the production logger globals.
+func productionLoggerSamplingOptions() *SamplingOptions {
+	return &SamplingOptions{
+		Disabled:   !ProductionLoggerSamplerEnabled,
+		Period:     ProductionLoggerSamplerPeriod,
+		First:      ProductionLoggerSamplerFirst,
+		ThereAfter: ProductionLoggerSamplerThereAfter,
+	}
+}
+
+// samplingOptionsFromEnvironment completes the specified sampling options with the environment configuration.
+// When options is nil, the logger does not sample its logs unless the environment enables the sampling. It returns
+// nil when the sampling is disabled.
+func samplingOptionsFromEnvironment(options *SamplingOptions) *SamplingOptions {
+	if options == nil {
+		options = productionLoggerSamplingOptions()
+		options.Disabled = true
+	} else {
+		copied := *options
+		options = &copied
+	}
+
+	switch strings.ToLower(os.Getenv(SamplingEnvironmentVariable)) {
+	case "enabled":
+		options.Disabled = false
+	case "disabled":
+		options.Disabled = true
+	}
+
+	if options.Disabled {
+		return nil
+	}
+
+	if v := os.Getenv(SamplingPeriodEnvironmentVariable); v != "" {
+		if period, err := time.ParseDuration(v); err == nil {
+			options.Period = period
+		} else {
+			fmt.Fprintf(os.Stderr, "Ignoring invalid %s value %q: %v\n", SamplingPeriodEnvironmentVariable, v, err)
+		}
+	}
+
+	for name, value := range map[string]*int{
+		SamplingFirstEnvironmentVariable:      &options.First,
+		SamplingThereAfterEnvironmentVariable: &options.ThereAfter,
+	} {
+		if v := os.Getenv(name); v != "" {
+			if n, err := strconv.Atoi(v); err == nil {
+				*value = n
+			} else {
+				fmt.Fprintf(os.Stderr, "Ignoring invalid %s value %q: %v\n", name, v, err)
+			}
+		}
+	}
+
+	return options
+}
+
+// samplingCore is a zapcore.Core sampling the logs with a policy per level and accounting for the dropped logs.
+type samplingCore struct {
+	core zapcore.Core
+	// samplers holds the sampler of the levels with their own policy, nil when the sampling of the level is
+	// disabled. The samplers count the logs by level and message only, they are shared by the children cores.
+	samplers map[zapcore.Level]zapcore.Core
+	// fallback is the sampler of the other levels.
+	fallback zapcore.Core
+	stats    *samplingStats
+}
+
+// newSamplingCore wraps the specified core with the sampling options.
+func newSamplingCore(core zapcore.Core, options SamplingOptions) zapcore.Core {
+	setSamplingOptionsDefaults(&options)
+
+	stats := newSamplingStats(core, options.SummaryInterval)
+
+	newSampler := func(first, thereAfter int) zapcore.Core {
+		// The zap sampler takes every Mth log, a rate of 0 takes no log once the first logs are taken.
+		if thereAfter <= 0 {
+			thereAfter = math.MaxInt32
+		}
+		return zapcore.NewSamplerWithOptions(sampledCore{}, options.Period, first, thereAfter, zapcore.SamplerHook(stats.hook))
+	}
+
+	c := &samplingCore{
+		core:     core,
+		samplers: make(map[zapcore.Level]zapcore.Core, len(options.Levels)),
+		fallback: newSampler(options.First, options.ThereAfter),
+		stats:    stats,
+	}
+
+	for level, policy := range options.Levels {
+		if policy.Disabled {
+			c.samplers[level] = nil
+		} else {
+			c.samplers[level] = newSampler(policy.First, policy.ThereAfter)
+		}
+	}
+
+	return c
+}
+
+// sampler returns the sampler of the specified level, nil when its logs are not sampled.
+func (c *samplingCore) sampler(level zapcore.Level) zapcore.Core {
+	// The zap samplers only count the logs from the debug level, the verbosity levels below are not sampled.
+	if level < zapcore.DebugLevel {
+		return nil
+	}
+
+	if sampler, ok := c.samplers[level]; ok {
+		return sampler
+	}
+	return c.fallback
+}
+
+func (c *samplingCore) Enabled(level zapcore.Level) bool {
+	return c.core.Enabled(level)
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &samplingCore{
+		core:     c.core.With(fields),
+		samplers: c.samplers,
+		fallback: c.fallback,
+		stats:    c.stats,
+	}
+}
+
+func (c *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	// The disabled logs are not counted by the samplers.
+	if !c.core.Enabled(ent.Level) {
+		return ce
+	}
+	if sampler := c.sampler(ent.Level); sampler != nil && sampler.Check(ent, nil) == nil {
+		return ce
+	}
+	return c.core.Check(ent, ce)
+}
+
+func (c *samplingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.core.Write(ent, fields)
+}
+
+func (c *samplingCore) Sync() error {
+	c.stats.summarize()
+	return c.core.Sync()
+}
+
+// sampledEntry is returned by sampledCore for the logs taken by the samplers. It is never written.
+var sampledEntry = &zapcore.CheckedEntry{}
+
+// sampledCore is the core wrapped by the zap samplers: it tells the sampling core that a log is taken without
+// allocating a checked entry.
+type sampledCore struct{}
+
+func (sampledCore) Enabled(zapcore.Level) bool { return true }
+
+func (c sampledCore) With([]zapcore.Field) zapcore.Core { return c }
+
+func (sampledCore) Check(zapcore.Entry, *zapcore.CheckedEntry) *zapcore.CheckedEntry { return sampledEntry }
+
+func (sampledCore) Write(zapcore.Entry, []zapcore.Field) error { return nil }
+
+func (sampledCore) Sync() error { return nil }
+
+var (
+	samplingStatsMu sync.Mutex
+	// samplingStatsSet holds the stats whose background routine is running.
+	samplingStatsSet = map[*samplingStats]struct{}{}
+)
+
+// stopSamplingSummaries writes the last summaries of the dropped logs and stops the background routines writing
+// them.
+func stopSamplingSummaries() {
+	samplingStatsMu.Lock()
+	set := samplingStatsSet
+	samplingStatsSet = map[*samplingStats]struct{}{}
+	samplingStatsMu.Unlock()
+
+	for s := range set {
+		s.stop()
+	}
+}
+
+// samplingStats accounts for the logs dropped by the samplers of a sink. A background routine summarizes them every
+// interval: it is started by the first dropped log and stops once an interval passes without dropped logs, so that
+// the loggers dropping no log run no routine.
+type samplingStats struct {
+	// core is the unsampled sink core the summaries are written to.
+	core     zapcore.Core
+	interval time.Duration
+	pending  uint64
+
+	mu      sync.Mutex
+	last    time.Time
+	dropped map[zapcore.Level]uint64
+	// done and stopped are set while the background routine is running.
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newSamplingStats(core zapcore.Core, interval time.Duration) *samplingStats {
+	return &samplingStats{
+		core:     core,
+		interval: interval,
+		last:     time.Now(),
+		dropped:  make(map[zapcore.Level]uint64),
+	}
+}
+
+// start starts the background routine. It must be called with the lock held.
+func (s *samplingStats) start() {
+	s.done, s.stopped = make(chan struct{}), make(chan struct{})
+
+	samplingStatsMu.Lock()
+	samplingStatsSet[s] = struct{}{}
+	samplingStatsMu.Unlock()
+
+	go s.run(s.done, s.stopped)
+}
+
+func (s *samplingStats) run(done, stopped chan struct{}) {
+	defer close(stopped)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !s.summarize() && s.idle(done) {
+				return
+			}
+		case <-done:
+			s.summarize()
+			return
+		}
+	}
+}
+
+// idle returns true when no log was dropped since the last summary, the routine of the specified done channel must
+// then return. The next dropped log starts a new routine.
+func (s *samplingStats) idle(done chan struct{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The routine is being stopped.
+	if s.done != done || atomic.LoadUint64(&s.pending) != 0 {
+		return false
+	}
+	s.done, s.stopped = nil, nil
+
+	samplingStatsMu.Lock()
+	delete(samplingStatsSet, s)
+	samplingStatsMu.Unlock()
+	return true
+}
+
+// stop writes the last summary and stops the background routine, if any.
+func (s *samplingStats) stop() {
+	s.mu.Lock()
+	done, stopped := s.done, s.stopped
+	s.done, s.stopped = nil, nil
+	s.mu.Unlock()
+
+	if done != nil {
+		close(done)
+		<-stopped
+	}
+}
+
+func (s *samplingStats) hook(ent zapcore.Entry, dec zapcore.SamplingDecision) {
+	if dec&zapcore.LogDropped == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	s.dropped[ent.Level]++
+	atomic.AddUint64(&s.pending, 1)
+	if s.done == nil {
+		s.start()
+	}
+	s.mu.Unlock()
+
+	_ = stats.RecordWithTags(
+		context.Background(),
+		[]tag.Mutator{
+			tag.Upsert(nameKey, ent.LoggerName),
+			tag.Upsert(levelKey, ent.Level.String()),
+		},
+		mSampledOutLogs.M(1),
+	)
+}
+
+// summarize logs the number of dropped logs since the last summary, if any. It returns whether a summary is written.
+func (s *samplingStats) summarize() bool {
+	if atomic.LoadUint64(&s.pending) == 0 {
+		return false
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	dropped := s.dropped
+	since := s.last
+	s.dropped = make(map[zapcore.Level]uint64, len(dropped))
+	s.last = now
+	atomic.StoreUint64(&s.pending, 0)
+	s.mu.Unlock()
+
+	var total uint64
+	for _, n := range dropped {
+		total += n
+	}
+
+	_ = s.core.Write(
+		zapcore.Entry{Level: zapcore.WarnLevel, Time: now, Message: samplingSummaryMessage},
+		[]zapcore.Field{
+			zap.Uint64("dropped", total),
+			zap.Object("dropped_by_level", droppedByLevel(dropped)),
+			zap.Duration("period", now.Sub(since)),
+		},
+	)
+	return true
+}
+
+// droppedByLevel encodes the number of dropped logs per level.
+type droppedByLevel map[zapcore.Level]uint64
+
+func (d droppedByLevel) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	levels := make([]zapcore.Level, 0, len(d))
+	for level := range d {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+
+	for _, level := range levels {
+		enc.AddUint64(level.String(), d[level])
+	}
+	return nil
+}
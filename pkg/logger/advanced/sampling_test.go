@@ -0,0 +1,188 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
+// syncBuffer is a buffer written by the background routines of the sinks.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// logs decodes the logs written so far.
+func (b *syncBuffer) logs(t *testing.T) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return decodeLogs(t, bytes.NewBufferString(b.buf.String()))
+}
+
+// summaries returns the summaries of the dropped logs among the specified logs.
+func summaries(logs []map[string]interface{}) []map[string]interface{} {
+	var found []map[string]interface{}
+	for _, log := range logs {
+		if log["msg"] == samplingSummaryMessage {
+			found = append(found, log)
+		}
+	}
+	return found
+}
+
+func TestSamplingCore(t *testing.T) {
+	if err := view.Register(LogSampledOutCountView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(LogSampledOutCountView)
+
+	var buf syncBuffer
+	log := NewProductionLoggerWithOptions(&buf, Options{
+		Sampling: &SamplingOptions{Period: time.Hour, First: 2, ThereAfter: 3, SummaryInterval: 50 * time.Millisecond},
+	}).WithName("sampled")
+
+	// the first 2 logs then every 3rd log are taken, the errors are never sampled
+	for i := 1; i <= 10; i++ {
+		log.Info("Request", "i", i)
+		log.Error(nil, "Failure", "i", i)
+	}
+
+	var requests, failures []interface{}
+	for _, l := range buf.logs(t) {
+		switch l["msg"] {
+		case "Request":
+			requests = append(requests, l["i"])
+		case "Failure":
+			failures = append(failures, l["i"])
+		}
+	}
+	if len(requests) != 4 || requests[0] != 1.0 || requests[1] != 2.0 || requests[2] != 5.0 || requests[3] != 8.0 {
+		t.Errorf("the sampler took the requests %v, want 1, 2, 5 and 8", requests)
+	}
+	if len(failures) != 10 {
+		t.Errorf("the sampler took %d failures, want 10", len(failures))
+	}
+
+	rows, err := view.RetrieveData(LogSampledOutCountView.Name)
+	if err != nil || len(rows) != 1 || rows[0].Data.(*view.CountData).Value != 6 {
+		t.Errorf("the dropped logs are counted as %v (%v), want 6", rows, err)
+	}
+
+	// the summary is written without another log
+	var found []map[string]interface{}
+	for deadline := time.Now().Add(5 * time.Second); len(found) == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		found = summaries(buf.logs(t))
+	}
+
+	if len(found) != 1 {
+		t.Fatalf("got %d summaries, want 1", len(found))
+	}
+	summary := found[0]
+	byLevel, _ := summary["dropped_by_level"].(map[string]interface{})
+	if summary["level"] != "warn" || summary["dropped"] != 6.0 || len(byLevel) != 1 || byLevel["info"] != 6.0 {
+		t.Errorf("the summary is %v, want 6 dropped info logs", summary)
+	}
+
+	// no summary is written when no log is dropped
+	time.Sleep(150 * time.Millisecond)
+	if got := len(summaries(buf.logs(t))); got != 1 {
+		t.Errorf("got %d summaries, want no other summary", got)
+	}
+}
+
+func TestSamplingCoreClose(t *testing.T) {
+	var buf syncBuffer
+	log := NewProductionLoggerWithOptions(&buf, Options{
+		Sampling: &SamplingOptions{Period: time.Hour, First: 1, SummaryInterval: time.Hour},
+	})
+
+	for i := 0; i < 3; i++ {
+		log.Info("Request")
+	}
+
+	// the last summary is written when closed
+	if err := Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	found := summaries(buf.logs(t))
+	if len(found) != 1 || found[0]["dropped"] != 2.0 {
+		t.Errorf("got the summaries %v, want 2 dropped logs", found)
+	}
+	if strings.Count(buf.buf.String(), `"msg":"Request"`) != 1 {
+		t.Errorf("the sampler took the requests %q, want the first one", buf.buf.String())
+	}
+}
+
+// runningSamplingStats returns the number of sampling stats whose background routine is running.
+func runningSamplingStats() int {
+	samplingStatsMu.Lock()
+	defer samplingStatsMu.Unlock()
+	return len(samplingStatsSet)
+}
+
+func TestSamplingCoreRoutine(t *testing.T) {
+	running := runningSamplingStats()
+
+	var buf syncBuffer
+	log := NewProductionLoggerWithOptions(&buf, Options{
+		Sampling: &SamplingOptions{Period: time.Hour, First: 1, SummaryInterval: 20 * time.Millisecond},
+	})
+
+	// no routine is started until a log is dropped
+	log.Info("Request")
+	if got := runningSamplingStats(); got != running {
+		t.Fatalf("got %d running summary routines, want %d", got, running)
+	}
+
+	log.Info("Request")
+	if got := runningSamplingStats(); got != running+1 {
+		t.Fatalf("got %d running summary routines after a dropped log, want %d", got, running+1)
+	}
+
+	// the routine stops once an interval passes without dropped logs
+	for deadline := time.Now().Add(5 * time.Second); runningSamplingStats() != running; {
+		if time.Now().After(deadline) {
+			t.Fatal("the summary routine is still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := len(summaries(buf.logs(t))); got != 1 {
+		t.Errorf("got %d summaries, want 1", got)
+	}
+
+	// the next dropped log starts a new routine
+	log.Info("Request")
+	if got := runningSamplingStats(); got != running+1 {
+		t.Errorf("got %d running summary routines after another dropped log, want %d", got, running+1)
+	}
+}
+
+func TestSamplingCoreWith(t *testing.T) {
+	var buf syncBuffer
+	log := NewProductionLoggerWithOptions(&buf, Options{
+		Sampling: &SamplingOptions{Period: time.Hour, First: 2, SummaryInterval: time.Hour},
+	})
+	t.Cleanup(stopSamplingSummaries)
+
+	// the loggers with values share the sampling of their parent
+	log.Info("Request", "i", 1)
+	log.WithValues("user", "alice").Info("Request", "i", 2)
+	log.WithValues("user", "bob").Info("Request", "i", 3)
+
+	logs := buf.logs(t)
+	if len(logs) != 2 || logs[1]["user"] != "alice" {
+		t.Errorf("the sampler took the logs %v, want the first 2", logs)
+	}
+}
@@ -0,0 +1,191 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Encoding defines the format of the logs written to a sink.
+type Encoding string
+
+const (
+	// JSONEncoding writes one JSON object per log, as the production logger does.
+	JSONEncoding Encoding = "json"
+	// ConsoleEncoding writes human readable logs, as the development logger does.
+	ConsoleEncoding Encoding = "console"
+)
+
+// Sink defines a destination of the logs, with its own format, minimum level and sampling.
+//
+// Example, logging to the console at info level and to a JSON file at debug level:
+//
+//    logs.NewProductionLoggerWithOptions(nil, logs.Options{
+//        Sinks: []logs.Sink{
+//            {Writer: os.Stdout, Encoding: logs.ConsoleEncoding, Level: zapcore.InfoLevel},
+//            {Writer: file, Encoding: logs.JSONEncoding, Level: zapcore.DebugLevel},
+//        },
+//    })
+type Sink struct {
+	// Writer receives the encoded logs. It is required unless NewCore is set.
+	Writer io.Writer
+	// Encoding defines the format of the logs. It defaults to the encoding of the logger the sink is added to.
+	Encoding Encoding
+	// Level defines the minimum level of the logs written to the sink. It defaults to the level of the logger the
+	// sink is added to.
+	Level zapcore.LevelEnabler
+	// Sampling defines how the sink samples its logs. The sink does not sample logs when nil.
+	Sampling *SamplingOptions
+	// Async enables the asynchronous writing of the logs to Writer. The logs are written synchronously when nil.
+	Async *AsyncOptions
+	// FlightRecorder buffers the logs below Level and writes them when an error is logged, see
+	// ``FlightRecorderOptions``. It defaults to the flight recorder of the logger the sink is added to.
+	FlightRecorder *FlightRecorderOptions
+	// NewCore creates the zap core of the sink when the logs cannot simply be written to Writer, see
+	// ``NewSyslogSink``. The encoder is the one of the sink encoding.
+	NewCore func(enc zapcore.Encoder, level zapcore.LevelEnabler) zapcore.Core
+}
+
+func setSinkDefaults(sink *Sink, defaults Sink) {
+	if sink.Encoding == "" {
+		sink.Encoding = defaults.Encoding
+	}
+
+	if sink.Level == nil {
+		sink.Level = defaults.Level
+	}
+
+	if sink.FlightRecorder == nil {
+		sink.FlightRecorder = defaults.FlightRecorder
+	}
+}
+
+// newEncoder creates the zap encoder of the specified encoding.
+func newEncoder(encoding Encoding) (zapcore.Encoder, error) {
+	switch encoding {
+	case JSONEncoding:
+		encCfg := zap.NewProductionEncoderConfig()
+
+		// Production logger doesn't use the EncodeTime methods as the Development logger. To disable the
+		// time within log we have to clear the TimeKey.
+		if DisableLogTime {
+			encCfg.TimeKey = ""
+		}
+
+		return zapcore.NewJSONEncoder(encCfg), nil
+	case ConsoleEncoding:
+		encCfg := zap.NewDevelopmentEncoderConfig()
+		encCfg.EncodeTime = makeTimeEncoder(simpleTimeEncoder)
+
+		return zapcore.NewConsoleEncoder(encCfg), nil
+	case LogfmtEncoding:
+		encCfg := zap.NewProductionEncoderConfig()
+		if DisableLogTime {
+			encCfg.TimeKey = ""
+		}
+
+		return newLogfmtEncoder(encCfg), nil
+	case ECSEncoding:
+		return newECSEncoder(), nil
+	case GoogleCloudEncoding:
+		return newGoogleCloudEncoder(), nil
+	case OpenTelemetryEncoding:
+		return newOpenTelemetryEncoder(), nil
+	default:
+		return nil, fmt.Errorf("unknown log encoding %q", encoding)
+	}
+}
+
+// newSinkCore creates the zap core writing the logs to the specified sink. The sink core is wrapped, from the inside
+// out, by the redaction, error, sampling, debug and flight recorder cores. The redaction and error cores only check
+// the level of the sink core, which filters on the level alone as the sampling is applied above them, and add
+// themselves to the checked entry so that the logs go through their Write.
+func newSinkCore(sink Sink, options Options) (zapcore.Core, error) {
+	if sink.Writer == nil && sink.NewCore == nil {
+		return nil, fmt.Errorf("log sink without writer")
+	}
+
+	enc, err := newEncoder(sink.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	// The flight recorder lowers the sink level on error bursts.
+	level := sink.Level
+	var recorder *flightRecorder
+	if sink.FlightRecorder != nil && !sink.FlightRecorder.Disabled {
+		recorder = newFlightRecorder(sink.Level, *sink.FlightRecorder)
+		level = recorder
+	}
+
+	var core, buffer zapcore.Core
+	var ws zapcore.WriteSyncer
+	if sink.NewCore != nil {
+		core = sink.NewCore(enc, level)
+	} else {
+		ws = zapcore.AddSync(sink.Writer)
+		if sink.Async != nil {
+			ws = newAsyncWriter(ws, *sink.Async)
+		}
+		if recorder != nil {
+			buffer = newErrorCore(newRedactingCore(newFlightBufferCore(enc.Clone(), recorder), options.Redaction))
+		}
+		core = zapcore.NewCore(enc, ws, level)
+	}
+	core = newErrorCore(newRedactingCore(core, options.Redaction))
+
+	if sink.Sampling != nil && !sink.Sampling.Disabled {
+		core = newSamplingCore(core, *sink.Sampling)
+	}
+
+	// The debug loggers bypass the sink level, the sampling and the flight recorder.
+	core = newDebugCore(core)
+
+	if recorder != nil {
+		core = newFlightCore(core, buffer, ws, recorder)
+	}
+
+	return core, nil
+}
+
+// newTeeCore creates a zap core duplicating the logs to the default sink, when its writer is set, to every sink of
+// the options and to the recent logs buffer. Invalid sinks are reported on the standard error and ignored.
+func newTeeCore(defaultSink Sink, options Options) zapcore.Core {
+	sinks := make([]Sink, 0, len(options.Sinks)+1)
+	if defaultSink.Writer != nil {
+		sinks = append(sinks, defaultSink)
+	}
+
+	for _, sink := range options.Sinks {
+		setSinkDefaults(&sink, defaultSink)
+		sinks = append(sinks, sink)
+	}
+
+	cores := make([]zapcore.Core, 0, len(sinks))
+	for _, sink := range sinks {
+		core, err := newSinkCore(sink, options)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring invalid log sink: %v\n", err)
+			continue
+		}
+		cores = append(cores, core)
+	}
+
+	if options.RecentLogs != nil {
+		core := newRedactingCore(newRecentCore(options.RecentLogs, defaultSink.Level), options.Redaction)
+		cores = append(cores, newErrorCore(core))
+	}
+
+	return zapcore.NewTee(cores...)
+}
+
+// errorOutput returns the writer receiving the logger internal errors.
+func errorOutput(defaultSink Sink) zapcore.WriteSyncer {
+	if defaultSink.Writer != nil {
+		return zapcore.AddSync(defaultSink.Writer)
+	}
+	return zapcore.Lock(os.Stderr)
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSinksLevelAndEncoding(t *testing.T) {
+	var console, file bytes.Buffer
+	log := NewProductionLoggerWithOptions(nil, Options{
+		Sinks: []Sink{
+			{Writer: &console, Encoding: ConsoleEncoding, Level: zapcore.InfoLevel},
+			{Writer: &file, Encoding: JSONEncoding, Level: zapcore.DebugLevel},
+		},
+	})
+
+	log.V(1).Info("Debug details")
+	log.Info("Server started", "port", 8080)
+
+	if strings.Contains(console.String(), "Debug details") {
+		t.Errorf("the info sink got the debug log: %s", console.String())
+	}
+	if !strings.Contains(console.String(), "INFO\tServer started\t{\"port\": 8080}") {
+		t.Errorf("the console sink did not get the info log in the console format: %s", console.String())
+	}
+
+	logs := decodeLogs(t, &file)
+	if len(logs) != 2 || logs[0]["msg"] != "Debug details" || logs[1]["msg"] != "Server started" {
+		t.Errorf("the JSON sink got %v, want the debug and info logs", logs)
+	}
+}
+
+func TestSinksDefaults(t *testing.T) {
+	var destination, sink bytes.Buffer
+	log := NewProductionLoggerWithOptions(&destination, Options{
+		Encoding: LogfmtEncoding,
+		Sinks:    []Sink{{Writer: &sink}},
+	})
+
+	log.V(1).Info("Debug details")
+	log.Info("Server started")
+
+	// the sink inherits the encoding and the level of the logger
+	for name, buf := range map[string]*bytes.Buffer{"destination": &destination, "sink": &sink} {
+		if strings.Contains(buf.String(), "Debug details") {
+			t.Errorf("the %s got the debug log: %s", name, buf.String())
+		}
+		if !strings.Contains(buf.String(), `msg="Server started"`) {
+			t.Errorf("the %s did not get the info log in the logfmt format: %s", name, buf.String())
+		}
+	}
+}
+
+func TestSinksInvalid(t *testing.T) {
+	var invalid, valid bytes.Buffer
+	log := NewProductionLoggerWithOptions(nil, Options{
+		Sinks: []Sink{
+			{Writer: &invalid, Encoding: "xml"},
+			{Encoding: JSONEncoding},
+			{Writer: &valid},
+		},
+	})
+
+	log.Info("Server started")
+
+	if invalid.Len() != 0 {
+		t.Errorf("the invalid sink got logs: %s", invalid.String())
+	}
+	if logs := decodeLogs(t, &valid); len(logs) != 1 || logs[0]["msg"] != "Server started" {
+		t.Errorf("the valid sink got %v, want the info log", logs)
+	}
+}
+
+func TestInvalidEncoding(t *testing.T) {
+	var destination bytes.Buffer
+	log := NewProductionLoggerWithOptions(&destination, Options{Encoding: "xml"})
+
+	log.Info("Server started")
+
+	// the logger falls back to its own encoding
+	if logs := decodeLogs(t, &destination); len(logs) != 1 || logs[0]["msg"] != "Server started" {
+		t.Errorf("the destination got %v, want the info log in the JSON format", logs)
+	}
+}
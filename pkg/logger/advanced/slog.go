@@ -0,0 +1,245 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewSlogHandler returns a ``log/slog`` handler logging with the specified logger.
+//
+// The slog levels are mapped to the zap levels: debug, info, warn and error. The levels below debug are mapped to
+// the logger verbosity, i.e. ``slog.LevelDebug - 4`` is logged as ``V(2)``. The groups are logged as nested objects
+// and the trace and span ids of the span of the record context are added to the logs, see ``WithTraceContext``.
+//
+// The logger must expose its zap logger, as the loggers created by this package do, for the levels and the groups to
+// be preserved. Otherwise the warnings are logged at info level and the groups are flattened with dotted keys.
+func NewSlogHandler(log logr.Logger) slog.Handler {
+	if underlier, ok := log.GetSink().(zapr.Underlier); ok {
+		return &slogHandler{log: underlier.GetUnderlying()}
+	}
+	return &slogLogrHandler{log: log}
+}
+
+// slogLevel maps the specified slog level to a zap level.
+func slogLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case level >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		// Every 4 slog levels below info is a verbosity level: debug is V(1).
+		verbosity := (int(slog.LevelInfo-level) + 3) / 4
+		if verbosity > 127 {
+			verbosity = 127
+		}
+		return zapcore.Level(-verbosity)
+	}
+}
+
+// slogGroup is a group opened by ``slog.Handler.WithGroup`` and the attributes added to it.
+type slogGroup struct {
+	name   string
+	fields []zap.Field
+}
+
+// slogHandler is a slog.Handler writing the records to a zap logger.
+type slogHandler struct {
+	// log holds the attributes added before the first group.
+	log    *zap.Logger
+	groups []slogGroup
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.log.Core().Enabled(slogLevel(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, record slog.Record) error {
+	ce := h.log.Check(slogLevel(record.Level), record.Message)
+	if ce == nil {
+		return nil
+	}
+
+	if !record.Time.IsZero() {
+		ce.Time = record.Time
+	}
+
+	fields := make([]zap.Field, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendSlogAttr(fields, attr)
+		return true
+	})
+
+	// Nest the record attributes in the opened groups, the groups without attributes are omitted.
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		group := h.groups[i]
+		fields = append(group.fields[:len(group.fields):len(group.fields)], fields...)
+		if len(fields) > 0 {
+			fields = []zap.Field{zap.Object(group.name, objectFields(fields))}
+		}
+	}
+
+	keysAndValues := traceContextValues(ctx)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields = append(fields, zap.Any(keysAndValues[i].(string), keysAndValues[i+1]))
+	}
+
+	ce.Write(fields...)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]zap.Field, 0, len(attrs))
+	for _, attr := range attrs {
+		fields = appendSlogAttr(fields, attr)
+	}
+
+	if len(h.groups) == 0 {
+		return &slogHandler{log: h.log.With(fields...)}
+	}
+
+	groups := append([]slogGroup(nil), h.groups...)
+	last := &groups[len(groups)-1]
+	last.fields = append(last.fields[:len(last.fields):len(last.fields)], fields...)
+
+	return &slogHandler{log: h.log, groups: groups}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &slogHandler{
+		log:    h.log,
+		groups: append(h.groups[:len(h.groups):len(h.groups)], slogGroup{name: name}),
+	}
+}
+
+// appendSlogAttr appends the zap fields of the specified slog attribute.
+func appendSlogAttr(fields []zap.Field, attr slog.Attr) []zap.Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	value := attr.Value
+	switch value.Kind() {
+	case slog.KindBool:
+		return append(fields, zap.Bool(attr.Key, value.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(attr.Key, value.Duration()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(attr.Key, value.Float64()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(attr.Key, value.Int64()))
+	case slog.KindString:
+		return append(fields, zap.String(attr.Key, value.String()))
+	case slog.KindTime:
+		return append(fields, zap.Time(attr.Key, value.Time()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(attr.Key, value.Uint64()))
+	case slog.KindGroup:
+		var group []zap.Field
+		for _, groupAttr := range value.Group() {
+			group = appendSlogAttr(group, groupAttr)
+		}
+
+		switch {
+		case len(group) == 0:
+			return fields
+		case attr.Key == "":
+			// Groups without key are inlined.
+			return append(fields, group...)
+		default:
+			return append(fields, zap.Object(attr.Key, objectFields(group)))
+		}
+	default:
+		return append(fields, zap.Any(attr.Key, value.Any()))
+	}
+}
+
+// objectFields encodes fields as an object, i.e. the fields of a slog group.
+type objectFields []zap.Field
+
+func (f objectFields) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for i := range f {
+		f[i].AddTo(enc)
+	}
+	return nil
+}
+
+// slogLogrHandler is a slog.Handler writing the records to a logr logger.
+type slogLogrHandler struct {
+	log    logr.Logger
+	prefix string
+}
+
+func (h *slogLogrHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if zapLevel := slogLevel(level); zapLevel < zapcore.InfoLevel {
+		return h.log.V(int(zapcore.InfoLevel - zapLevel)).Enabled()
+	}
+	return h.log.Enabled()
+}
+
+func (h *slogLogrHandler) Handle(ctx context.Context, record slog.Record) error {
+	keysAndValues := make([]interface{}, 0, 2*record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		keysAndValues = appendSlogKeysAndValues(keysAndValues, h.prefix, attr)
+		return true
+	})
+	keysAndValues = append(keysAndValues, traceContextValues(ctx)...)
+
+	switch zapLevel := slogLevel(record.Level); {
+	case zapLevel >= zapcore.ErrorLevel:
+		h.log.Error(nil, record.Message, keysAndValues...)
+	case zapLevel < zapcore.InfoLevel:
+		h.log.V(int(zapcore.InfoLevel-zapLevel)).Info(record.Message, keysAndValues...)
+	default:
+		h.log.Info(record.Message, keysAndValues...)
+	}
+	return nil
+}
+
+func (h *slogLogrHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var keysAndValues []interface{}
+	for _, attr := range attrs {
+		keysAndValues = appendSlogKeysAndValues(keysAndValues, h.prefix, attr)
+	}
+	return &slogLogrHandler{log: h.log.WithValues(keysAndValues...), prefix: h.prefix}
+}
+
+func (h *slogLogrHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogLogrHandler{log: h.log, prefix: h.prefix + name + "."}
+}
+
+// appendSlogKeysAndValues appends the key and value pairs of the specified slog attribute, the groups are flattened
+// with dotted keys.
+func appendSlogKeysAndValues(keysAndValues []interface{}, prefix string, attr slog.Attr) []interface{} {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return keysAndValues
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			keysAndValues = appendSlogKeysAndValues(keysAndValues, prefix, groupAttr)
+		}
+		return keysAndValues
+	}
+
+	return append(keysAndValues, prefix+attr.Key, attr.Value.Any())
+}
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSlogLevel(t *testing.T) {
+	for _, tt := range []struct {
+		level slog.Level
+		want  zapcore.Level
+	}{
+		{slog.LevelError + 4, zapcore.ErrorLevel},
+		{slog.LevelError, zapcore.ErrorLevel},
+		{slog.LevelWarn, zapcore.WarnLevel},
+		{slog.LevelInfo + 2, zapcore.InfoLevel},
+		{slog.LevelInfo, zapcore.InfoLevel},
+		{slog.LevelInfo - 1, zapcore.DebugLevel},
+		{slog.LevelDebug, zapcore.DebugLevel},
+		{slog.LevelDebug - 4, zapcore.Level(-2)},
+		{slog.Level(-1000), zapcore.Level(-127)},
+	} {
+		if got := slogLevel(tt.level); got != tt.want {
+			t.Errorf("slogLevel(%v) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewSlogHandler(NewProductionLoggerWithOptions(&buf, Options{})))
+
+	log.Debug("Hidden")
+	log.Warn("Slow request", "duration", 2.5)
+	log.With("service", "users").WithGroup("request").With("method", "GET").WithGroup("details").
+		Error("Request failed", "status", 500, slog.Group("client", "ip", "127.0.0.1"), slog.Group("", "inlined", true))
+	log.WithGroup("empty").Info("Request received")
+
+	logs := decodeLogs(t, &buf)
+	if len(logs) != 3 {
+		t.Fatalf("got %d logs, want 3: %v", len(logs), logs)
+	}
+
+	if logs[0]["level"] != "warn" || logs[0]["msg"] != "Slow request" || logs[0]["duration"] != 2.5 {
+		t.Errorf("the warning is %v", logs[0])
+	}
+
+	// the groups are nested, the groups without key are inlined
+	want := map[string]interface{}{
+		"method": "GET",
+		"details": map[string]interface{}{
+			"status":  float64(500),
+			"client":  map[string]interface{}{"ip": "127.0.0.1"},
+			"inlined": true,
+		},
+	}
+	if logs[1]["level"] != "error" || logs[1]["service"] != "users" || !reflect.DeepEqual(logs[1]["request"], want) {
+		t.Errorf("the error is %v, want the request group %v", logs[1], want)
+	}
+
+	// the empty groups are omitted
+	if _, ok := logs[2]["empty"]; ok || logs[2]["msg"] != "Request received" {
+		t.Errorf("the log without attributes is %v, want no group", logs[2])
+	}
+}
+
+func TestSlogHandlerEnabled(t *testing.T) {
+	handler := NewSlogHandler(NewProductionLoggerWithOptions(&bytes.Buffer{}, Options{}))
+
+	for level, want := range map[slog.Level]bool{slog.LevelDebug: false, slog.LevelInfo: true, slog.LevelError: true} {
+		if got := handler.Enabled(context.Background(), level); got != want {
+			t.Errorf("Enabled(%v) = %v, want %v", level, got, want)
+		}
+	}
+}
+
+func TestSlogLogrHandler(t *testing.T) {
+	var lines []string
+	logr := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{Verbosity: 1})
+	log := slog.New(NewSlogHandler(logr))
+
+	log.Debug("Connecting")
+	log.Log(context.Background(), slog.LevelDebug-4, "Hidden")
+	log.Warn("Slow request")
+	log.WithGroup("request").With("method", "GET").Error("Request failed", slog.Group("client", "ip", "127.0.0.1"))
+
+	// the warnings are logged at info level, the groups are flattened
+	want := []string{
+		`"level"=1 "msg"="Connecting"`,
+		`"level"=0 "msg"="Slow request"`,
+		`"msg"="Request failed" "error"=null "request.method"="GET" "request.client.ip"="127.0.0.1"`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("the logger got %q, want %q", lines, want)
+	}
+}
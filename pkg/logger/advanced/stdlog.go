@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"io"
+	stdlog "log"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// stdLogLevelPrefix matches the level prefixes commonly used with the standard library logger, i.e. ``[WARN]``,
+// ``ERROR:`` or ``debug``.
+var stdLogLevelPrefix = regexp.MustCompile(`(?i)^\[?(debug|info|warn|warning|error|fatal)\]?:?\s+`)
+
+// stdLogMessageLevels defines the level of the well-known messages of the standard library.
+var stdLogMessageLevels = []struct {
+	prefix string
+	level  zapcore.Level
+}{
+	{prefix: "http: panic serving", level: zapcore.ErrorLevel},
+	{prefix: "http: TLS handshake error", level: zapcore.WarnLevel},
+	{prefix: "http: superfluous response.WriteHeader", level: zapcore.WarnLevel},
+	{prefix: "http2: server: error reading preface", level: zapcore.WarnLevel},
+}
+
+// NewStdLogWriter returns a writer logging every written output with the specified logger.
+//
+// The level of an output is defined by its level prefix, i.e. ``[WARN]`` or ``ERROR:``, which is removed, or by the
+// well-known standard library messages, i.e. TLS handshake errors are warnings and recovered panics are errors. The
+// other outputs are logged at the specified default level.
+//
+// The zap logger of the logger is resolved on every write, so that the delegating loggers, i.e. ``logger.Log``, log
+// at the output level once their logger is set. The warnings are logged at info level when no zap logger is found.
+func NewStdLogWriter(log logr.Logger, defaultLevel zapcore.Level) io.Writer {
+	return &stdLogWriter{log: log, level: defaultLevel}
+}
+
+// NewStdLogger returns a standard library logger logging with the specified logger, see ``NewStdLogWriter``. Use it
+// where a ``*log.Logger`` is expected, i.e. ``http.Server.ErrorLog``.
+func NewStdLogger(log logr.Logger, defaultLevel zapcore.Level) *stdlog.Logger {
+	return stdlog.New(NewStdLogWriter(log, defaultLevel), "", 0)
+}
+
+// RedirectStdLog redirects the output of the standard library global logger to the specified logger, at info level
+// by default, see ``NewStdLogWriter``. It returns a function restoring the previous output.
+func RedirectStdLog(log logr.Logger) func() {
+	flags := stdlog.Flags()
+	prefix := stdlog.Prefix()
+	output := stdlog.Writer()
+
+	stdlog.SetFlags(0)
+	stdlog.SetPrefix("")
+	stdlog.SetOutput(NewStdLogWriter(log, zapcore.InfoLevel))
+
+	return func() {
+		stdlog.SetFlags(flags)
+		stdlog.SetPrefix(prefix)
+		stdlog.SetOutput(output)
+	}
+}
+
+type stdLogWriter struct {
+	log   logr.Logger
+	level zapcore.Level
+}
+
+// Write logs the specified output as a single log: the standard library logger writes one log per call, and the
+// multiline logs, i.e. recovered panics and their stack trace, are kept together.
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+	if strings.TrimSpace(line) == "" {
+		return len(p), nil
+	}
+
+	level, msg := stdLogLevel(line, w.level)
+
+	if log, ok := underlyingZapLogger(w.log); ok {
+		if ce := log.Check(level, msg); ce != nil {
+			ce.Write()
+		}
+		return len(p), nil
+	}
+
+	switch {
+	case level >= zapcore.ErrorLevel:
+		w.log.Error(nil, msg)
+	case level < zapcore.InfoLevel:
+		w.log.V(int(zapcore.InfoLevel - level)).Info(msg)
+	default:
+		w.log.Info(msg)
+	}
+	return len(p), nil
+}
+
+// underlyingZapLogger returns the zap logger of the specified logger. The delegating sinks, i.e. the sink of
+// ``logger.Log``, return the sink they delegate to when adding values, an empty set of values resolves it.
+func underlyingZapLogger(log logr.Logger) (*zap.Logger, bool) {
+	sink := log.GetSink()
+	if sink == nil {
+		return nil, false
+	}
+
+	if underlier, ok := sink.(zapr.Underlier); ok {
+		return underlier.GetUnderlying(), true
+	}
+	if underlier, ok := sink.WithValues().(zapr.Underlier); ok {
+		return underlier.GetUnderlying(), true
+	}
+	return nil, false
+}
+
+// stdLogLevel returns the level of the specified standard library log line and its message without level prefix.
+func stdLogLevel(line string, defaultLevel zapcore.Level) (zapcore.Level, string) {
+	if match := stdLogLevelPrefix.FindStringSubmatch(line); match != nil {
+		msg := line[len(match[0]):]
+		switch strings.ToLower(match[1]) {
+		case "debug":
+			return zapcore.DebugLevel, msg
+		case "info":
+			return zapcore.InfoLevel, msg
+		case "warn", "warning":
+			return zapcore.WarnLevel, msg
+		default:
+			// Fatal logs are logged as errors: the standard library logger exits by itself.
+			return zapcore.ErrorLevel, msg
+		}
+	}
+
+	for _, known := range stdLogMessageLevels {
+		if strings.HasPrefix(line, known.prefix) {
+			return known.level, line
+		}
+	}
+
+	return defaultLevel, line
+}
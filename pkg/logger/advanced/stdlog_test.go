@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"go.uber.org/zap/zapcore"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestStdLogLevel(t *testing.T) {
+	for _, tt := range []struct {
+		line      string
+		wantLevel zapcore.Level
+		wantMsg   string
+	}{
+		{"[DEBUG] Connecting", zapcore.DebugLevel, "Connecting"},
+		{"info: Connected", zapcore.InfoLevel, "Connected"},
+		{"[WARN] Slow response", zapcore.WarnLevel, "Slow response"},
+		{"WARNING: Slow response", zapcore.WarnLevel, "Slow response"},
+		{"error: Connection lost", zapcore.ErrorLevel, "Connection lost"},
+		{"[FATAL] Exiting", zapcore.ErrorLevel, "Exiting"},
+		{"http: TLS handshake error from 127.0.0.1:1234: EOF", zapcore.WarnLevel, "http: TLS handshake error from 127.0.0.1:1234: EOF"},
+		{"http: panic serving 127.0.0.1:1234: boom", zapcore.ErrorLevel, "http: panic serving 127.0.0.1:1234: boom"},
+		{"Started", zapcore.InfoLevel, "Started"},
+		{"warnings are not prefixes", zapcore.InfoLevel, "warnings are not prefixes"},
+	} {
+		level, msg := stdLogLevel(tt.line, zapcore.InfoLevel)
+		if level != tt.wantLevel || msg != tt.wantMsg {
+			t.Errorf("stdLogLevel(%q) = %v, %q, want %v, %q", tt.line, level, msg, tt.wantLevel, tt.wantMsg)
+		}
+	}
+}
+
+func TestStdLogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewProductionLoggerWithOptions(&buf, Options{})
+
+	// the delegating loggers log with the zap logger they delegate to once fulfilled
+	delegating := logf.NewDelegatingLogSink(logf.NullLogSink{})
+	delegatingLog := logr.New(delegating).WithName("server")
+	delegating.Fulfill(log.GetSink())
+
+	for name, log := range map[string]logr.Logger{"zap": log, "delegating": delegatingLog} {
+		t.Run(name, func(t *testing.T) {
+			buf.Reset()
+
+			std := NewStdLogger(log, zapcore.ErrorLevel)
+			std.Print("[WARN] Slow response")
+			std.Print("http: panic serving 127.0.0.1:1234: boom\ngoroutine 1 [running]:\n")
+			std.Print("Unknown error")
+			std.Print("[DEBUG] Hidden")
+
+			logs := decodeLogs(t, &buf)
+			if len(logs) != 3 {
+				t.Fatalf("got %d logs, want 3: %v", len(logs), logs)
+			}
+			for i, want := range []struct{ level, msg string }{
+				{"warn", "Slow response"},
+				{"error", "http: panic serving 127.0.0.1:1234: boom\ngoroutine 1 [running]:"},
+				{"error", "Unknown error"},
+			} {
+				if logs[i]["level"] != want.level || logs[i]["msg"] != want.msg {
+					t.Errorf("log %d is %v, want %s %q", i, logs[i], want.level, want.msg)
+				}
+			}
+		})
+	}
+}
+
+func TestStdLogWriterFallback(t *testing.T) {
+	var lines []string
+	log := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{Verbosity: 1})
+
+	std := NewStdLogger(log, zapcore.InfoLevel)
+	std.Print("[WARN] Slow response")
+	std.Print("[ERROR] Connection lost")
+	std.Print("[DEBUG] Connecting")
+
+	want := []string{
+		`"level"=0 "msg"="Slow response"`,
+		`"msg"="Connection lost" "error"=null`,
+		`"level"=1 "msg"="Connecting"`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("the logger got %q, want %q", lines, want)
+	}
+}
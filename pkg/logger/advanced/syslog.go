@@ -0,0 +1,513 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/mojun2021/micro-server/pkg/helpers/production"
+)
+
+// SyslogFormat defines the syslog message format.
+type SyslogFormat int
+
+const (
+	// RFC5424 is the syslog protocol format, https://tools.ietf.org/html/rfc5424. The entry fields are carried as
+	// structured data.
+	RFC5424 SyslogFormat = iota
+	// RFC3164 is the BSD syslog format, https://tools.ietf.org/html/rfc3164. The entry fields are appended to the
+	// message as JSON.
+	RFC3164
+)
+
+// SyslogFacility defines the syslog facility of the messages.
+type SyslogFacility int
+
+// Syslog facilities as defined by RFC 5424.
+const (
+	SyslogFacilityKern     SyslogFacility = 0
+	SyslogFacilityUser     SyslogFacility = 1
+	SyslogFacilityMail     SyslogFacility = 2
+	SyslogFacilityDaemon   SyslogFacility = 3
+	SyslogFacilityAuth     SyslogFacility = 4
+	SyslogFacilitySyslog   SyslogFacility = 5
+	SyslogFacilityAuthPriv SyslogFacility = 10
+	SyslogFacilityLocal0   SyslogFacility = 16
+	SyslogFacilityLocal1   SyslogFacility = 17
+	SyslogFacilityLocal2   SyslogFacility = 18
+	SyslogFacilityLocal3   SyslogFacility = 19
+	SyslogFacilityLocal4   SyslogFacility = 20
+	SyslogFacilityLocal5   SyslogFacility = 21
+	SyslogFacilityLocal6   SyslogFacility = 22
+	SyslogFacilityLocal7   SyslogFacility = 23
+)
+
+const (
+	// SyslogDefaultStructuredDataID defines the default identifier of the RFC 5424 structured data element carrying
+	// the entry fields.
+	SyslogDefaultStructuredDataID = "fields@32473"
+
+	syslogDefaultMinBackoff   = 100 * time.Millisecond
+	syslogDefaultMaxBackoff   = 30 * time.Second
+	syslogDefaultWriteTimeout = time.Second
+	syslogDialTimeout         = 5 * time.Second
+	syslogNilValue            = "-"
+
+	// The maximum lengths of the RFC 5424 header fields and structured data names.
+	syslogMaxHostnameLen = 255
+	syslogMaxAppNameLen  = 48
+	syslogMaxNameLen     = 32
+)
+
+// syslogLocalAddresses lists the usual local syslog sockets.
+var syslogLocalAddresses = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogOptions defines a syslog destination.
+type SyslogOptions struct {
+	// Network is the network of the syslog server: ``unix``, ``unixgram``, ``udp`` or ``tcp``. When empty, the
+	// local syslog socket is used.
+	Network string
+	// Address is the address of the syslog server.
+	Address string
+	// Format defines the syslog message format. It defaults to ``RFC5424``.
+	Format SyslogFormat
+	// Facility defines the facility of the messages. It defaults to ``SyslogFacilityUser``.
+	Facility SyslogFacility
+	// AppName defines the application name of the messages. It defaults to the executable name.
+	AppName string
+	// Hostname defines the host name of the messages. It defaults to the current host name.
+	Hostname string
+	// StructuredDataID defines the identifier of the RFC 5424 structured data element carrying the entry fields. It
+	// defaults to ``SyslogDefaultStructuredDataID``.
+	StructuredDataID string
+	// MinBackoff and MaxBackoff bound the delay between two reconnections to the syslog server.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// WriteTimeout defines the maximum duration of a write to the syslog server, the log goes to the fallback when
+	// it expires. It defaults to 1s.
+	WriteTimeout time.Duration
+	// Fallback receives the logs, encoded with the sink encoding, while the syslog server cannot be reached. It
+	// defaults to the standard error.
+	Fallback io.Writer
+}
+
+func setSyslogOptionsDefaults(options *SyslogOptions) {
+	// The kernel facility is reserved to the kernel messages.
+	if options.Facility == SyslogFacilityKern {
+		options.Facility = SyslogFacilityUser
+	}
+
+	if options.AppName == "" {
+		options.AppName = filepath.Base(os.Args[0])
+	}
+
+	if options.Hostname == "" {
+		options.Hostname = production.Hostname()
+	}
+
+	if options.StructuredDataID == "" {
+		options.StructuredDataID = SyslogDefaultStructuredDataID
+	}
+
+	if options.MinBackoff == 0 {
+		options.MinBackoff = syslogDefaultMinBackoff
+	}
+
+	if options.MaxBackoff == 0 {
+		options.MaxBackoff = syslogDefaultMaxBackoff
+	}
+
+	if options.WriteTimeout == 0 {
+		options.WriteTimeout = syslogDefaultWriteTimeout
+	}
+
+	if options.Fallback == nil {
+		options.Fallback = os.Stderr
+	}
+}
+
+// NewSyslogSink creates a sink sending the logs to a syslog server.
+//
+// Example, sending the errors to the local syslog:
+//
+//    sink, err := logs.NewSyslogSink(logs.SyslogOptions{Facility: logs.SyslogFacilityLocal0})
+//    sink.Level = zapcore.ErrorLevel
+func NewSyslogSink(options SyslogOptions) (Sink, error) {
+	setSyslogOptionsDefaults(&options)
+
+	switch options.Network {
+	case "":
+	case "unix", "unixgram", "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+		if options.Address == "" {
+			return Sink{}, fmt.Errorf("syslog address not defined for network %q", options.Network)
+		}
+	default:
+		return Sink{}, fmt.Errorf("unsupported syslog network %q", options.Network)
+	}
+
+	conn := &syslogConn{options: &options, backoff: options.MinBackoff}
+	fallback := zapcore.Lock(zapcore.AddSync(options.Fallback))
+
+	return Sink{
+		NewCore: func(enc zapcore.Encoder, level zapcore.LevelEnabler) zapcore.Core {
+			return &syslogCore{
+				LevelEnabler: level,
+				enc:          enc,
+				conn:         conn,
+				fallback:     fallback,
+			}
+		},
+	}, nil
+}
+
+// syslogSeverity maps a zap level to a syslog severity.
+func syslogSeverity(level zapcore.Level) int {
+	switch {
+	case level >= zapcore.FatalLevel:
+		return 0 // emergency
+	case level >= zapcore.PanicLevel:
+		return 1 // alert
+	case level >= zapcore.DPanicLevel:
+		return 2 // critical
+	case level >= zapcore.ErrorLevel:
+		return 3 // error
+	case level >= zapcore.WarnLevel:
+		return 4 // warning
+	case level >= zapcore.InfoLevel:
+		return 6 // informational
+	default:
+		return 7 // debug
+	}
+}
+
+// syslogCore is a zapcore.Core sending the entries to a syslog server.
+type syslogCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	// values holds the fields added with With, encoded when added, and namespaces the namespaces they opened.
+	values     map[string]interface{}
+	namespaces []string
+	conn       *syslogConn
+	fallback   zapcore.WriteSyncer
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	values := c.encodeValues(fields)
+	namespaces := c.namespaces
+	for i := range fields {
+		fields[i].AddTo(enc)
+		if fields[i].Type == zapcore.NamespaceType {
+			namespaces = append(namespaces[:len(namespaces):len(namespaces)], fields[i].Key)
+		}
+	}
+
+	return &syslogCore{
+		LevelEnabler: c.LevelEnabler,
+		enc:          enc,
+		values:       values,
+		namespaces:   namespaces,
+		conn:         c.conn,
+		fallback:     c.fallback,
+	}
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// encodeValues returns the values of the core along with the specified fields, added to the namespace opened last.
+func (c *syslogCore) encodeValues(fields []zapcore.Field) map[string]interface{} {
+	if len(fields) == 0 {
+		return c.values
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, namespace := range c.namespaces {
+		enc.OpenNamespace(namespace)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return mergeSyslogValues(c.values, enc.Fields)
+}
+
+// mergeSyslogValues returns the values of dst along with the values of src, the namespaces present in both being
+// merged. The values of dst are not modified.
+func mergeSyslogValues(dst, src map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(dst)+len(src))
+	for k, v := range dst {
+		merged[k] = v
+	}
+
+	for k, v := range src {
+		if namespace, ok := v.(map[string]interface{}); ok {
+			if existing, ok := merged[k].(map[string]interface{}); ok {
+				merged[k] = mergeSyslogValues(existing, namespace)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	msg := c.conn.format(ent, c.encodeValues(fields))
+	if err := c.conn.write(msg); err == nil {
+		return nil
+	}
+
+	// The syslog server cannot be reached, keep the log locally.
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	_, err = c.fallback.Write(buf.Bytes())
+	return err
+}
+
+func (c *syslogCore) Sync() error {
+	return c.fallback.Sync()
+}
+
+// syslogConn is the connection to the syslog server, shared by all the cores of a sink.
+type syslogConn struct {
+	options *SyslogOptions
+
+	mu      sync.Mutex
+	conn    net.Conn
+	stream  bool
+	dialing bool
+	backoff time.Duration
+	retryAt time.Time
+}
+
+// write sends the message to the syslog server, reconnecting when needed. While the server cannot be reached, the
+// reconnections are delayed with an exponential backoff and an error is returned.
+//
+// The connection is dialed without holding the lock: the messages written meanwhile return an error, and go to the
+// fallback, rather than waiting for the dial timeout. The writes are bounded by the write timeout.
+func (c *syslogConn) write(msg []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		switch {
+		case c.dialing:
+			return fmt.Errorf("syslog server connection in progress")
+		case time.Now().Before(c.retryAt):
+			return fmt.Errorf("syslog server unavailable until %s", c.retryAt.Format(time.RFC3339))
+		}
+
+		c.dialing = true
+		c.mu.Unlock()
+		conn, stream, err := c.dial()
+		c.mu.Lock()
+		c.dialing = false
+
+		if err != nil {
+			c.delayRetry()
+			return err
+		}
+		c.conn, c.stream = conn, stream
+	}
+
+	if c.stream {
+		if c.options.Network == "unix" {
+			msg = append(msg, '\n')
+		} else {
+			// Octet counting framing, https://tools.ietf.org/html/rfc6587#section-3.4.1
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+	}
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.options.WriteTimeout)); err != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+		c.delayRetry()
+		return err
+	}
+
+	if _, err := c.conn.Write(msg); err != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+		c.delayRetry()
+		return err
+	}
+
+	c.backoff = c.options.MinBackoff
+	return nil
+}
+
+func (c *syslogConn) delayRetry() {
+	c.retryAt = time.Now().Add(c.backoff)
+
+	c.backoff *= 2
+	if c.backoff > c.options.MaxBackoff {
+		c.backoff = c.options.MaxBackoff
+	}
+}
+
+// dial connects to the syslog server, it returns whether the connection is a stream.
+func (c *syslogConn) dial() (net.Conn, bool, error) {
+	if c.options.Network != "" {
+		conn, err := net.DialTimeout(c.options.Network, c.options.Address, syslogDialTimeout)
+		if err != nil {
+			return nil, false, err
+		}
+		return conn, !strings.HasPrefix(c.options.Network, "udp") && c.options.Network != "unixgram", nil
+	}
+
+	var err error
+	for _, address := range syslogLocalAddresses {
+		for _, network := range []string{"unixgram", "unix"} {
+			var conn net.Conn
+			if conn, err = net.DialTimeout(network, address, syslogDialTimeout); err == nil {
+				return conn, network == "unix", nil
+			}
+		}
+	}
+
+	return nil, false, fmt.Errorf("local syslog socket not found: %w", err)
+}
+
+// format creates the syslog message of the specified entry.
+func (c *syslogConn) format(ent zapcore.Entry, fields map[string]interface{}) []byte {
+	options := c.options
+	priority := int(options.Facility)*8 + syslogSeverity(ent.Level)
+
+	var b strings.Builder
+
+	switch options.Format {
+	case RFC3164:
+		fmt.Fprintf(&b, "<%d>%s %s %s[%d]: %s",
+			priority,
+			ent.Time.Format(time.Stamp),
+			options.Hostname,
+			options.AppName,
+			os.Getpid(),
+			ent.Message,
+		)
+
+		if len(fields) > 0 {
+			if data, err := json.Marshal(fields); err == nil {
+				b.WriteByte(' ')
+				b.Write(data)
+			}
+		}
+	default:
+		msgID := syslogNilValue
+		if ent.LoggerName != "" {
+			msgID = syslogName(ent.LoggerName, syslogMaxNameLen)
+		}
+
+		fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s ",
+			priority,
+			ent.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+			syslogNameOrNil(options.Hostname, syslogMaxHostnameLen),
+			syslogNameOrNil(options.AppName, syslogMaxAppNameLen),
+			os.Getpid(),
+			msgID,
+		)
+
+		writeStructuredData(&b, options.StructuredDataID, ent, fields)
+
+		b.WriteByte(' ')
+		b.WriteString(ent.Message)
+	}
+
+	return []byte(b.String())
+}
+
+// writeStructuredData writes the entry caller and fields as a RFC 5424 structured data element.
+func writeStructuredData(b *strings.Builder, id string, ent zapcore.Entry, fields map[string]interface{}) {
+	if len(fields) == 0 && !ent.Caller.Defined {
+		b.WriteString(syslogNilValue)
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	b.WriteByte('[')
+	b.WriteString(id)
+
+	if ent.Caller.Defined {
+		writeStructuredDataParam(b, "caller", ent.Caller.TrimmedPath())
+	}
+
+	for _, key := range keys {
+		value, ok := fields[key].(string)
+		if !ok {
+			data, err := json.Marshal(fields[key])
+			if err != nil {
+				data = []byte(fmt.Sprint(fields[key]))
+			}
+			value = string(data)
+		}
+
+		writeStructuredDataParam(b, key, value)
+	}
+
+	b.WriteByte(']')
+}
+
+func writeStructuredDataParam(b *strings.Builder, name, value string) {
+	b.WriteByte(' ')
+	b.WriteString(syslogName(name, syslogMaxNameLen))
+	b.WriteString(`="`)
+
+	// '"', '\' and ']' must be escaped in parameter values.
+	for _, r := range value {
+		switch r {
+		case '"', '\\', ']':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	b.WriteByte('"')
+}
+
+// syslogName sanitizes a header field or structured data name: printable US-ASCII without '=', ' ', ']' and '"', up
+// to the specified length.
+func syslogName(name string, maxLen int) string {
+	sanitized := []byte(name)
+	for i, c := range sanitized {
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			sanitized[i] = '_'
+		}
+	}
+
+	if len(sanitized) > maxLen {
+		sanitized = sanitized[:maxLen]
+	}
+
+	return string(sanitized)
+}
+
+func syslogNameOrNil(name string, maxLen int) string {
+	if name == "" {
+		return syslogNilValue
+	}
+	return syslogName(name, maxLen)
+}
@@ -0,0 +1,232 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newSyslogTestLogger creates a zap logger writing to the specified syslog sink only.
+func newSyslogTestLogger(t *testing.T, options SyslogOptions) *zap.Logger {
+	t.Helper()
+
+	sink, err := NewSyslogSink(options)
+	if err != nil {
+		t.Fatalf("NewSyslogSink() error = %v", err)
+	}
+
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	return zap.New(sink.NewCore(enc, zapcore.DebugLevel))
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	log := newSyslogTestLogger(t, SyslogOptions{
+		Network:  "udp",
+		Address:  listener.LocalAddr().String(),
+		Facility: SyslogFacilityLocal0,
+		AppName:  "app",
+		Hostname: "host",
+	})
+	log.Named("orders").Warn("Order rejected", zap.String("user", `al"ice`))
+
+	_ = listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := listener.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	msg := string(buf[:n])
+
+	// local0 warning: 16*8+4
+	if !strings.HasPrefix(msg, "<132>1 ") {
+		t.Errorf("message %q does not start with the priority and version", msg)
+	}
+	for _, want := range []string{
+		" host app ",
+		" orders [fields@32473 user=\"al\\\"ice\"] Order rejected",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message %q does not contain %q", msg, want)
+		}
+	}
+}
+
+func TestSyslogSinkTCPOctetCounting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(length))
+			if err != nil {
+				received <- "invalid frame length " + length
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	log := newSyslogTestLogger(t, SyslogOptions{Network: "tcp", Address: listener.Addr().String()})
+	log.Info("first")
+	log.Info("second")
+
+	for _, want := range []string{"first", "second"} {
+		select {
+		case msg := <-received:
+			if !strings.HasSuffix(msg, " - "+want) {
+				t.Errorf("message %q does not end with %q", msg, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %q not received", want)
+		}
+	}
+}
+
+func TestSyslogSinkFallback(t *testing.T) {
+	// a closed listener gives an address with nothing listening
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	var fallback bytes.Buffer
+	log := newSyslogTestLogger(t, SyslogOptions{Network: "tcp", Address: address, Fallback: &fallback})
+	log.Error("unreachable")
+	log.Error("still unreachable")
+
+	if got := strings.Count(fallback.String(), `"msg":"`); got != 2 {
+		t.Errorf("fallback got %d logs, want 2: %s", got, fallback.String())
+	}
+}
+
+func TestSyslogSinkWriteTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// the server accepts the connection but never reads it
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	var fallback bytes.Buffer
+	log := newSyslogTestLogger(t, SyslogOptions{
+		Network:      "tcp",
+		Address:      listener.Addr().String(),
+		WriteTimeout: 50 * time.Millisecond,
+		Fallback:     &fallback,
+	})
+
+	large := strings.Repeat("x", 64*1024)
+	deadline := time.Now().Add(10 * time.Second)
+	for fallback.Len() == 0 && time.Now().Before(deadline) {
+		log.Info(large)
+	}
+
+	if fallback.Len() == 0 {
+		t.Fatal("the logs never went to the fallback while the server was stalled")
+	}
+}
+
+func TestSyslogHeaderLengths(t *testing.T) {
+	hostname := strings.Repeat("h", 100)
+	appName := strings.Repeat("a", 60)
+
+	conn := &syslogConn{options: &SyslogOptions{Hostname: hostname, AppName: appName}}
+	msg := string(conn.format(zapcore.Entry{Message: "msg", LoggerName: strings.Repeat("n", 40)}, nil))
+
+	for _, want := range []string{
+		" " + hostname + " ",
+		" " + appName[:syslogMaxAppNameLen] + " ",
+		" " + strings.Repeat("n", syslogMaxNameLen) + " ",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message %q does not contain %q", msg, want)
+		}
+	}
+}
+
+// mutableStringer is a fmt.Stringer whose value changes.
+type mutableStringer struct{ value string }
+
+func (s *mutableStringer) String() string { return s.value }
+
+func TestSyslogCoreWith(t *testing.T) {
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := &syslogCore{LevelEnabler: zapcore.DebugLevel, enc: enc, conn: &syslogConn{options: &SyslogOptions{}}}
+
+	state := &mutableStringer{value: "pending"}
+	parent := core.With([]zapcore.Field{zap.Stringer("state", state), zap.Namespace("request")}).(*syslogCore)
+	child := parent.With([]zapcore.Field{zap.String("id", "42")}).(*syslogCore)
+	state.value = "done"
+
+	// the fields are encoded when added, in the namespace opened last
+	values := child.encodeValues([]zapcore.Field{zap.Int("attempt", 2)})
+	if values["state"] != "pending" {
+		t.Errorf("state = %v, want the value when added", values["state"])
+	}
+
+	request, ok := values["request"].(map[string]interface{})
+	if !ok || request["id"] != "42" || request["attempt"] != int64(2) || len(values) != 2 {
+		t.Errorf("values = %v, want the request fields in the request namespace", values)
+	}
+
+	if request, _ := parent.values["request"].(map[string]interface{}); len(request) != 0 {
+		t.Errorf("the parent values are modified by its child: %v", parent.values)
+	}
+}
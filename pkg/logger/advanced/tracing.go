@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceIDKey defines the key of the trace id logged by ``WithTraceContext``. Encoders map it to the field
+	// expected by their schema.
+	TraceIDKey = "trace_id"
+	// SpanIDKey defines the key of the span id logged by ``WithTraceContext``. Encoders map it to the field
+	// expected by their schema.
+	SpanIDKey = "span_id"
+)
+
+// WithTraceContext returns a logger adding the trace and span ids of the span of the specified context to its logs.
+// The logger is returned as is when the context has no span.
+func WithTraceContext(ctx context.Context, log logr.Logger) logr.Logger {
+	if keysAndValues := traceContextValues(ctx); keysAndValues != nil {
+		return log.WithValues(keysAndValues...)
+	}
+	return log
+}
+
+// traceContextValues returns the trace and span ids of the span of the specified context as key and value pairs. The
+// OpenTelemetry span is used, or the OpenCensus span when the context has none, i.e. in the applications traced with OpenCensus.
+func traceContextValues(ctx context.Context) []interface{} {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		return []interface{}{
+			TraceIDKey, spanContext.TraceID().String(),
+			SpanIDKey, spanContext.SpanID().String(),
+		}
+	}
+
+	span := octrace.FromContext(ctx)
+	if span == nil {
+		return nil
+	}
+
+	spanContext := span.SpanContext()
+	return []interface{}{
+		TraceIDKey, spanContext.TraceID.String(),
+		SpanIDKey, spanContext.SpanID.String(),
+	}
+}
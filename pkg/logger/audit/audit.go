@@ -0,0 +1,305 @@
+// Package audit records the security relevant actions of an application in an audit trail, separate from the
+// diagnostic logs.
+//
+// Every event records who (the actor) did what (the action) to which target, when and with which outcome. The events
+// are written synchronously, one JSON object per line, and are never sampled. Each record holds the hash of the
+// previous one so that a modified, removed, inserted or reordered record is detected by ``Verify``.
+//
+// The hash chain only protects the trail against someone who cannot recompute it:
+//
+// - without key, the hashes are plain SHA-256 and anyone able to write the trail can rewrite it along with its whole
+// chain. Set ``Options.Key`` to compute the hashes with HMAC-SHA256, and keep the key away from the trail.
+//
+// - the removal of the last records leaves a valid chain. Store the anchor of the trail, see ``Logger.Anchor``,
+// outside of it, i.e. in a remote store, and verify the trail up to it, see ``VerifyOptions.Anchor``.
+//
+// Whoever holds the key and the anchor store can still forge the trail.
+//
+// To use this package,
+//
+//     auditLog, err := audit.OpenWithOptions("/var/log/app/audit.log", audit.Options{Key: key})
+//     if err != nil {
+//         return err
+//     }
+//     defer auditLog.Close()
+//
+//     auditLog.Record(audit.Event{
+//         Actor:   "jdoe",
+//         Action:  "user.delete",
+//         Target:  "users/42",
+//         Outcome: audit.OutcomeSuccess,
+//     })
+//
+//     // Audit the state-changing requests of a router
+//     router.Use(audit.Middleware(auditLog, audit.MiddlewareOptions{}))
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Outcome defines the result of an audited action.
+type Outcome string
+
+const (
+	// OutcomeSuccess is the outcome of the actions that succeeded.
+	OutcomeSuccess Outcome = "success"
+	// OutcomeFailure is the outcome of the actions that failed.
+	OutcomeFailure Outcome = "failure"
+	// OutcomeDenied is the outcome of the actions that were not authorized.
+	OutcomeDenied Outcome = "denied"
+)
+
+// Event is an audited action.
+type Event struct {
+	// Time defines when the action happened. It defaults to the time the event is recorded.
+	Time time.Time
+	// Actor identifies who did the action, i.e. a user name or a service account.
+	Actor string
+	// Action defines what was done, i.e. ``user.delete`` or ``PUT``.
+	Action string
+	// Target identifies the resource the action was done to.
+	Target string
+	// Outcome defines the result of the action.
+	Outcome Outcome
+	// Details holds additional information on the action. The values must be JSON encodable.
+	Details map[string]interface{}
+}
+
+// Record is an event as written in the audit trail.
+type Record struct {
+	// Sequence is the position of the record in the audit trail, starting at 1.
+	Sequence uint64                 `json:"seq"`
+	Time     time.Time              `json:"time"`
+	Actor    string                 `json:"actor"`
+	Action   string                 `json:"action"`
+	Target   string                 `json:"target,omitempty"`
+	Outcome  Outcome                `json:"outcome"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+	// PrevHash is the hash of the previous record, empty for the first record.
+	PrevHash string `json:"prev_hash"`
+	// Hash is the hash of the record, see ``Verify``.
+	Hash string `json:"hash"`
+}
+
+// hashSuffix is the end of a record line following its hashed content: the hash is always the last field.
+const hashSuffix = `,"hash":"%s"}`
+
+// Options represents the audit logger configuration options.
+type Options struct {
+	// Sequence is the sequence of the last record of the audit trail, when the logger appends to an existing trail.
+	Sequence uint64
+	// PrevHash is the hash of the last record of the audit trail, when the logger appends to an existing trail.
+	PrevHash string
+	// Key defines the secret key of the record hashes, computed with HMAC-SHA256. The hashes are plain SHA-256 when
+	// empty, see the package documentation.
+	Key []byte
+}
+
+// Anchor identifies the last record of an audit trail, see ``Logger.Anchor``.
+type Anchor struct {
+	Sequence uint64 `json:"seq"`
+	Hash     string `json:"hash"`
+}
+
+// Logger writes the events to an audit trail. It is safe for concurrent use.
+type Logger struct {
+	mu       sync.Mutex
+	w        io.Writer
+	key      []byte
+	sequence uint64
+	prevHash string
+	// file is the audit trail file opened by the logger, and size its size, to remove a partially written record
+	file *os.File
+	size int64
+}
+
+// NewLogger creates a new audit logger writing the events to the specified writer. A record that fails to be written
+// can be partially written, and then breaks the chain of the trail: prefer ``OpenWithOptions`` for the audit trail
+// files.
+func NewLogger(w io.Writer, options Options) *Logger {
+	return &Logger{w: w, key: options.Key, sequence: options.Sequence, prevHash: options.PrevHash}
+}
+
+// Open creates a new audit logger appending the events to the specified file, see ``OpenWithOptions``.
+func Open(filename string) (*Logger, error) {
+	return OpenWithOptions(filename, Options{})
+}
+
+// OpenWithOptions creates a new audit logger appending the events to the specified file. When the file already holds
+// records, the new records are chained to the last one: the options sequence and previous hash are ignored.
+//
+// A record partially written, i.e. when the process crashed while writing it, is removed from the file. The record
+// was never acknowledged: ``Logger.Record`` did not return.
+func OpenWithOptions(filename string, options Options) (*Logger, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	last, size, err := recoverTrail(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to read the audit trail %s: %w", filename, err)
+	}
+
+	if last != nil {
+		options.Sequence = last.Sequence
+		options.PrevHash = last.Hash
+	}
+
+	l := NewLogger(f, options)
+	l.file = f
+	l.size = size
+	return l, nil
+}
+
+// recoverTrail returns the last record of the specified audit trail, nil when it is empty, and the size of the
+// trail. The end of the trail following its last complete line, i.e. a partially written record, is removed.
+func recoverTrail(f *os.File) (*Record, int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	var last []byte
+	var size int64
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				if err := f.Truncate(size); err != nil {
+					return nil, 0, fmt.Errorf("failed to remove the partial record: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		size += int64(len(line))
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			last = line
+		}
+	}
+
+	if last == nil {
+		return nil, size, nil
+	}
+
+	var record Record
+	if err := json.Unmarshal(last, &record); err != nil {
+		return nil, 0, err
+	}
+	return &record, size, nil
+}
+
+// Record writes the specified event to the audit trail. An error is returned when the event could not be written:
+// audit events must not be lost silently. When the event is written but fails to be synced, the error is returned
+// and the next events are chained to it.
+func (l *Logger) Record(event Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record := Record{
+		Sequence: l.sequence + 1,
+		Time:     event.Time.UTC(),
+		Actor:    event.Actor,
+		Action:   event.Action,
+		Target:   event.Target,
+		Outcome:  event.Outcome,
+		Details:  event.Details,
+		PrevHash: l.prevHash,
+	}
+
+	line, hash, err := encodeRecord(&record, l.key)
+	if err != nil {
+		return fmt.Errorf("failed to encode the audit event: %w", err)
+	}
+
+	// The record is written at once, and removed from the file when partially written.
+	if _, err := l.w.Write(line); err != nil {
+		if l.file != nil {
+			_ = l.file.Truncate(l.size)
+		}
+		return fmt.Errorf("failed to write the audit event: %w", err)
+	}
+
+	// The record is written, the next records are chained to it even when it fails to be synced.
+	l.sequence = record.Sequence
+	l.prevHash = hash
+	l.size += int64(len(line))
+
+	if syncer, ok := l.w.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			return fmt.Errorf("failed to sync the audit trail: %w", err)
+		}
+	}
+	return nil
+}
+
+// Anchor returns the anchor of the last record written, to store outside of the audit trail: the trail is then
+// verified up to it, see ``VerifyOptions.Anchor``.
+func (l *Logger) Anchor() Anchor {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return Anchor{Sequence: l.sequence, Hash: l.prevHash}
+}
+
+// Close closes the audit trail writer when it is a closer.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if closer, ok := l.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// encodeRecord returns the line of the specified record and its hash. The hash is the SHA-256, or the HMAC-SHA256
+// with the key, of the record encoded without its hash, which holds the hash of the previous record.
+func encodeRecord(record *Record, key []byte) ([]byte, string, error) {
+	content, err := json.Marshal(struct {
+		Record
+		Hash string `json:"hash,omitempty"`
+	}{Record: *record})
+	if err != nil {
+		return nil, "", err
+	}
+
+	hash := hashContent(content, key)
+
+	line := append(content[:len(content)-1], fmt.Sprintf(hashSuffix, hash)...)
+	return append(line, '\n'), hash, nil
+}
+
+// hashContent returns the hash of the specified record content, encoded without its hash.
+func hashContent(content []byte, key []byte) string {
+	if len(key) == 0 {
+		sum := sha256.Sum256(content)
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testKey = []byte("test-key")
+
+// recordEvents records the specified number of events.
+func recordEvents(t *testing.T, l *Logger, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		if err := l.Record(Event{Actor: "jdoe", Action: "user.delete", Target: "users/42", Outcome: OutcomeSuccess}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.log")
+
+	l, err := OpenWithOptions(filename, Options{Key: testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordEvents(t, l, 2)
+	_ = l.Close()
+
+	// the records are chained to the records of the trail
+	l, err = OpenWithOptions(filename, Options{Key: testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordEvents(t, l, 1)
+	anchor := l.Anchor()
+	_ = l.Close()
+
+	if anchor.Sequence != 3 {
+		t.Errorf("Anchor() sequence = %d, want 3", anchor.Sequence)
+	}
+
+	data, _ := os.ReadFile(filename)
+	if err := Verify(bytes.NewReader(data), VerifyOptions{Key: testKey, Anchor: &anchor}); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	// the hashes are computed with the key
+	if err := Verify(bytes.NewReader(data), VerifyOptions{}); err == nil {
+		t.Error("Verify() succeeds without the key")
+	}
+}
+
+func TestOpenPartialRecord(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.log")
+
+	l, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordEvents(t, l, 2)
+	_ = l.Close()
+
+	// the process crashed while writing the third record
+	f, _ := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0600)
+	_, _ = f.WriteString(`{"seq":3,"time":"2024-01-01T00:00:00Z","actor":"jd`)
+	_ = f.Close()
+
+	l, err = Open(filename)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	recordEvents(t, l, 1)
+	_ = l.Close()
+
+	data, _ := os.ReadFile(filename)
+	if err := Verify(bytes.NewReader(data), VerifyOptions{}); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var last Record
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil || len(lines) != 3 || last.Sequence != 3 {
+		t.Errorf("the trail holds %d records ending with %+v, want 3 records", len(lines), last)
+	}
+}
+
+// failingSyncer is a writer whose Sync fails.
+type failingSyncer struct {
+	bytes.Buffer
+}
+
+func (*failingSyncer) Sync() error { return errors.New("sync failed") }
+
+func TestRecordSyncFailure(t *testing.T) {
+	var w failingSyncer
+	l := NewLogger(&w, Options{Key: testKey})
+
+	for i := 0; i < 2; i++ {
+		if err := l.Record(Event{Actor: "jdoe", Action: "user.delete", Outcome: OutcomeSuccess}); err == nil {
+			t.Fatal("Record() succeeds although the sync fails")
+		}
+	}
+
+	// the records written are chained although they failed to be synced
+	anchor := l.Anchor()
+	if anchor.Sequence != 2 {
+		t.Errorf("Anchor() sequence = %d, want 2", anchor.Sequence)
+	}
+	if err := Verify(bytes.NewReader(w.Bytes()), VerifyOptions{Key: testKey, Anchor: &anchor}); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/mojun2021/micro-server/pkg/logger"
+)
+
+// AnonymousActor is the actor of the audited requests without identified actor.
+const AnonymousActor = "anonymous"
+
+var auditLog = logger.Log.WithName("audit")
+
+type actorKey struct{}
+
+// WithActor returns a copy of the specified context identifying the actor of the request. Authentication middlewares
+// set it before the audit middleware handles the request.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by ``WithActor``, or an empty string.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// MiddlewareOptions represents the audit middleware configuration options.
+type MiddlewareOptions struct {
+	// Methods lists the audited request methods. It defaults to the state-changing methods: ``POST``, ``PUT``,
+	// ``PATCH`` and ``DELETE``.
+	Methods []string
+	// Actor identifies the actor of a request, it must only trust authenticated identities. It defaults to the actor
+	// set by ``WithActor``, then to ``AnonymousActor``.
+	//
+	// The basic authentication user of a request is not verified by the middleware: it is only recorded as the
+	// ``claimed_actor`` detail of the event.
+	Actor func(r *http.Request) string
+}
+
+func setMiddlewareOptionsDefaults(options *MiddlewareOptions) {
+	if options.Methods == nil {
+		options.Methods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+
+	if options.Actor == nil {
+		options.Actor = defaultActor
+	}
+}
+
+func defaultActor(r *http.Request) string {
+	if actor := ActorFromContext(r.Context()); actor != "" {
+		return actor
+	}
+
+	return AnonymousActor
+}
+
+// Middleware returns a middleware recording an event for every audited request, once it is handled. The action is
+// the request method, the target is the request path and the outcome is defined by the response status:
+//
+// - ``denied`` for 401 and 403
+//
+// - ``failure`` for the other statuses from 400
+//
+// - ``success`` otherwise
+//
+// A request whose handler panics is recorded as a ``failure`` with the 500 status, the panic is not recovered.
+//
+// The events that cannot be recorded are reported as errors by the ``audit`` logger.
+func Middleware(auditLogger *Logger, options MiddlewareOptions) func(http.Handler) http.Handler {
+	setMiddlewareOptionsDefaults(&options)
+
+	methods := make(map[string]bool, len(options.Methods))
+	for _, method := range options.Methods {
+		methods[method] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !methods[r.Method] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			actor := options.Actor(r)
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			// The event is recorded even when the handler panics, as a failure. The panic is not recovered to keep
+			// its stack.
+			completed := false
+			defer func() {
+				status := recorder.status
+				if !completed {
+					status = http.StatusInternalServerError
+				}
+
+				event := Event{
+					Time:    start,
+					Actor:   actor,
+					Action:  r.Method,
+					Target:  r.URL.Path,
+					Outcome: requestOutcome(status),
+					Details: map[string]interface{}{
+						"status":      status,
+						"remote_addr": r.RemoteAddr,
+						"duration":    time.Since(start).String(),
+					},
+				}
+
+				if requestID := r.Header.Get("X-Request-Id"); requestID != "" {
+					event.Details["request_id"] = requestID
+				}
+
+				if user, _, ok := r.BasicAuth(); ok && user != "" && user != actor {
+					event.Details["claimed_actor"] = user
+				}
+
+				if !completed {
+					event.Details["panic"] = true
+				}
+
+				if err := auditLogger.Record(event); err != nil {
+					auditLog.Error(err, "Failed to record the audit event", "actor", actor, "action", event.Action,
+						"target", event.Target)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+			completed = true
+		})
+	}
+}
+
+// requestOutcome returns the outcome of a request with the specified response status.
+func requestOutcome(status int) Outcome {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return OutcomeDenied
+	case status >= http.StatusBadRequest:
+		return OutcomeFailure
+	default:
+		return OutcomeSuccess
+	}
+}
+
+// statusRecorder records the status of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the wrapped response writer, see ``http.ResponseController``.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
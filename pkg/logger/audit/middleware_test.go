@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// lastTestRecord returns the last record of the specified trail.
+func lastTestRecord(t *testing.T, trail *bytes.Buffer) Record {
+	t.Helper()
+
+	lines := strings.Split(strings.TrimSpace(trail.String()), "\n")
+	var record Record
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &record); err != nil {
+		t.Fatalf("invalid record %q: %v", lines[len(lines)-1], err)
+	}
+	return record
+}
+
+func TestMiddleware(t *testing.T) {
+	var trail bytes.Buffer
+	middleware := Middleware(NewLogger(&trail, Options{}), MiddlewareOptions{})
+
+	for _, tt := range []struct {
+		name        string
+		method      string
+		status      int
+		actor       string
+		basicAuth   string
+		wantRecord  bool
+		wantOutcome Outcome
+		wantActor   string
+	}{
+		{"read", http.MethodGet, http.StatusOK, "", "", false, "", ""},
+		{"success", http.MethodPost, http.StatusCreated, "jdoe", "", true, OutcomeSuccess, "jdoe"},
+		{"denied", http.MethodDelete, http.StatusForbidden, "jdoe", "", true, OutcomeDenied, "jdoe"},
+		{"failure", http.MethodPut, http.StatusConflict, "jdoe", "", true, OutcomeFailure, "jdoe"},
+		{"claimed actor", http.MethodPatch, http.StatusOK, "", "admin", true, OutcomeSuccess, AnonymousActor},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			trail.Reset()
+
+			handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+
+			r := httptest.NewRequest(tt.method, "/users/42", nil)
+			if tt.actor != "" {
+				r = r.WithContext(WithActor(r.Context(), tt.actor))
+			}
+			if tt.basicAuth != "" {
+				r.SetBasicAuth(tt.basicAuth, "password")
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if !tt.wantRecord {
+				if trail.Len() != 0 {
+					t.Errorf("the request is audited: %s", trail.String())
+				}
+				return
+			}
+
+			record := lastTestRecord(t, &trail)
+			if record.Actor != tt.wantActor || record.Action != tt.method || record.Target != "/users/42" ||
+				record.Outcome != tt.wantOutcome {
+				t.Errorf("the request is recorded as %+v, want %s %s %s by %s", record, tt.wantOutcome, tt.method,
+					"/users/42", tt.wantActor)
+			}
+
+			// the basic authentication user is not verified
+			if claimed := record.Details["claimed_actor"]; tt.basicAuth != "" && claimed != tt.basicAuth {
+				t.Errorf("the claimed actor is %v, want %s", claimed, tt.basicAuth)
+			}
+		})
+	}
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	var trail bytes.Buffer
+	handler := Middleware(NewLogger(&trail, Options{}), MiddlewareOptions{})(
+		http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("handler failure")
+		}),
+	)
+
+	func() {
+		defer func() {
+			// the panic goes on from the handler
+			if recovered := recover(); recovered != "handler failure" {
+				t.Errorf("recovered %v, want the handler panic", recovered)
+			}
+		}()
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", nil))
+	}()
+
+	record := lastTestRecord(t, &trail)
+	if record.Outcome != OutcomeFailure || record.Details["status"] != float64(http.StatusInternalServerError) ||
+		record.Details["panic"] != true {
+		t.Errorf("the panicking request is recorded as %+v, want a failure", record)
+	}
+}
@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxRecordSize defines the maximum size of a record line read from an audit trail.
+const maxRecordSize = 1024 * 1024
+
+// VerificationError reports the first record breaking the hash chain of an audit trail.
+type VerificationError struct {
+	// Line is the line of the record in the audit trail, starting at 1.
+	Line int
+	// Sequence is the sequence of the record, 0 when the record cannot be decoded.
+	Sequence uint64
+	// Reason describes why the record is invalid.
+	Reason string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("audit trail line %d (seq %d): %s", e.Line, e.Sequence, e.Reason)
+}
+
+// VerifyOptions represents the audit trail verification options.
+type VerifyOptions struct {
+	// Sequence and PrevHash define the sequence and hash of the record preceding the trail, the zero values verify a
+	// trail from its first record.
+	Sequence uint64
+	PrevHash string
+	// Key defines the secret key of the record hashes, see ``Options.Key``.
+	Key []byte
+	// Anchor defines the last record of the trail, stored outside of it, see ``Logger.Anchor``. The trail must hold
+	// this record: the trail was truncated when it ends before, or rewritten when the record hash differs. The end of
+	// the trail is not checked when nil.
+	Anchor *Anchor
+}
+
+// Verify checks the hash chain of the specified audit trail. It returns a ``*VerificationError`` for the first
+// record that was modified, or when a record was removed, inserted or moved, or when the trail does not hold the
+// anchor record.
+func Verify(r io.Reader, options VerifyOptions) error {
+	sequence := options.Sequence
+	prevHash := options.PrevHash
+	lineNumber := 0
+	anchored := options.Anchor == nil || options.Anchor.Sequence <= options.Sequence
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxRecordSize)
+
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return &VerificationError{Line: lineNumber, Reason: fmt.Sprintf("invalid record: %v", err)}
+		}
+
+		invalid := func(format string, args ...interface{}) error {
+			return &VerificationError{Line: lineNumber, Sequence: record.Sequence, Reason: fmt.Sprintf(format, args...)}
+		}
+
+		suffix := []byte(fmt.Sprintf(hashSuffix, record.Hash))
+		if !bytes.HasSuffix(line, suffix) {
+			return invalid("the hash is not the last field of the record")
+		}
+
+		content := append(line[:len(line)-len(suffix):len(line)-len(suffix)], '}')
+		if hash := hashContent(content, options.Key); !hmac.Equal([]byte(hash), []byte(record.Hash)) {
+			return invalid("hash mismatch, the record was modified")
+		}
+
+		if record.Sequence != sequence+1 {
+			return invalid("expected sequence %d, a record was removed or inserted", sequence+1)
+		}
+
+		if record.PrevHash != prevHash {
+			return invalid("previous hash mismatch, a record was removed or inserted")
+		}
+
+		if options.Anchor != nil && record.Sequence == options.Anchor.Sequence {
+			if record.Hash != options.Anchor.Hash {
+				return invalid("anchor hash mismatch, the trail was rewritten")
+			}
+			anchored = true
+		}
+
+		sequence = record.Sequence
+		prevHash = record.Hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if !anchored {
+		return &VerificationError{
+			Line:     lineNumber,
+			Sequence: sequence,
+			Reason:   fmt.Sprintf("the trail ends before the anchor sequence %d, it was truncated", options.Anchor.Sequence),
+		}
+	}
+
+	return nil
+}
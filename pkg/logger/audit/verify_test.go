@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestTrail returns the lines of an audit trail of the specified number of records, and its anchor.
+func newTestTrail(t *testing.T, key []byte, n int) ([]string, Anchor) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	l := NewLogger(&buf, Options{Key: key})
+	recordEvents(t, l, n)
+
+	return strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n"), l.Anchor()
+}
+
+// rechain rewrites the hash chain of the specified records with the key, as an attacker would.
+func rechain(t *testing.T, lines []string, key []byte) []string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var prevHash string
+	for i, line := range lines {
+		record := decodeTestRecord(t, line)
+		record.Sequence = uint64(i + 1)
+		record.PrevHash = prevHash
+
+		encoded, hash, err := encodeRecord(&record, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(encoded)
+		prevHash = hash
+	}
+	return strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func decodeTestRecord(t *testing.T, line string) Record {
+	t.Helper()
+
+	var record Record
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestVerify(t *testing.T) {
+	lines, anchor := newTestTrail(t, testKey, 5)
+	unkeyed, unkeyedAnchor := newTestTrail(t, nil, 5)
+
+	for _, tt := range []struct {
+		name     string
+		lines    func() []string
+		options  VerifyOptions
+		wantLine int
+	}{
+		{
+			name:    "valid",
+			lines:   func() []string { return lines },
+			options: VerifyOptions{Key: testKey, Anchor: &anchor},
+		},
+		{
+			name: "modification",
+			lines: func() []string {
+				modified := append([]string(nil), lines...)
+				modified[2] = strings.Replace(modified[2], `"actor":"jdoe"`, `"actor":"root"`, 1)
+				return modified
+			},
+			options:  VerifyOptions{Key: testKey},
+			wantLine: 3,
+		},
+		{
+			name: "reordering",
+			lines: func() []string {
+				return []string{lines[0], lines[2], lines[1], lines[3], lines[4]}
+			},
+			options:  VerifyOptions{Key: testKey},
+			wantLine: 2,
+		},
+		{
+			name: "deletion",
+			lines: func() []string {
+				return []string{lines[0], lines[1], lines[3], lines[4]}
+			},
+			options:  VerifyOptions{Key: testKey},
+			wantLine: 3,
+		},
+		{
+			name:     "truncation",
+			lines:    func() []string { return lines[:3] },
+			options:  VerifyOptions{Key: testKey, Anchor: &anchor},
+			wantLine: 3,
+		},
+		{
+			name: "recomputed chain",
+			lines: func() []string {
+				return rechain(t, []string{lines[0], lines[1], lines[3], lines[4]}, nil)
+			},
+			options:  VerifyOptions{Key: testKey},
+			wantLine: 1,
+		},
+		{
+			name: "recomputed chain without key",
+			lines: func() []string {
+				return rechain(t, []string{unkeyed[0], unkeyed[1], unkeyed[3], unkeyed[4], unkeyed[4]}, nil)
+			},
+			options:  VerifyOptions{Anchor: &unkeyedAnchor},
+			wantLine: 5,
+		},
+		{
+			name:    "following trail",
+			lines:   func() []string { return lines[2:] },
+			options: VerifyOptions{Key: testKey, Sequence: 2, PrevHash: decodeTestRecord(t, lines[1]).Hash, Anchor: &anchor},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(strings.NewReader(strings.Join(tt.lines(), "")), tt.options)
+
+			if tt.wantLine == 0 {
+				if err != nil {
+					t.Errorf("Verify() error = %v", err)
+				}
+				return
+			}
+
+			var verificationErr *VerificationError
+			if !errors.As(err, &verificationErr) || verificationErr.Line != tt.wantLine {
+				t.Errorf("Verify() error = %v, want an error at line %d", err, tt.wantLine)
+			}
+		})
+	}
+}
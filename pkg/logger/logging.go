@@ -14,6 +14,10 @@
 //     // you will still be able to use the logger.
 //     logs.SetLogger(appLog)
 //
+// ``SetLogger`` also sets the default ``log/slog`` logger and redirects the standard library global logger to the
+// logger, use ``SetLoggerWithOptions`` to opt out. The package requires Go 1.21 or later for the ``log/slog``
+// support.
+//
 //
 // At any time you can change the default logging level by setting the ``USGO_LOG_LEVEL`` environment
 // variable. The available log levels are:
@@ -28,12 +32,22 @@
 //
 // This environment variable also support value level, just like the logger ``V(level int8)`` method. i.e. if your
 // logger uses ``logger.V(17).Info("my message")`` you can set ``USGO_LOG_LEVEL=17``.
+//
+// Sensitive values are masked before being logged when a redaction policy is set, see ``Options.Redaction``. The
+// redaction is opt-in: set the policy, i.e. to ``DefaultRedactionPolicy()``, or ``USGO_LOG_REDACTION=enabled``.
+// Wrap a value with ``Redact`` to always mask it, whatever the policy:
+//
+//     appLog.Info("User logged in", "user", name, "password", logger.Redact(password))
 package logger
 
 import (
+	"context"
 	"io"
+	stdlog "log"
+	"log/slog"
 
 	"github.com/go-logr/logr"
+	"go.uber.org/zap/zapcore"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/mojun2021/micro-server/pkg/helpers/production"
@@ -42,14 +56,125 @@ import (
 
 var Log = logf.Log
 
+// Options represents the logger configuration options.
+type Options = logs.Options
+
+// Redacted wraps a value that must never reach the logs.
+type Redacted = logs.Redacted
+
+// RedactionPolicy defines which logged values are masked before being encoded, see ``Options.Redaction``.
+type RedactionPolicy = logs.RedactionPolicy
+
+// ErrorFields is implemented by the errors contributing structured fields to their logs.
+type ErrorFields = logs.ErrorFields
+
+// RecentLogs keeps the last logs in memory in order to serve them over HTTP, see ``Options.RecentLogs``.
+type RecentLogs = logs.RecentLogs
+
 func NewLogger(w io.Writer, appName string) (log logr.Logger) {
+	return NewLoggerWithOptions(w, appName, Options{})
+}
+
+// NewLoggerWithOptions creates a new logger configured with the specified options.
+func NewLoggerWithOptions(w io.Writer, appName string, options Options) (log logr.Logger) {
 	if !production.InProduction() {
-		log = logs.NewDevelopmentLogger(w)
+		log = logs.NewDevelopmentLoggerWithOptions(w, options)
 	} else {
-		log = logs.NewProductionLogger(w)
+		log = logs.NewProductionLoggerWithOptions(w, options)
 	}
 	return log.WithName(appName)
 }
 
-// SetLogger sets a concrete logging implementation for all deferred Loggers.
-func SetLogger(l logr.Logger) { logf.SetLogger(l) }
+// Flush writes the logs buffered by the asynchronous sinks. Call it on application shutdown.
+func Flush() error { return logs.Flush() }
+
+// Close writes the logs buffered by the asynchronous sinks and stops their background routines, the sinks then write
+// their logs synchronously.
+func Close() error { return logs.Close() }
+
+// NewRecentLogs creates an in-memory buffer keeping the specified number of logs.
+func NewRecentLogs(size int) *RecentLogs { return logs.NewRecentLogs(size) }
+
+// Redact wraps the specified value so that it is masked when logged.
+func Redact(value interface{}) Redacted { return logs.Redact(value) }
+
+// DefaultRedactionPolicy returns a new policy masking the passwords, tokens, secrets, JSON Web Tokens and card numbers.
+func DefaultRedactionPolicy() *RedactionPolicy { return logs.DefaultRedactionPolicy() }
+
+// WithTraceContext returns a logger adding the trace and span ids of the span of the specified context to its logs.
+func WithTraceContext(ctx context.Context, log logr.Logger) logr.Logger {
+	return logs.WithTraceContext(ctx, log)
+}
+
+// NewStdLogger returns a standard library logger logging with the specified logger, at the specified level unless
+// the logs define their own level. Use it where a ``*log.Logger`` is expected, i.e. ``http.Server.ErrorLog``.
+func NewStdLogger(log logr.Logger, defaultLevel zapcore.Level) *stdlog.Logger {
+	return logs.NewStdLogger(log, defaultLevel)
+}
+
+// NewSlogHandler returns a ``log/slog`` handler logging with the specified logger.
+func NewSlogHandler(log logr.Logger) slog.Handler { return logs.NewSlogHandler(log) }
+
+// WithDebug returns a logger writing its debug logs whatever the logger level, i.e. to diagnose a single request.
+func WithDebug(log logr.Logger) logr.Logger { return logs.WithDebug(log) }
+
+// FromContext returns the logger of the specified context, or the deferred logger when the context has none. The
+// key and value pairs are added to the returned logger.
+func FromContext(ctx context.Context, keysAndValues ...interface{}) logr.Logger {
+	return logf.FromContext(ctx, keysAndValues...)
+}
+
+// IntoContext returns a copy of the specified context holding the logger, see ``FromContext``.
+func IntoContext(ctx context.Context, log logr.Logger) context.Context {
+	return logf.IntoContext(ctx, log)
+}
+
+// SetLogger sets a concrete logging implementation for all deferred Loggers, the default ``log/slog`` logger and the
+// output of the standard library global logger.
+func SetLogger(l logr.Logger) {
+	SetLoggerWithOptions(l, SetLoggerOptions{})
+}
+
+// SetLoggerOptions defines the standard library loggers that do not log with the logger set by
+// ``SetLoggerWithOptions``.
+type SetLoggerOptions struct {
+	// DisableSlog keeps the default ``log/slog`` logger. Otherwise, it is set to the logger, see ``NewSlogHandler``.
+	DisableSlog bool
+	// DisableStdLog keeps the output of the standard library global logger. Otherwise, it is redirected to the
+	// ``stdlog`` logger, see ``NewStdLogger``. When only the slog logger is set, the standard library global logger
+	// writes to it without level mapping.
+	DisableStdLog bool
+}
+
+// SetLoggerWithOptions sets a concrete logging implementation for all deferred Loggers, and the standard library
+// loggers not disabled by the options. It returns a function restoring the previous standard library loggers, the
+// deferred Loggers keep the logger.
+//
+// Example:
+//
+//     restore := logs.SetLoggerWithOptions(appLog, logs.SetLoggerOptions{DisableStdLog: true})
+//     defer restore()
+func SetLoggerWithOptions(l logr.Logger, options SetLoggerOptions) func() {
+	logf.SetLogger(l)
+
+	flags := stdlog.Flags()
+	prefix := stdlog.Prefix()
+	output := stdlog.Writer()
+	defaultSlog := slog.Default()
+
+	if !options.DisableSlog {
+		slog.SetDefault(slog.New(logs.NewSlogHandler(l)))
+	}
+	if !options.DisableStdLog {
+		// Redirect once the slog logger is set, as slog.SetDefault redirects the standard library global logger to it.
+		logs.RedirectStdLog(l.WithName("stdlog"))
+	}
+
+	return func() {
+		// Restoring the default slog logger does not restore the output of the standard library global logger.
+		slog.SetDefault(defaultSlog)
+		stdlog.SetFlags(flags)
+		stdlog.SetPrefix(prefix)
+		stdlog.SetOutput(output)
+	}
+}
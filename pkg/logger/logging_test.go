@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"bytes"
+	stdlog "log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	logs "github.com/mojun2021/micro-server/pkg/logger/advanced"
+)
+
+// setStdLoggers sets the standard library loggers writing to the returned buffers until the test ends.
+func setStdLoggers(t *testing.T) (slogBuf, stdlogBuf *bytes.Buffer) {
+	flags, prefix, output, defaultSlog := stdlog.Flags(), stdlog.Prefix(), stdlog.Writer(), slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(defaultSlog)
+		stdlog.SetFlags(flags)
+		stdlog.SetPrefix(prefix)
+		stdlog.SetOutput(output)
+	})
+
+	slogBuf, stdlogBuf = &bytes.Buffer{}, &bytes.Buffer{}
+	slog.SetDefault(slog.New(slog.NewTextHandler(slogBuf, nil)))
+	stdlog.SetOutput(stdlogBuf)
+	return slogBuf, stdlogBuf
+}
+
+// logLines returns the lines of the logs written to the specified buffer.
+func logLines(buf *bytes.Buffer) []string {
+	if buf.Len() == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(buf.String()), "\n")
+}
+
+func TestSetLogger(t *testing.T) {
+	setStdLoggers(t)
+
+	var buf bytes.Buffer
+	SetLogger(logs.NewProductionLogger(&buf))
+	slog.Info("Slog log")
+	stdlog.Print("[WARN] Standard library log")
+
+	// the standard library loggers log with the logger
+	lines := logLines(&buf)
+	if len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], `{"level":"info"`) || !strings.HasSuffix(lines[0], `"msg":"Slog log"}`) ||
+		!strings.HasPrefix(lines[1], `{"level":"warn"`) || !strings.HasSuffix(lines[1], `"logger":"stdlog","msg":"Standard library log"}`) {
+		t.Errorf("the logger got %q, want the slog log and the standard library warning", lines)
+	}
+}
+
+func TestSetLoggerWithOptions(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		options    SetLoggerOptions
+		want       []string
+		wantSlog   bool
+		wantStdlog bool
+	}{
+		{"default", SetLoggerOptions{}, []string{`"msg":"Slog log"}`, `"logger":"stdlog","msg":"Standard library log"}`}, false, false},
+		{"slog disabled", SetLoggerOptions{DisableSlog: true}, []string{`"logger":"stdlog","msg":"Standard library log"}`}, true, false},
+		{"stdlog disabled", SetLoggerOptions{DisableStdLog: true}, []string{`"msg":"Slog log"}`, `"msg":"[WARN] Standard library log"}`}, false, false},
+		{"both disabled", SetLoggerOptions{DisableSlog: true, DisableStdLog: true}, nil, true, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			slogBuf, stdlogBuf := setStdLoggers(t)
+			defaultSlog := slog.Default()
+
+			var buf bytes.Buffer
+			restore := SetLoggerWithOptions(logs.NewProductionLogger(&buf), tt.options)
+			slog.Info("Slog log")
+			stdlog.Print("[WARN] Standard library log")
+			restore()
+
+			lines := logLines(&buf)
+			if len(lines) != len(tt.want) {
+				t.Fatalf("the logger got %q, want %d logs", lines, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.HasSuffix(lines[i], want) {
+					t.Errorf("the logger got %q, want it to end with %s", lines[i], want)
+				}
+			}
+
+			// the disabled standard library loggers keep their output
+			if got := strings.Contains(slogBuf.String(), "Slog log"); got != tt.wantSlog {
+				t.Errorf("the previous slog logger got the log: %v, want %v", got, tt.wantSlog)
+			}
+			if got := strings.Contains(stdlogBuf.String(), "Standard library log"); got != tt.wantStdlog {
+				t.Errorf("the previous standard library logger output got the log: %v, want %v", got, tt.wantStdlog)
+			}
+
+			if stdlog.Writer() != stdlogBuf || slog.Default() != defaultSlog {
+				t.Error("the standard library loggers are not restored")
+			}
+		})
+	}
+}
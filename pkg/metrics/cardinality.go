@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mojun2021/micro-server/pkg/logger"
+)
+
+const (
+	// OverflowLabelValue defines the label value the values over the limit of a label are folded into.
+	OverflowLabelValue = "other"
+	// DefaultMaxLabelValues defines the default maximum number of values of a label.
+	DefaultMaxLabelValues = 100
+	// LabelOverflowMetricName defines the name of the counter of the label values folded by the cardinality guards.
+	// It has 2 labels: ``metric`` and ``label``.
+	LabelOverflowMetricName = "metrics_label_overflow_total"
+)
+
+var cardinalityLog = logger.Log.WithName("metrics").WithName("cardinality")
+
+// CardinalityGuardOptions represents the cardinality guard configuration options.
+type CardinalityGuardOptions struct {
+	// MaxValues defines the maximum number of values of every label. It defaults to ``DefaultMaxLabelValues``.
+	MaxValues int
+	// LabelMaxValues overrides the maximum number of values of some labels, by label name.
+	LabelMaxValues map[string]int
+	// Registerer defines where the ``metrics_label_overflow_total`` counter is registered, i.e. the registry of the
+	// Prometheus exporter, see ``Exporter.Registry``. It is required.
+	Registerer prometheus.Registerer
+}
+
+func setCardinalityGuardOptionsDefaults(options *CardinalityGuardOptions) {
+	if options.MaxValues <= 0 {
+		options.MaxValues = DefaultMaxLabelValues
+	}
+}
+
+// CardinalityGuard limits the number of values of the labels of a metric, so that a misbehaving route or a client
+// supplied value cannot explode the number of series. Once a label has reached its limit, its new values are folded
+// into ``OverflowLabelValue``.
+//
+// The folded values are counted by the ``metrics_label_overflow_total`` counter, and the first folded value of a
+// label is reported by the ``metrics.cardinality`` logger.
+//
+// Example:
+//
+//    guard, err := metrics.NewCardinalityGuard("orders_total", []string{"customer"}, metrics.CardinalityGuardOptions{
+//       Registerer: exporter.Registry(),
+//    })
+//    ...
+//    orders.WithLabelValues(guard.Values(customer)...).Inc()
+type CardinalityGuard struct {
+	metric   string
+	labels   []string
+	limits   []int
+	overflow *prometheus.CounterVec
+
+	mu       sync.RWMutex
+	values   []map[string]bool
+	reported []bool
+}
+
+// NewCardinalityGuard creates a new cardinality guard of the labels of the specified metric.
+func NewCardinalityGuard(metric string, labels []string, options CardinalityGuardOptions) (*CardinalityGuard, error) {
+	setCardinalityGuardOptionsDefaults(&options)
+
+	if options.Registerer == nil {
+		return nil, errors.New("missing cardinality guard registerer")
+	}
+
+	overflow := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: LabelOverflowMetricName,
+		Help: "The number of label values folded by the cardinality guards.",
+	}, []string{"metric", "label"})
+
+	// the counter is shared by the guards of the registerer
+	if err := options.Registerer.Register(overflow); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if !errors.As(err, &alreadyRegistered) {
+			return nil, err
+		}
+
+		existing, ok := alreadyRegistered.ExistingCollector.(*prometheus.CounterVec)
+		if !ok {
+			return nil, err
+		}
+		overflow = existing
+	}
+
+	g := &CardinalityGuard{
+		metric:   metric,
+		labels:   labels,
+		limits:   make([]int, len(labels)),
+		overflow: overflow,
+		values:   make([]map[string]bool, len(labels)),
+		reported: make([]bool, len(labels)),
+	}
+
+	for i, label := range labels {
+		g.limits[i] = options.MaxValues
+		if limit, ok := options.LabelMaxValues[label]; ok && limit > 0 {
+			g.limits[i] = limit
+		}
+		g.values[i] = make(map[string]bool)
+	}
+
+	return g, nil
+}
+
+// Values returns the specified label values, in the order of the guard labels, with the values over the limit of
+// their label folded into ``OverflowLabelValue``.
+func (g *CardinalityGuard) Values(values ...string) []string {
+	guarded := append([]string(nil), values...)
+
+	for i, value := range guarded {
+		if i >= len(g.labels) || value == OverflowLabelValue {
+			continue
+		}
+
+		g.mu.RLock()
+		seen := g.values[i][value]
+		g.mu.RUnlock()
+
+		if !seen && !g.add(i, value) {
+			guarded[i] = OverflowLabelValue
+		}
+	}
+
+	return guarded
+}
+
+// add adds a value to a label, it returns false and reports the overflow when the label has reached its limit.
+func (g *CardinalityGuard) add(i int, value string) bool {
+	g.mu.Lock()
+	if g.values[i][value] || len(g.values[i]) < g.limits[i] {
+		g.values[i][value] = true
+		g.mu.Unlock()
+		return true
+	}
+
+	report := !g.reported[i]
+	g.reported[i] = true
+	g.mu.Unlock()
+
+	g.overflow.WithLabelValues(g.metric, g.labels[i]).Inc()
+	if report {
+		cardinalityLog.Info("Folding the label values over the limit", "metric", g.metric, "label", g.labels[i],
+			"limit", g.limits[i], "value", value, "folded_into", OverflowLabelValue)
+	}
+
+	return false
+}
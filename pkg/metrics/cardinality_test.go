@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCardinalityGuard(t *testing.T) {
+	if _, err := NewCardinalityGuard("orders_total", []string{"customer"}, CardinalityGuardOptions{}); err == nil {
+		t.Error("NewCardinalityGuard() succeeds without registerer")
+	}
+
+	// the guards of two registries do not share their counter
+	for i := 0; i < 2; i++ {
+		registry := prometheus.NewRegistry()
+		guard, err := NewCardinalityGuard("orders_total", []string{"customer"}, CardinalityGuardOptions{
+			MaxValues:  2,
+			Registerer: registry,
+		})
+		if err != nil {
+			t.Fatalf("NewCardinalityGuard() error = %v", err)
+		}
+
+		for _, customer := range []string{"a", "b", "a", "c", "d"} {
+			got := guard.Values(customer)[0]
+			want := customer
+			if customer == "c" || customer == "d" {
+				want = OverflowLabelValue
+			}
+			if got != want {
+				t.Errorf("Values(%q) = %q, want %q", customer, got, want)
+			}
+		}
+
+		if got := testutil.ToFloat64(guard.overflow.WithLabelValues("orders_total", "customer")); got != 2 {
+			t.Errorf("overflow = %v, want 2", got)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"errors"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	// BuildInfoMetricName defines the name of the build information gauge. Its value is always 1, it has 3 labels:
+	// ``version``, ``revision`` and ``goversion``.
+	BuildInfoMetricName = "build_info"
+	// StartTimeMetricName defines the name of the start time gauge, in seconds since the Unix epoch.
+	StartTimeMetricName = "start_time_seconds"
+	// UptimeMetricName defines the name of the uptime gauge, in seconds.
+	UptimeMetricName = "uptime_seconds"
+)
+
+// startTime is the time the program started, the package being initialized at startup.
+var startTime = time.Now()
+
+// CollectorsOptions defines the collectors registered along with the exporter metrics. They are all registered by
+// default.
+type CollectorsOptions struct {
+	// DisableProcess disables the process collector: CPU, memory, file descriptors and start time of the process.
+	DisableProcess bool
+	// DisableGoRuntime disables the Go runtime collector: memory statistics, goroutines, and the ``runtime/metrics``
+	// GC and scheduler metrics, i.e. the ``go_sched_latencies_seconds`` and ``go_gc_pauses_seconds`` histograms.
+	DisableGoRuntime bool
+	// DisableBuildInfo disables the ``build_info`` gauge.
+	DisableBuildInfo bool
+	// DisableUptime disables the ``start_time_seconds`` and ``uptime_seconds`` gauges.
+	DisableUptime bool
+	// BuildInfo overrides the labels of the ``build_info`` gauge.
+	BuildInfo BuildInfo
+}
+
+// BuildInfo represents the labels of the ``build_info`` gauge. The empty fields default to the build information
+// embedded in the binary, see ``debug.ReadBuildInfo``.
+type BuildInfo struct {
+	// Version defines the version of the program. It defaults to the version of the main module, i.e. ``(devel)``.
+	Version string
+	// Revision defines the source revision of the program. It defaults to the VCS revision of the build.
+	Revision string
+	// GoVersion defines the Go version the program is built with.
+	GoVersion string
+}
+
+func setBuildInfoDefaults(info *BuildInfo) {
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" {
+			info.Version = buildInfo.Main.Version
+		}
+
+		if info.Revision == "" {
+			for _, setting := range buildInfo.Settings {
+				if setting.Key == "vcs.revision" {
+					info.Revision = setting.Value
+				}
+			}
+		}
+
+		if info.GoVersion == "" {
+			info.GoVersion = buildInfo.GoVersion
+		}
+	}
+
+	if info.GoVersion == "" {
+		info.GoVersion = runtime.Version()
+	}
+}
+
+// RegisterCollectors registers the enabled collectors with the specified registerer, i.e. the registry of
+// ``NewPrometheusExporterFromRegistry``. The collectors already registered with the same metrics are skipped.
+func RegisterCollectors(registerer prometheus.Registerer, options CollectorsOptions) error {
+	var cs []prometheus.Collector
+
+	if !options.DisableProcess {
+		cs = append(cs, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+
+	if !options.DisableGoRuntime {
+		cs = append(cs, collectors.NewGoCollector(
+			collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsGC, collectors.MetricsScheduler),
+		))
+	}
+
+	if !options.DisableBuildInfo {
+		info := options.BuildInfo
+		setBuildInfoDefaults(&info)
+
+		buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: BuildInfoMetricName,
+			Help: "The build information of the program, its value is always 1.",
+			ConstLabels: prometheus.Labels{
+				"version":   info.Version,
+				"revision":  info.Revision,
+				"goversion": info.GoVersion,
+			},
+		})
+		buildInfo.Set(1)
+		cs = append(cs, buildInfo)
+	}
+
+	if !options.DisableUptime {
+		cs = append(
+			cs,
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: StartTimeMetricName,
+				Help: "The start time of the program since the Unix epoch, in seconds.",
+			}, func() float64 {
+				return float64(startTime.UnixNano()) / float64(time.Second)
+			}),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: UptimeMetricName,
+				Help: "The time since the program started, in seconds.",
+			}, func() float64 {
+				return time.Since(startTime).Seconds()
+			}),
+		)
+	}
+
+	for _, c := range cs {
+		if err := registerer.Register(c); err != nil {
+			var alreadyRegistered prometheus.AlreadyRegisteredError
+			if !errors.As(err, &alreadyRegistered) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
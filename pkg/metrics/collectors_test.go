@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gatherFamilies returns the metric families registered with the collectors of the specified options, by name.
+func gatherFamilies(t *testing.T, options CollectorsOptions) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	if err := RegisterCollectors(registry, options); err != nil {
+		t.Fatalf("RegisterCollectors() error = %v", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	return byName
+}
+
+func TestRegisterCollectors(t *testing.T) {
+	families := gatherFamilies(t, CollectorsOptions{})
+
+	for _, name := range []string{
+		BuildInfoMetricName,
+		StartTimeMetricName,
+		UptimeMetricName,
+		"process_start_time_seconds",
+		"go_goroutines",
+		"go_sched_latencies_seconds",
+		"go_gc_pauses_seconds",
+	} {
+		if _, ok := families[name]; !ok {
+			t.Errorf("the collectors do not export %s", name)
+		}
+	}
+
+	// the start time is the package initialization time, the uptime is measured when gathered
+	start := families[StartTimeMetricName].GetMetric()[0].GetGauge().GetValue()
+	if want := float64(startTime.UnixNano()) / float64(time.Second); start != want {
+		t.Errorf("%s = %v, want %v", StartTimeMetricName, start, want)
+	}
+	uptime := families[UptimeMetricName].GetMetric()[0].GetGauge().GetValue()
+	if uptime <= 0 || uptime > time.Since(startTime).Seconds() {
+		t.Errorf("%s = %v, want the time since %v", UptimeMetricName, uptime, startTime)
+	}
+}
+
+func TestRegisterCollectorsBuildInfo(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		buildInfo BuildInfo
+		want      map[string]string
+	}{
+		{"default", BuildInfo{}, map[string]string{"version": "", "revision": "", "goversion": runtime.Version()}},
+		{"overridden", BuildInfo{Version: "v1.2.3", Revision: "abc123", GoVersion: "go1.21.0"}, map[string]string{
+			"version": "v1.2.3", "revision": "abc123", "goversion": "go1.21.0",
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			families := gatherFamilies(t, CollectorsOptions{BuildInfo: tt.buildInfo})
+
+			metric := families[BuildInfoMetricName].GetMetric()[0]
+			if metric.GetGauge().GetValue() != 1 {
+				t.Errorf("%s = %v, want 1", BuildInfoMetricName, metric.GetGauge().GetValue())
+			}
+
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			for name, want := range tt.want {
+				// the test binary has no version nor revision, the defaults are only checked when known
+				if got, ok := labels[name]; !ok || (want != "" && got != want) {
+					t.Errorf("%s label %s = %q, want %q", BuildInfoMetricName, name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterCollectorsDisabled(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		options CollectorsOptions
+		absent  []string
+		present []string
+	}{
+		{"process", CollectorsOptions{DisableProcess: true}, []string{"process_start_time_seconds"}, []string{"go_goroutines"}},
+		{"go runtime", CollectorsOptions{DisableGoRuntime: true}, []string{"go_goroutines", "go_gc_pauses_seconds"}, []string{BuildInfoMetricName}},
+		{"build info", CollectorsOptions{DisableBuildInfo: true}, []string{BuildInfoMetricName}, []string{UptimeMetricName}},
+		{"uptime", CollectorsOptions{DisableUptime: true}, []string{StartTimeMetricName, UptimeMetricName}, []string{BuildInfoMetricName}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			families := gatherFamilies(t, tt.options)
+
+			for _, name := range tt.absent {
+				if _, ok := families[name]; ok {
+					t.Errorf("the collectors export %s", name)
+				}
+			}
+			for _, name := range tt.present {
+				if _, ok := families[name]; !ok {
+					t.Errorf("the collectors do not export %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterCollectorsTwice(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	// the collectors already registered are skipped
+	for i := 0; i < 2; i++ {
+		if err := RegisterCollectors(registry, CollectorsOptions{}); err != nil {
+			t.Fatalf("RegisterCollectors() error = %v", err)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// DeclaredMetricsMeterName defines the instrumentation name of the meter of the declared metrics.
+const DeclaredMetricsMeterName = "github.com/mojun2021/micro-server/pkg/metrics"
+
+// DefaultHistogramBuckets defines the default upper bounds of the histogram buckets, suited to durations in seconds.
+var DefaultHistogramBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// The declared metrics are bound to the meter of every exporter, see ``bindDeclaredMetrics``: the instruments of a
+// metric are created with the meters of the exporters alive when it is declared, and with the meters of the
+// exporters created later.
+var (
+	declaredMu      sync.Mutex
+	declaredMetrics = make(map[string]declaredMetric)
+	declaredMeters  = make(map[*declaredBinding]metric.Meter)
+
+	// validationMeter checks the instruments when declared, it has no reader and records nothing
+	validationMeter = sdkmetric.NewMeterProvider().Meter(DeclaredMetricsMeterName)
+)
+
+// declaredMetric is a declared counter, gauge or histogram.
+type declaredMetric interface {
+	bind(meter metric.Meter) error
+	unbind(meter metric.Meter)
+}
+
+// declare binds a metric to the meters of the exporters. It fails when the metric name is already declared, or when
+// the metric is not a valid OpenTelemetry instrument, i.e. its name is invalid.
+func declare(name string, m declaredMetric) error {
+	declaredMu.Lock()
+	defer declaredMu.Unlock()
+
+	if _, ok := declaredMetrics[name]; ok {
+		return fmt.Errorf("metric %q already declared", name)
+	}
+
+	if err := m.bind(validationMeter); err != nil {
+		return fmt.Errorf("failed to declare the metric %q: %w", name, err)
+	}
+	m.unbind(validationMeter)
+
+	for _, meter := range declaredMeters {
+		if err := m.bind(meter); err != nil {
+			for _, bound := range declaredMeters {
+				m.unbind(bound)
+			}
+			return fmt.Errorf("failed to declare the metric %q: %w", name, err)
+		}
+	}
+
+	declaredMetrics[name] = m
+	return nil
+}
+
+// undeclare removes the specified metric from the declared metrics and unbinds it from the meters of the exporters.
+// The name can then be declared again, i.e. by the next run of a test.
+func undeclare(name string) {
+	declaredMu.Lock()
+	defer declaredMu.Unlock()
+
+	m, ok := declaredMetrics[name]
+	if !ok {
+		return
+	}
+
+	delete(declaredMetrics, name)
+	for _, meter := range declaredMeters {
+		m.unbind(meter)
+	}
+}
+
+// declaredBinding binds the declared metrics to the meter provider of an exporter, see ``bindDeclaredMetrics``.
+type declaredBinding struct {
+	once sync.Once
+}
+
+// bindDeclaredMetrics creates the instruments of the declared metrics with the specified meter provider, the metrics
+// declared later are bound as well until the returned binding is released.
+func bindDeclaredMetrics(provider metric.MeterProvider) (*declaredBinding, error) {
+	declaredMu.Lock()
+	defer declaredMu.Unlock()
+
+	meter := provider.Meter(DeclaredMetricsMeterName)
+	for name, m := range declaredMetrics {
+		if err := m.bind(meter); err != nil {
+			for _, bound := range declaredMetrics {
+				bound.unbind(meter)
+			}
+			return nil, fmt.Errorf("failed to bind the metric %q: %w", name, err)
+		}
+	}
+
+	b := &declaredBinding{}
+	declaredMeters[b] = meter
+	return b, nil
+}
+
+// release stops recording the declared metrics with the meter provider of the binding, it can be called several
+// times.
+func (b *declaredBinding) release() {
+	b.once.Do(func() {
+		declaredMu.Lock()
+		defer declaredMu.Unlock()
+
+		meter := declaredMeters[b]
+		delete(declaredMeters, b)
+		for _, m := range declaredMetrics {
+			m.unbind(meter)
+		}
+	})
+}
+
+// instruments holds the instruments of a declared metric, one per bound meter.
+type instruments[T any] struct {
+	create func(meter metric.Meter) (T, error)
+	labels map[attribute.Key]bool
+
+	mu     sync.RWMutex
+	values map[metric.Meter]T
+}
+
+func newInstruments[T any](labels []Label, create func(meter metric.Meter) (T, error)) *instruments[T] {
+	keys := make(map[attribute.Key]bool, len(labels))
+	for _, l := range labels {
+		keys[l.key] = true
+	}
+
+	return &instruments[T]{create: create, labels: keys, values: make(map[metric.Meter]T)}
+}
+
+func (i *instruments[T]) bind(meter metric.Meter) error {
+	instrument, err := i.create(meter)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.values[meter] = instrument
+	return nil
+}
+
+func (i *instruments[T]) unbind(meter metric.Meter) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.values, meter)
+}
+
+// record records a measurement with every instrument. The values of the labels that are not labels of the metric are
+// dropped.
+func (i *instruments[T]) record(values []LabelValue, measure func(instrument T, options metric.MeasurementOption)) {
+	attributes := make([]attribute.KeyValue, 0, len(values))
+	for _, v := range values {
+		if i.labels[v.kv.Key] {
+			attributes = append(attributes, v.kv)
+		}
+	}
+	options := metric.WithAttributeSet(attribute.NewSet(attributes...))
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, instrument := range i.values {
+		measure(instrument, options)
+	}
+}
+
+// Label is a label of the declared metrics. Declare it once, with the metrics using it:
+//
+//    var (
+//        routeLabel  = metrics.NewLabel("route")
+//        requests, _ = metrics.NewCounter("myapp/requests", "The number of requests", routeLabel)
+//    )
+//
+//    requests.Inc(ctx, routeLabel.Value("/users"))
+type Label struct {
+	key attribute.Key
+}
+
+// LabelValue is the value of a label, see ``Label.Value``.
+type LabelValue struct {
+	kv attribute.KeyValue
+}
+
+// NewLabel declares a label.
+func NewLabel(name string) Label {
+	return Label{key: attribute.Key(name)}
+}
+
+// Name returns the name of the label.
+func (l Label) Name() string {
+	return string(l.key)
+}
+
+// Value returns the specified value of the label.
+func (l Label) Value(value string) LabelValue {
+	return LabelValue{kv: l.key.String(value)}
+}
+
+// Counter is a declared counter, see ``NewCounter``.
+type Counter struct {
+	instruments *instruments[metric.Int64Counter]
+}
+
+// NewCounter declares a counter with the specified labels. The counter is recorded with the meter of every exporter,
+// there is no need to give it to the exporters. It fails when a metric with the same name is already declared.
+func NewCounter(name, description string, labels ...Label) (*Counter, error) {
+	c := &Counter{instruments: newInstruments(labels, func(meter metric.Meter) (metric.Int64Counter, error) {
+		return meter.Int64Counter(name, metric.WithDescription(description))
+	})}
+
+	if err := declare(name, c.instruments); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Inc increments the counter.
+func (c *Counter) Inc(ctx context.Context, values ...LabelValue) {
+	c.Add(ctx, 1, values...)
+}
+
+// Add adds the specified increment to the counter.
+func (c *Counter) Add(ctx context.Context, increment int64, values ...LabelValue) {
+	c.instruments.record(values, func(instrument metric.Int64Counter, options metric.MeasurementOption) {
+		instrument.Add(ctx, increment, options)
+	})
+}
+
+// Gauge is a declared gauge, see ``NewGauge``.
+type Gauge struct {
+	instruments *instruments[metric.Float64Gauge]
+}
+
+// NewGauge declares a gauge with the specified labels, it exports the last value set. The gauge is recorded with the
+// meter of every exporter, there is no need to give it to the exporters. It fails when a metric with the same name
+// is already declared.
+func NewGauge(name, description string, labels ...Label) (*Gauge, error) {
+	g := &Gauge{instruments: newInstruments(labels, func(meter metric.Meter) (metric.Float64Gauge, error) {
+		return meter.Float64Gauge(name, metric.WithDescription(description))
+	})}
+
+	if err := declare(name, g.instruments); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Set sets the value of the gauge.
+func (g *Gauge) Set(ctx context.Context, value float64, values ...LabelValue) {
+	g.instruments.record(values, func(instrument metric.Float64Gauge, options metric.MeasurementOption) {
+		instrument.Record(ctx, value, options)
+	})
+}
+
+// Histogram is a declared histogram, see ``NewHistogram``.
+type Histogram struct {
+	instruments *instruments[metric.Float64Histogram]
+}
+
+// NewHistogram declares a histogram with the specified bucket upper bounds and labels. The buckets default to
+// ``DefaultHistogramBuckets`` when empty. The histogram is recorded with the meter of every exporter, there is no need
+// to give it to the exporters. It fails when a metric with the same name is already declared.
+func NewHistogram(name, description string, buckets []float64, labels ...Label) (*Histogram, error) {
+	if len(buckets) == 0 {
+		buckets = DefaultHistogramBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+
+	h := &Histogram{instruments: newInstruments(labels, func(meter metric.Meter) (metric.Float64Histogram, error) {
+		return meter.Float64Histogram(name, metric.WithDescription(description),
+			metric.WithExplicitBucketBoundaries(buckets...))
+	})}
+
+	if err := declare(name, h.instruments); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Observe adds a value to the histogram.
+func (h *Histogram) Observe(ctx context.Context, value float64, values ...LabelValue) {
+	h.instruments.record(values, func(instrument metric.Float64Histogram, options metric.MeasurementOption) {
+		instrument.Record(ctx, value, options)
+	})
+}
+
+// ObserveSince adds the duration since the specified start to the histogram, in seconds.
+//
+// Example:
+//
+//    defer latency.ObserveSince(ctx, time.Now(), routeLabel.Value(route))
+func (h *Histogram) ObserveSince(ctx context.Context, start time.Time, values ...LabelValue) {
+	h.Observe(ctx, time.Since(start).Seconds(), values...)
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// undeclareTestMetrics undeclares the specified metrics when the test ends, so that the tests can be run several
+// times.
+func undeclareTestMetrics(t *testing.T, names ...string) {
+	t.Cleanup(func() {
+		for _, name := range names {
+			undeclare(name)
+		}
+	})
+}
+
+func TestDeclaredMetrics(t *testing.T) {
+	undeclareTestMetrics(t, "test/declared_requests", "test/declared_inflight", "test/declared_latency")
+
+	ctx := context.Background()
+
+	first, err := NewPrometheusExporterWithOptions(PrometheusOptions{DisableDefaultRegistry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	// the metrics are recorded with the exporters created before and after they are declared
+	routeLabel := NewLabel("route")
+	requests, err := NewCounter("test/declared_requests", "The number of requests", routeLabel)
+	if err != nil {
+		t.Fatalf("NewCounter() error = %v", err)
+	}
+	inflight, err := NewGauge("test/declared_inflight", "The number of requests in flight")
+	if err != nil {
+		t.Fatalf("NewGauge() error = %v", err)
+	}
+	latency, err := NewHistogram("test/declared_latency", "The latency of the requests", []float64{.1, 1}, routeLabel)
+	if err != nil {
+		t.Fatalf("NewHistogram() error = %v", err)
+	}
+
+	second, err := NewPrometheusExporterWithOptions(PrometheusOptions{DisableDefaultRegistry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	requests.Inc(ctx, routeLabel.Value("/users"))
+	requests.Add(ctx, 2, routeLabel.Value("/users"), NewLabel("undeclared").Value("dropped"))
+	inflight.Set(ctx, 3)
+	inflight.Set(ctx, 5)
+	latency.Observe(ctx, .5, routeLabel.Value("/users"))
+	latency.ObserveSince(ctx, time.Now(), routeLabel.Value("/users"))
+
+	for name, exporter := range map[string]*Exporter{"first": first, "second": second} {
+		body := scrape(t, exporter)
+		for _, want := range []string{
+			`test_declared_requests{route="/users"} 3`,
+			`test_declared_inflight 5`,
+			`test_declared_latency_bucket{route="/users",le="0.1"} 1`,
+			`test_declared_latency_bucket{route="/users",le="1"} 2`,
+			`test_declared_latency_count{route="/users"} 2`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("the %s exporter does not serve %s:\n%s", name, want, body)
+			}
+		}
+		if strings.Contains(body, "undeclared") {
+			t.Errorf("the %s exporter serves the undeclared label:\n%s", name, body)
+		}
+	}
+
+	// the closed exporters stop recording the metrics
+	_ = first.Close()
+	requests.Inc(ctx, routeLabel.Value("/users"))
+	if got := len(requests.instruments.values); got != 1 {
+		t.Errorf("the counter is recorded with %d meters after close, want 1", got)
+	}
+	if body := scrape(t, second); !strings.Contains(body, `test_declared_requests{route="/users"} 4`) {
+		t.Errorf("the counter is not recorded after the other exporter is closed:\n%s", body)
+	}
+}
+
+func TestDeclaredMetricsInvalid(t *testing.T) {
+	undeclareTestMetrics(t, "test/declared_duplicate")
+
+	if _, err := NewCounter("test/declared_duplicate", ""); err != nil {
+		t.Fatalf("NewCounter() error = %v", err)
+	}
+
+	if _, err := NewCounter("test/declared_duplicate", ""); err == nil {
+		t.Error("NewCounter() succeeds with a declared name")
+	}
+	if _, err := NewHistogram("test/declared_duplicate", "", nil); err == nil {
+		t.Error("NewHistogram() succeeds with the name of a declared counter")
+	}
+	if _, err := NewGauge("1 invalid name", ""); err == nil {
+		t.Error("NewGauge() succeeds with an invalid name")
+	}
+}
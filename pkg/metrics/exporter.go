@@ -1,55 +1,203 @@
 package metrics
 
 import (
-	ocprometheus "contrib.go.opencensus.io/exporter/prometheus"
+	"context"
+	"net/http"
+	"sort"
+
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 	ocview "go.opencensus.io/stats/view"
-
-	"github.com/mojun2021/micro-server/pkg/logger"
-	advlogs "github.com/mojun2021/micro-server/pkg/logger/advanced"
+	"go.opentelemetry.io/otel"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 )
 
-var log = logger.Log.WithName("metrics")
+// Exporter is a Prometheus metrics exporter based on OpenTelemetry. It serves the metrics of its registry over HTTP,
+// see ``routes.AddMetrics``.
+//
+// The metrics are recorded with the OpenTelemetry meters of ``MeterProvider``. The OpenCensus views of the exporter,
+// i.e. ``LogCountView``, are bridged to OpenTelemetry and exported along with them during the migration.
+//
+// Every exporter owns its registry, its meter provider and its views: several exporters, i.e. of several servers of
+// a process, do not export the metrics of each other. Close the exporter to release them.
+//
+// The declared metrics, see ``NewCounter``, ``NewGauge`` and ``NewHistogram``, are exported by every exporter.
+type Exporter struct {
+	registry *prometheus.Registry
+	provider *sdkmetric.MeterProvider
+	views    *viewSet
+	declared *declaredBinding
+	handler  http.Handler
+
+	// previous is the global meter provider replaced by the exporter, restored on shutdown
+	previous metric.MeterProvider
+	global   bool
+}
 
-// NewPrometheusExporter creates a new Prometheus metrics exporter.
-func NewPrometheusExporter(views ...*ocview.View) (*ocprometheus.Exporter, error) {
-	// Create the Prometheus metrics registry and register collectors
-	metricsRegistry, ok := prometheus.DefaultRegisterer.(*prometheus.Registry)
-	if !ok {
-		metricsRegistry = prometheus.NewRegistry()
-		metricsRegistry.MustRegister(collectors.NewGoCollector())
+// PrometheusOptions represents the Prometheus metrics exporter configuration options.
+type PrometheusOptions struct {
+	// Collectors defines the collectors registered with the exporter registry.
+	Collectors CollectorsOptions
+	// DisableDefaultRegistry stops serving the metrics of the default Prometheus registry along with the ones of the
+	// exporter registry, i.e. the metrics registered with ``promauto`` or ``prometheus.MustRegister``. The metrics of
+	// the exporter registry win over the metrics of the default registry with the same name.
+	DisableDefaultRegistry bool
+	// GlobalMeterProvider registers the exporter as the global OpenTelemetry meter provider. The previous global meter
+	// provider is restored when the exporter is shut down, unless another one was registered in between.
+	GlobalMeterProvider bool
+}
+
+// NewPrometheusExporter creates a new Prometheus metrics exporter with the default options, see
+// ``NewPrometheusExporterWithOptions``.
+func NewPrometheusExporter(views ...*ocview.View) (*Exporter, error) {
+	return NewPrometheusExporterWithOptions(PrometheusOptions{}, views...)
+}
+
+// NewPrometheusExporterWithOptions creates a new Prometheus metrics exporter, with a new registry holding the enabled
+// collectors, see ``CollectorsOptions``. The metrics of the default Prometheus registry are served along with the
+// ones of the exporter registry unless ``DisableDefaultRegistry`` is set.
+//
+// The exporter is not registered as the global OpenTelemetry meter provider unless ``GlobalMeterProvider`` is set:
+// record the metrics with the meters of ``MeterProvider``.
+func NewPrometheusExporterWithOptions(options PrometheusOptions, views ...*ocview.View) (*Exporter, error) {
+	metricsRegistry := prometheus.NewRegistry()
+	if err := RegisterCollectors(metricsRegistry, options.Collectors); err != nil {
+		return nil, err
+	}
+
+	exporter, err := NewPrometheusExporterFromRegistry(metricsRegistry, views...)
+	if err != nil {
+		return nil, err
 	}
 
-	return NewPrometheusExporterFromRegistry(metricsRegistry, views...)
+	if !options.DisableDefaultRegistry {
+		gatherer := &mergedGatherer{primary: metricsRegistry, secondary: prometheus.DefaultGatherer}
+		exporter.handler = promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})
+	}
+
+	if options.GlobalMeterProvider {
+		exporter.previous = otel.GetMeterProvider()
+		exporter.global = true
+		otel.SetMeterProvider(exporter.provider)
+	}
+
+	return exporter, nil
 }
 
-// NewPrometheusExporterFromRegistry creates a new Prometheus metrics exporter.
-func NewPrometheusExporterFromRegistry(registry *prometheus.Registry, views ...*ocview.View) (*ocprometheus.Exporter, error) {
-	prometheusExporter, err := ocprometheus.NewExporter(
-		ocprometheus.Options{
-			Registry: registry,
-		},
+// NewPrometheusExporterFromRegistry creates a new Prometheus metrics exporter of the specified registry, i.e. the
+// default Prometheus registry. It serves the metrics of this registry only, and is not registered as the global
+// OpenTelemetry meter provider.
+//
+// The registry must not be shared with another exporter: the metrics would be collected twice.
+func NewPrometheusExporterFromRegistry(registry *prometheus.Registry, views ...*ocview.View) (*Exporter, error) {
+	viewSet, err := registerViews(views)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Prometheus output is kept as it was with the OpenCensus exporter: no unit or counter suffix, no scope or
+	// target labels.
+	reader, err := otelprometheus.New(
+		otelprometheus.WithRegisterer(registry),
+		otelprometheus.WithProducer(viewSet.producer()),
+		otelprometheus.WithoutUnits(),
+		otelprometheus.WithoutCounterSuffixes(),
+		otelprometheus.WithoutScopeInfo(),
+		otelprometheus.WithoutTargetInfo(),
 	)
 	if err != nil {
+		viewSet.unregister()
 		return nil, err
 	}
 
-	// register the stats exporter
-	ocview.RegisterExporter(prometheusExporter)
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	declared, err := bindDeclaredMetrics(provider)
+	if err != nil {
+		_ = provider.Shutdown(context.Background())
+		viewSet.unregister()
+		return nil, err
+	}
 
-	views = append(
-		views,
-		advlogs.LogCountView,
-		//middlewares.ServerRequestBytesView,
-		//middlewares.ServerResponseCountView,
-		//middlewares.ServerResponseBytesView,
-		//middlewares.ServerLatencyView,
-	)
+	return &Exporter{
+		registry: registry,
+		provider: provider,
+		views:    viewSet,
+		declared: declared,
+		handler:  promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	}, nil
+}
+
+// ServeHTTP serves the metrics in the Prometheus exposition format, or in the OpenMetrics format with the exemplars
+// when the scraper negotiates it, see ``middlewares.RequestMetrics``.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.handler.ServeHTTP(w, r)
+}
+
+// MeterProvider returns the OpenTelemetry meter provider of the exported metrics.
+func (e *Exporter) MeterProvider() metric.MeterProvider {
+	return e.provider
+}
+
+// Meter returns the OpenTelemetry meter with the specified instrumentation name.
+func (e *Exporter) Meter(name string, options ...metric.MeterOption) metric.Meter {
+	return e.provider.Meter(name, options...)
+}
+
+// Registry returns the Prometheus registry of the exported metrics.
+func (e *Exporter) Registry() *prometheus.Registry {
+	return e.registry
+}
+
+// Shutdown stops the exporter meter provider, unregisters the views of the exporter and stops recording the declared
+// metrics. The OpenTelemetry collector
+// of the exporter stays registered in its registry but collects nothing. The previous global meter provider is
+// restored when the exporter is still the global one, see ``GlobalMeterProvider``.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	defer e.views.unregister()
+	e.declared.release()
+
+	if e.global && otel.GetMeterProvider() == metric.MeterProvider(e.provider) {
+		otel.SetMeterProvider(e.previous)
+	}
 
-	// register the views
-	if err := ocview.Register(views...); err != nil {
-		log.Error(err, "Failed to register views")
+	return e.provider.Shutdown(ctx)
+}
+
+// Close shuts the exporter down, see ``Shutdown``.
+func (e *Exporter) Close() error {
+	return e.Shutdown(context.Background())
+}
+
+// mergedGatherer gathers the metric families of two gatherers, the families of the primary gatherer win over the
+// families of the secondary gatherer with the same name, i.e. the process and Go collectors registered with both.
+type mergedGatherer struct {
+	primary   prometheus.Gatherer
+	secondary prometheus.Gatherer
+}
+
+func (g *mergedGatherer) Gather() ([]*dto.MetricFamily, error) {
+	var errs prometheus.MultiError
+
+	families, err := g.primary.Gather()
+	errs.Append(err)
+
+	names := make(map[string]bool, len(families))
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+
+	others, err := g.secondary.Gather()
+	errs.Append(err)
+
+	for _, family := range others {
+		if !names[family.GetName()] {
+			families = append(families, family)
+		}
 	}
-	return prometheusExporter, nil
+
+	sort.Slice(families, func(i, j int) bool { return families[i].GetName() < families[j].GetName() })
+	return families, errs.MaybeUnwrap()
 }
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/stats"
+	ocview "go.opencensus.io/stats/view"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// scrape returns the metrics served by the specified exporter.
+func scrape(t *testing.T, exporter *Exporter) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	exporter.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	if w.Code != 200 {
+		t.Fatalf("scrape status = %d: %s", w.Code, body)
+	}
+	return string(body)
+}
+
+func TestPrometheusExporterDefaultRegistry(t *testing.T) {
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_default_registry_total"})
+	prometheus.MustRegister(counter)
+	defer prometheus.Unregister(counter)
+	counter.Inc()
+
+	exporter, err := NewPrometheusExporter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Close()
+
+	// the Go collector is registered with both registries
+	body := scrape(t, exporter)
+	if !strings.Contains(body, "test_default_registry_total 1") {
+		t.Errorf("the metrics of the default registry are not served:\n%s", body)
+	}
+	if got := strings.Count(body, "\ngo_goroutines "); got != 1 {
+		t.Errorf("go_goroutines served %d times, want 1", got)
+	}
+
+	isolated, err := NewPrometheusExporterWithOptions(PrometheusOptions{DisableDefaultRegistry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer isolated.Close()
+
+	if body := scrape(t, isolated); strings.Contains(body, "test_default_registry_total") {
+		t.Errorf("the metrics of the default registry are served when disabled:\n%s", body)
+	}
+}
+
+func TestPrometheusExporterGlobalMeterProvider(t *testing.T) {
+	previous := otel.GetMeterProvider()
+
+	exporter, err := NewPrometheusExporter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetMeterProvider() != previous {
+		t.Error("the exporter is registered as the global meter provider without GlobalMeterProvider")
+	}
+	exporter.Close()
+
+	exporter, err = NewPrometheusExporterWithOptions(PrometheusOptions{GlobalMeterProvider: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetMeterProvider() != metric.MeterProvider(exporter.provider) {
+		t.Error("the exporter is not registered as the global meter provider")
+	}
+
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetMeterProvider() != previous {
+		t.Error("the previous global meter provider is not restored on shutdown")
+	}
+}
+
+func TestPrometheusExporterApplicationViews(t *testing.T) {
+	measure := stats.Int64("test_application_view", "", stats.UnitDimensionless)
+	view := &ocview.View{Measure: measure, Aggregation: ocview.Count()}
+	if err := ocview.Register(view); err != nil {
+		t.Fatal(err)
+	}
+	defer ocview.Unregister(view)
+
+	exporter, err := NewPrometheusExporter(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter.Close()
+
+	if ocview.Find("test_application_view") == nil {
+		t.Error("the view registered by the application is unregistered by the exporter")
+	}
+
+	owned := &ocview.View{
+		Name:        "test_exporter_view",
+		Measure:     measure,
+		Aggregation: ocview.Count(),
+	}
+	exporter, err = NewPrometheusExporter(owned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter.Close()
+
+	if ocview.Find("test_exporter_view") != nil {
+		t.Error("the view registered by the exporter is not unregistered on close")
+	}
+}
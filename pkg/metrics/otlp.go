@@ -0,0 +1,311 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	ocview "go.opencensus.io/stats/view"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// OTLPProtocol defines the transport protocol of the OTLP metrics exporter.
+type OTLPProtocol string
+
+const (
+	// OTLPProtocolGRPC exports the metrics over gRPC, the collector listens on port ``4317`` by default.
+	OTLPProtocolGRPC OTLPProtocol = "grpc"
+	// OTLPProtocolHTTPProtobuf exports the metrics as protobuf over HTTP, the collector listens on port ``4318`` by
+	// default.
+	OTLPProtocolHTTPProtobuf OTLPProtocol = "http/protobuf"
+)
+
+// OTLPTemporality defines the aggregation temporality of the metrics exported over OTLP.
+type OTLPTemporality string
+
+const (
+	// OTLPTemporalityCumulative exports the metrics aggregated since the exporter started.
+	OTLPTemporalityCumulative OTLPTemporality = "cumulative"
+	// OTLPTemporalityDelta exports the counters and histograms aggregated since the last export, the up-down counters
+	// stay cumulative.
+	OTLPTemporalityDelta OTLPTemporality = "delta"
+	// OTLPTemporalityLowMemory exports the synchronous counters and histograms aggregated since the last export, the
+	// other instruments stay cumulative.
+	OTLPTemporalityLowMemory OTLPTemporality = "lowmemory"
+)
+
+const (
+	// OTLPProtocolEnvironmentVariable defines the name of the environment variable holding the OTLP protocol.
+	OTLPProtocolEnvironmentVariable = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	// OTLPMetricsProtocolEnvironmentVariable defines the name of the environment variable holding the OTLP protocol
+	// of the metrics, it takes precedence over ``OTEL_EXPORTER_OTLP_PROTOCOL``.
+	OTLPMetricsProtocolEnvironmentVariable = "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"
+
+	// OTLPDefaultRetryInitialInterval defines the default time to wait after a failed export before retrying.
+	OTLPDefaultRetryInitialInterval = 5 * time.Second
+	// OTLPDefaultRetryMaxInterval defines the default maximum time to wait between retries.
+	OTLPDefaultRetryMaxInterval = 30 * time.Second
+	// OTLPDefaultRetryMaxElapsedTime defines the default maximum time spent exporting a batch, retries included.
+	OTLPDefaultRetryMaxElapsedTime = time.Minute
+)
+
+// OTLPOptions represents the OTLP metrics exporter configuration options.
+//
+// The options left empty default to the standard OpenTelemetry environment variables, i.e.
+// ``OTEL_EXPORTER_OTLP_ENDPOINT``, ``OTEL_EXPORTER_OTLP_HEADERS``, ``OTEL_METRIC_EXPORT_INTERVAL`` or
+// ``OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE``, the ``OTEL_EXPORTER_OTLP_METRICS_*`` variables taking
+// precedence.
+type OTLPOptions struct {
+	// Protocol defines the transport protocol. It defaults to the ``OTEL_EXPORTER_OTLP_METRICS_PROTOCOL`` then
+	// ``OTEL_EXPORTER_OTLP_PROTOCOL`` environment variables, then to ``OTLPProtocolHTTPProtobuf``.
+	Protocol OTLPProtocol
+	// Endpoint defines the collector URL, i.e. ``http://localhost:4318``. The connection is not secured when the
+	// scheme is ``http``. The HTTP exporter posts the metrics to the ``/v1/metrics`` path when the URL has no path.
+	Endpoint string
+	// Headers defines the headers sent with every export, i.e. the collector credentials. They replace the headers
+	// of the environment variables.
+	Headers map[string]string
+	// Interval defines the time between two exports. It defaults to the ``OTEL_METRIC_EXPORT_INTERVAL`` environment
+	// variable, then to one minute.
+	Interval time.Duration
+	// Timeout defines the timeout of an export. It defaults to the ``OTEL_EXPORTER_OTLP_METRICS_TIMEOUT`` then
+	// ``OTEL_EXPORTER_OTLP_TIMEOUT`` environment variables, then to 10 seconds.
+	Timeout time.Duration
+	// Temporality defines the aggregation temporality. It defaults to the
+	// ``OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE`` environment variable, then to
+	// ``OTLPTemporalityCumulative``.
+	Temporality OTLPTemporality
+	// Retry defines how the failed exports are retried.
+	Retry OTLPRetryOptions
+}
+
+// OTLPRetryOptions defines how the failed exports are retried, with an exponential backoff.
+type OTLPRetryOptions struct {
+	// Disabled disables the retries, the metrics of a failed export are dropped.
+	Disabled bool
+	// InitialInterval defines the time to wait after the first failure. It defaults to
+	// ``OTLPDefaultRetryInitialInterval``.
+	InitialInterval time.Duration
+	// MaxInterval defines the maximum time to wait between two retries. It defaults to
+	// ``OTLPDefaultRetryMaxInterval``.
+	MaxInterval time.Duration
+	// MaxElapsedTime defines the maximum time spent on an export, retries included, before its metrics are dropped.
+	// It defaults to ``OTLPDefaultRetryMaxElapsedTime``.
+	MaxElapsedTime time.Duration
+}
+
+func setOTLPOptionsDefaults(options *OTLPOptions) {
+	if options.Protocol == "" {
+		for _, name := range []string{OTLPMetricsProtocolEnvironmentVariable, OTLPProtocolEnvironmentVariable} {
+			value := OTLPProtocol(strings.TrimSpace(os.Getenv(name)))
+			if value == "" {
+				continue
+			}
+
+			if value != OTLPProtocolGRPC && value != OTLPProtocolHTTPProtobuf {
+				fmt.Fprintf(os.Stderr, "Ignoring invalid %s value %q: unsupported protocol\n", name, value)
+				continue
+			}
+
+			options.Protocol = value
+			break
+		}
+	}
+
+	if options.Protocol == "" {
+		options.Protocol = OTLPProtocolHTTPProtobuf
+	}
+
+	if options.Retry.InitialInterval <= 0 {
+		options.Retry.InitialInterval = OTLPDefaultRetryInitialInterval
+	}
+
+	if options.Retry.MaxInterval <= 0 {
+		options.Retry.MaxInterval = OTLPDefaultRetryMaxInterval
+	}
+
+	if options.Retry.MaxElapsedTime <= 0 {
+		options.Retry.MaxElapsedTime = OTLPDefaultRetryMaxElapsedTime
+	}
+}
+
+// OTLPExporter pushes the metrics to an OpenTelemetry collector over OTLP.
+//
+// The metrics are recorded with the OpenTelemetry meters of ``MeterProvider``. As with the Prometheus exporter, the
+// OpenCensus views of the exporter are bridged to OpenTelemetry and exported along with them.
+type OTLPExporter struct {
+	provider *sdkmetric.MeterProvider
+	views    *viewSet
+	declared *declaredBinding
+}
+
+// NewOTLPExporter creates a new OTLP metrics exporter, pushing the metrics periodically until it is shut down. It is
+// not registered as the global OpenTelemetry meter provider.
+func NewOTLPExporter(ctx context.Context, options OTLPOptions, views ...*ocview.View) (*OTLPExporter, error) {
+	setOTLPOptionsDefaults(&options)
+
+	if options.Endpoint != "" {
+		if u, err := url.Parse(options.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid OTLP endpoint %q", options.Endpoint)
+		}
+	}
+
+	exporter, err := newOTLPMetricExporter(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	viewSet, err := registerViews(views)
+	if err != nil {
+		_ = exporter.Shutdown(ctx)
+		return nil, err
+	}
+
+	var readerOptions []sdkmetric.PeriodicReaderOption
+	readerOptions = append(readerOptions, sdkmetric.WithProducer(viewSet.producer()))
+	if options.Interval > 0 {
+		readerOptions = append(readerOptions, sdkmetric.WithInterval(options.Interval))
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter, readerOptions...)
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	declared, err := bindDeclaredMetrics(provider)
+	if err != nil {
+		_ = provider.Shutdown(ctx)
+		viewSet.unregister()
+		return nil, err
+	}
+
+	return &OTLPExporter{
+		provider: provider,
+		views:    viewSet,
+		declared: declared,
+	}, nil
+}
+
+// newOTLPMetricExporter creates the OTLP exporter of the configured protocol. The options left empty are not set, for
+// the exporter to read them from the environment variables.
+func newOTLPMetricExporter(ctx context.Context, options OTLPOptions) (sdkmetric.Exporter, error) {
+	temporality, err := otlpTemporalitySelector(options.Temporality)
+	if err != nil {
+		return nil, err
+	}
+
+	switch options.Protocol {
+	case OTLPProtocolGRPC:
+		var grpcOptions []otlpmetricgrpc.Option
+		grpcOptions = append(grpcOptions, otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{
+			Enabled:         !options.Retry.Disabled,
+			InitialInterval: options.Retry.InitialInterval,
+			MaxInterval:     options.Retry.MaxInterval,
+			MaxElapsedTime:  options.Retry.MaxElapsedTime,
+		}))
+		if options.Endpoint != "" {
+			grpcOptions = append(grpcOptions, otlpmetricgrpc.WithEndpointURL(options.Endpoint))
+		}
+		if options.Headers != nil {
+			grpcOptions = append(grpcOptions, otlpmetricgrpc.WithHeaders(options.Headers))
+		}
+		if options.Timeout > 0 {
+			grpcOptions = append(grpcOptions, otlpmetricgrpc.WithTimeout(options.Timeout))
+		}
+		if temporality != nil {
+			grpcOptions = append(grpcOptions, otlpmetricgrpc.WithTemporalitySelector(temporality))
+		}
+		return otlpmetricgrpc.New(ctx, grpcOptions...)
+
+	case OTLPProtocolHTTPProtobuf:
+		var httpOptions []otlpmetrichttp.Option
+		httpOptions = append(httpOptions, otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig{
+			Enabled:         !options.Retry.Disabled,
+			InitialInterval: options.Retry.InitialInterval,
+			MaxInterval:     options.Retry.MaxInterval,
+			MaxElapsedTime:  options.Retry.MaxElapsedTime,
+		}))
+		if options.Endpoint != "" {
+			httpOptions = append(httpOptions, otlpmetrichttp.WithEndpointURL(options.Endpoint))
+		}
+		if options.Headers != nil {
+			httpOptions = append(httpOptions, otlpmetrichttp.WithHeaders(options.Headers))
+		}
+		if options.Timeout > 0 {
+			httpOptions = append(httpOptions, otlpmetrichttp.WithTimeout(options.Timeout))
+		}
+		if temporality != nil {
+			httpOptions = append(httpOptions, otlpmetrichttp.WithTemporalitySelector(temporality))
+		}
+		return otlpmetrichttp.New(ctx, httpOptions...)
+
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q", options.Protocol)
+	}
+}
+
+// otlpTemporalitySelector returns the selector of the specified temporality, or nil when it is empty.
+func otlpTemporalitySelector(temporality OTLPTemporality) (sdkmetric.TemporalitySelector, error) {
+	switch OTLPTemporality(strings.ToLower(string(temporality))) {
+	case "":
+		return nil, nil
+
+	case OTLPTemporalityCumulative:
+		return sdkmetric.DefaultTemporalitySelector, nil
+
+	case OTLPTemporalityDelta:
+		return func(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+			switch kind {
+			case sdkmetric.InstrumentKindUpDownCounter, sdkmetric.InstrumentKindObservableUpDownCounter:
+				return metricdata.CumulativeTemporality
+			default:
+				return metricdata.DeltaTemporality
+			}
+		}, nil
+
+	case OTLPTemporalityLowMemory:
+		return func(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+			switch kind {
+			case sdkmetric.InstrumentKindCounter, sdkmetric.InstrumentKindHistogram:
+				return metricdata.DeltaTemporality
+			default:
+				return metricdata.CumulativeTemporality
+			}
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported OTLP temporality %q", temporality)
+	}
+}
+
+// MeterProvider returns the OpenTelemetry meter provider of the exported metrics.
+func (e *OTLPExporter) MeterProvider() metric.MeterProvider {
+	return e.provider
+}
+
+// Meter returns the OpenTelemetry meter with the specified instrumentation name.
+func (e *OTLPExporter) Meter(name string, options ...metric.MeterOption) metric.Meter {
+	return e.provider.Meter(name, options...)
+}
+
+// ForceFlush pushes the current metrics without waiting for the next export.
+func (e *OTLPExporter) ForceFlush(ctx context.Context) error {
+	return e.provider.ForceFlush(ctx)
+}
+
+// Shutdown pushes the current metrics then stops the exporter and unregisters its views. The export is retried until
+// the context is done.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	defer e.views.unregister()
+	e.declared.release()
+	return e.provider.Shutdown(ctx)
+}
+
+// Close shuts the exporter down, see ``Shutdown``.
+func (e *OTLPExporter) Close() error {
+	return e.Shutdown(context.Background())
+}
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	collectormetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpExport is an export received by a fake collector.
+type otlpExport struct {
+	headers map[string]string
+	request *collectormetricpb.ExportMetricsServiceRequest
+}
+
+// grpcCollector is an in-process OTLP gRPC collector.
+type grpcCollector struct {
+	collectormetricpb.UnimplementedMetricsServiceServer
+	exports chan otlpExport
+}
+
+func (c *grpcCollector) Export(
+	ctx context.Context,
+	request *collectormetricpb.ExportMetricsServiceRequest,
+) (*collectormetricpb.ExportMetricsServiceResponse, error) {
+	headers := make(map[string]string)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			headers[key] = values[0]
+		}
+	}
+
+	c.exports <- otlpExport{headers: headers, request: request}
+	return &collectormetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+// startGRPCCollector starts an in-process OTLP gRPC collector, it returns its endpoint.
+func startGRPCCollector(t *testing.T, exports chan otlpExport) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer()
+	collectormetricpb.RegisterMetricsServiceServer(server, &grpcCollector{exports: exports})
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	return "http://" + listener.Addr().String()
+}
+
+// startHTTPCollector starts an in-process OTLP HTTP collector, it returns its endpoint.
+func startHTTPCollector(t *testing.T, exports chan otlpExport) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		request := &collectormetricpb.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(body, request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		headers := make(map[string]string)
+		for key := range r.Header {
+			headers[http.CanonicalHeaderKey(key)] = r.Header.Get(key)
+		}
+
+		exports <- otlpExport{headers: headers, request: request}
+
+		response, _ := proto.Marshal(&collectormetricpb.ExportMetricsServiceResponse{})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		_, _ = w.Write(response)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// findSum returns the data points of the specified sum metric of an export.
+func findSum(request *collectormetricpb.ExportMetricsServiceRequest, name string) []*metricpb.NumberDataPoint {
+	for _, rm := range request.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == name && m.GetSum() != nil {
+					return m.GetSum().DataPoints
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func TestOTLPExporter(t *testing.T) {
+	for _, test := range []struct {
+		protocol    OTLPProtocol
+		start       func(*testing.T, chan otlpExport) string
+		header      string
+		temporality OTLPTemporality
+		want        metricpb.AggregationTemporality
+	}{
+		{
+			protocol:    OTLPProtocolGRPC,
+			start:       startGRPCCollector,
+			header:      "x-api-key",
+			temporality: OTLPTemporalityCumulative,
+			want:        metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+		},
+		{
+			protocol:    OTLPProtocolHTTPProtobuf,
+			start:       startHTTPCollector,
+			header:      "X-Api-Key",
+			temporality: OTLPTemporalityDelta,
+			want:        metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+		},
+	} {
+		t.Run(string(test.protocol), func(t *testing.T) {
+			exports := make(chan otlpExport, 10)
+			endpoint := test.start(t, exports)
+
+			ctx := context.Background()
+			exporter, err := NewOTLPExporter(ctx, OTLPOptions{
+				Protocol:    test.protocol,
+				Endpoint:    endpoint,
+				Headers:     map[string]string{"x-api-key": "secret"},
+				Interval:    time.Hour,
+				Temporality: test.temporality,
+				Retry:       OTLPRetryOptions{Disabled: true},
+			})
+			if err != nil {
+				t.Fatalf("NewOTLPExporter() error = %v", err)
+			}
+			defer exporter.Close()
+
+			orders, err := exporter.Meter("test").Int64Counter("orders")
+			if err != nil {
+				t.Fatal(err)
+			}
+			orders.Add(ctx, 3, metric.WithAttributes(attribute.String("status", "paid")))
+			orders.Add(ctx, 2, metric.WithAttributes(attribute.String("status", "paid")))
+
+			if err := exporter.ForceFlush(ctx); err != nil {
+				t.Fatalf("ForceFlush() error = %v", err)
+			}
+
+			var export otlpExport
+			select {
+			case export = <-exports:
+			case <-time.After(10 * time.Second):
+				t.Fatal("no export received")
+			}
+
+			if got := export.headers[test.header]; got != "secret" {
+				t.Errorf("header %s = %q, want %q", test.header, got, "secret")
+			}
+
+			for _, rm := range export.request.ResourceMetrics {
+				for _, sm := range rm.ScopeMetrics {
+					for _, m := range sm.Metrics {
+						if m.Name == "orders" && m.GetSum().AggregationTemporality != test.want {
+							t.Errorf("temporality = %v, want %v", m.GetSum().AggregationTemporality, test.want)
+						}
+					}
+				}
+			}
+
+			points := findSum(export.request, "orders")
+			if len(points) != 1 {
+				t.Fatalf("got %d data points of orders, want 1", len(points))
+			}
+
+			if got := points[0].GetAsInt(); got != 5 {
+				t.Errorf("orders = %d, want 5", got)
+			}
+
+			attributes := points[0].Attributes
+			if len(attributes) != 1 || attributes[0].Key != "status" || attributes[0].Value.GetStringValue() != "paid" {
+				t.Errorf("attributes = %v, want status=paid", attributes)
+			}
+		})
+	}
+}
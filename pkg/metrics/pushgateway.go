@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+
+	"github.com/mojun2021/micro-server/pkg/logger"
+)
+
+const (
+	// PushgatewayURLEnvironmentVariable defines the name of the environment variable holding the Pushgateway URL.
+	PushgatewayURLEnvironmentVariable = "USGO_PUSHGATEWAY_URL"
+	// PushgatewayJobEnvironmentVariable defines the name of the environment variable holding the job name of the
+	// pushed metrics.
+	PushgatewayJobEnvironmentVariable = "USGO_PUSHGATEWAY_JOB"
+)
+
+var pushLog = logger.Log.WithName("metrics").WithName("pushgateway")
+
+// PushgatewayOptions represents the Pushgateway pusher configuration options.
+type PushgatewayOptions struct {
+	// URL defines the Pushgateway URL, i.e. ``http://pushgateway:9091``. It defaults to the ``USGO_PUSHGATEWAY_URL``
+	// environment variable.
+	URL string
+	// Job defines the ``job`` label of the pushed metrics. It defaults to the ``USGO_PUSHGATEWAY_JOB`` environment
+	// variable, then to the name of the executable.
+	Job string
+	// Grouping defines the other labels of the group of the pushed metrics, i.e. ``instance``.
+	Grouping map[string]string
+	// Username defines the basic authentication user of the Pushgateway.
+	Username string
+	// Password defines the basic authentication password of the Pushgateway.
+	Password string
+	// Interval defines the time between two pushes while the job runs, see ``Pusher.Run``. The metrics are only pushed
+	// on completion when 0.
+	Interval time.Duration
+	// DeleteOnSuccess deletes the group of the metrics from the Pushgateway when the job succeeds, instead of pushing
+	// them, see ``Pusher.Complete``. The metrics of a failed job are always pushed.
+	DeleteOnSuccess bool
+	// Client defines the HTTP client sending the requests, i.e. to authenticate with a client certificate. It defaults
+	// to ``http.DefaultClient``.
+	Client push.HTTPDoer
+}
+
+func setPushgatewayOptionsDefaults(options *PushgatewayOptions) {
+	if options.URL == "" {
+		options.URL = os.Getenv(PushgatewayURLEnvironmentVariable)
+	}
+
+	if options.Job == "" {
+		options.Job = os.Getenv(PushgatewayJobEnvironmentVariable)
+	}
+
+	if options.Job == "" {
+		options.Job = filepath.Base(os.Args[0])
+	}
+}
+
+// Pusher pushes the metrics of a registry to a Prometheus Pushgateway, for the jobs that are not running long enough
+// to be scraped, i.e. batch jobs or Kubernetes CronJobs.
+//
+// Example:
+//
+//    pusher, err := metrics.NewPusher(exporter.Registry(), metrics.PushgatewayOptions{Interval: time.Minute})
+//    ...
+//    go pusher.Run(ctx)
+//    jobErr := runJob(ctx)
+//    err = pusher.Complete(context.Background(), jobErr)
+//
+// ``Complete`` stops ``Run`` and waits for it to return: the last push of ``Run`` cannot overwrite the metrics pushed
+// on completion, or recreate the deleted group.
+type Pusher struct {
+	pusher  *push.Pusher
+	options PushgatewayOptions
+
+	mu      sync.Mutex
+	stopped chan struct{}
+	runs    sync.WaitGroup
+}
+
+// NewPusher creates a new Pushgateway pusher of the metrics of the specified gatherer, i.e. the registry of an
+// exporter, see ``Exporter.Registry``.
+func NewPusher(gatherer prometheus.Gatherer, options PushgatewayOptions) (*Pusher, error) {
+	setPushgatewayOptionsDefaults(&options)
+
+	if options.URL == "" {
+		return nil, errors.New("no Pushgateway URL")
+	}
+
+	pusher := push.New(options.URL, options.Job).Gatherer(gatherer)
+	for name, value := range options.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	if options.Username != "" || options.Password != "" {
+		pusher = pusher.BasicAuth(options.Username, options.Password)
+	}
+
+	if options.Client != nil {
+		pusher = pusher.Client(options.Client)
+	}
+
+	if err := pusher.Error(); err != nil {
+		return nil, err
+	}
+
+	return &Pusher{pusher: pusher, options: options, stopped: make(chan struct{})}, nil
+}
+
+// Push pushes the metrics, they replace the metrics of the same group in the Pushgateway.
+func (p *Pusher) Push(ctx context.Context) error {
+	return p.pusher.PushContext(ctx)
+}
+
+// Delete deletes the metrics of the group from the Pushgateway.
+func (p *Pusher) Delete() error {
+	return p.pusher.Delete()
+}
+
+// Run pushes the metrics periodically until the context is done or the pusher is completed, see
+// ``PushgatewayOptions.Interval`` and ``Complete``. The failed pushes are reported as errors by the
+// ``metrics.pushgateway`` logger, the next push being retried at the next interval. It returns immediately when the
+// interval is 0 or when the pusher is already completed.
+func (p *Pusher) Run(ctx context.Context) error {
+	if p.options.Interval <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	select {
+	case <-p.stopped:
+		p.mu.Unlock()
+		return nil
+	default:
+		p.runs.Add(1)
+		p.mu.Unlock()
+	}
+	defer p.runs.Done()
+
+	ticker := time.NewTicker(p.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-p.stopped:
+			return nil
+
+		case <-ticker.C:
+			if err := p.Push(ctx); err != nil && ctx.Err() == nil {
+				pushLog.Error(err, "Failed to push the metrics", "url", p.options.URL, "job", p.options.Job)
+			}
+		}
+	}
+}
+
+// Complete pushes the metrics once the job is done, the job error being nil when it succeeded. The group of the
+// metrics is deleted instead when the job succeeded and ``PushgatewayOptions.DeleteOnSuccess`` is set.
+//
+// It stops the running ``Run`` calls and waits for them to return first, a push in progress included.
+func (p *Pusher) Complete(ctx context.Context, jobErr error) error {
+	p.mu.Lock()
+	select {
+	case <-p.stopped:
+	default:
+		close(p.stopped)
+	}
+	p.mu.Unlock()
+	p.runs.Wait()
+
+	if jobErr == nil && p.options.DeleteOnSuccess {
+		return p.Delete()
+	}
+
+	return p.Push(ctx)
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pushRequest is a request received by a fake Pushgateway.
+type pushRequest struct {
+	method   string
+	path     string
+	username string
+	password string
+	body     string
+}
+
+// fakePushgateway records the requests it receives.
+type fakePushgateway struct {
+	mu       sync.Mutex
+	requests []pushRequest
+}
+
+func (g *fakePushgateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	username, password, _ := r.BasicAuth()
+
+	g.mu.Lock()
+	g.requests = append(g.requests, pushRequest{
+		method:   r.Method,
+		path:     r.URL.Path,
+		username: username,
+		password: password,
+		body:     string(body),
+	})
+	g.mu.Unlock()
+
+	// the Pushgateway accepts the deletions asynchronously
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *fakePushgateway) received() []pushRequest {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return append([]pushRequest(nil), g.requests...)
+}
+
+// newPushTestRegistry creates a registry holding a counter.
+func newPushTestRegistry(t *testing.T) *prometheus.Registry {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "processed_total"})
+	registry.MustRegister(counter)
+	counter.Add(3)
+	return registry
+}
+
+func TestPusherComplete(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		jobErr     error
+		wantMethod string
+	}{
+		{name: "failure", jobErr: errors.New("job failed"), wantMethod: http.MethodPut},
+		{name: "success", wantMethod: http.MethodDelete},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			gateway := &fakePushgateway{}
+			server := httptest.NewServer(gateway)
+			defer server.Close()
+
+			pusher, err := NewPusher(newPushTestRegistry(t), PushgatewayOptions{
+				URL:             server.URL,
+				Job:             "batch",
+				Grouping:        map[string]string{"instance": "worker-1"},
+				Username:        "user",
+				Password:        "secret",
+				DeleteOnSuccess: true,
+			})
+			if err != nil {
+				t.Fatalf("NewPusher() error = %v", err)
+			}
+
+			if err := pusher.Complete(context.Background(), test.jobErr); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+
+			requests := gateway.received()
+			if len(requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(requests))
+			}
+
+			request := requests[0]
+			if request.method != test.wantMethod {
+				t.Errorf("method = %s, want %s", request.method, test.wantMethod)
+			}
+			if want := "/metrics/job/batch/instance/worker-1"; request.path != want {
+				t.Errorf("path = %s, want %s", request.path, want)
+			}
+			if request.username != "user" || request.password != "secret" {
+				t.Errorf("basic auth = %s:%s, want user:secret", request.username, request.password)
+			}
+			if test.wantMethod == http.MethodPut && !strings.Contains(request.body, "processed_total") {
+				t.Error("the metrics are not pushed")
+			}
+		})
+	}
+}
+
+func TestPusherCompleteStopsRun(t *testing.T) {
+	gateway := &fakePushgateway{}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	pusher, err := NewPusher(newPushTestRegistry(t), PushgatewayOptions{
+		URL:             server.URL,
+		Job:             "batch",
+		Interval:        5 * time.Millisecond,
+		DeleteOnSuccess: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_ = pusher.Run(context.Background())
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(gateway.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := pusher.Complete(context.Background(), nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run is still running once completed")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	requests := gateway.received()
+	if len(requests) < 2 {
+		t.Fatalf("got %d requests, want the periodic pushes and the deletion", len(requests))
+	}
+	for _, request := range requests[:len(requests)-1] {
+		if request.method != http.MethodPut {
+			t.Errorf("periodic push method = %s, want %s", request.method, http.MethodPut)
+		}
+	}
+	if last := requests[len(requests)-1]; last.method != http.MethodDelete {
+		t.Errorf("last request method = %s, want %s", last.method, http.MethodDelete)
+	}
+}
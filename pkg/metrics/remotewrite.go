@@ -0,0 +1,645 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/mojun2021/micro-server/pkg/logger"
+)
+
+const (
+	// RemoteWriteURLEnvironmentVariable defines the name of the environment variable holding the remote-write URL.
+	RemoteWriteURLEnvironmentVariable = "USGO_REMOTE_WRITE_URL"
+
+	// RemoteWriteDefaultInterval defines the default time between two collections of the samples.
+	RemoteWriteDefaultInterval = 15 * time.Second
+	// RemoteWriteDefaultTimeout defines the default timeout of a remote-write request.
+	RemoteWriteDefaultTimeout = 30 * time.Second
+	// RemoteWriteDefaultShards defines the default number of shards sending the samples concurrently.
+	RemoteWriteDefaultShards = 4
+	// RemoteWriteDefaultCapacity defines the default number of samples queued per shard.
+	RemoteWriteDefaultCapacity = 2500
+	// RemoteWriteDefaultMaxSamplesPerSend defines the default maximum number of samples of a request.
+	RemoteWriteDefaultMaxSamplesPerSend = 500
+	// RemoteWriteDefaultBatchSendDeadline defines the default maximum time a sample waits in a shard before being
+	// sent.
+	RemoteWriteDefaultBatchSendDeadline = 5 * time.Second
+	// RemoteWriteDefaultMinBackoff defines the default initial time to wait before retrying a request.
+	RemoteWriteDefaultMinBackoff = 30 * time.Millisecond
+	// RemoteWriteDefaultMaxBackoff defines the default maximum time to wait before retrying a request.
+	RemoteWriteDefaultMaxBackoff = 5 * time.Second
+	// RemoteWriteDefaultFlushTimeout defines the default time the queued samples are sent for on shutdown.
+	RemoteWriteDefaultFlushTimeout = 10 * time.Second
+
+	remoteWriteVersion = "0.1.0"
+)
+
+var remoteWriteLog = logger.Log.WithName("metrics").WithName("remote_write")
+
+// RemoteWriteOptions represents the Prometheus remote-write configuration options.
+type RemoteWriteOptions struct {
+	// URL defines the remote-write endpoint, i.e. ``http://prometheus:9090/api/v1/write``. It defaults to the
+	// ``USGO_REMOTE_WRITE_URL`` environment variable.
+	URL string
+	// Headers defines the headers sent with every request, i.e. an ``Authorization`` header.
+	Headers map[string]string
+	// Username defines the basic authentication user of the endpoint.
+	Username string
+	// Password defines the basic authentication password of the endpoint.
+	Password string
+	// ExternalLabels defines the labels added to every sample, i.e. ``instance``. The labels of a sample take
+	// precedence.
+	ExternalLabels map[string]string
+	// Interval defines the time between two collections of the samples. It defaults to
+	// ``RemoteWriteDefaultInterval``.
+	Interval time.Duration
+	// Timeout defines the timeout of a request. It defaults to ``RemoteWriteDefaultTimeout``.
+	Timeout time.Duration
+	// Shards defines the number of shards sending the samples concurrently, the samples of a series always being sent
+	// by the same shard. It defaults to ``RemoteWriteDefaultShards``.
+	Shards int
+	// Capacity defines the number of samples queued per shard, the samples are dropped when the queue is full. A
+	// shard retrying a request sends no other batch meanwhile: its samples are queued until the queue is full. It
+	// defaults to ``RemoteWriteDefaultCapacity``.
+	Capacity int
+	// MaxSamplesPerSend defines the maximum number of samples of a request. It defaults to
+	// ``RemoteWriteDefaultMaxSamplesPerSend``.
+	MaxSamplesPerSend int
+	// BatchSendDeadline defines the maximum time a sample waits in a shard before being sent. It defaults to
+	// ``RemoteWriteDefaultBatchSendDeadline``.
+	BatchSendDeadline time.Duration
+	// MinBackoff defines the initial time to wait before retrying a failed request, doubled on every retry. It
+	// defaults to ``RemoteWriteDefaultMinBackoff``.
+	MinBackoff time.Duration
+	// MaxBackoff defines the maximum time to wait before retrying a failed request. It defaults to
+	// ``RemoteWriteDefaultMaxBackoff``.
+	MaxBackoff time.Duration
+	// FlushTimeout defines the time the queued samples are sent for on shutdown, the remaining samples are dropped.
+	// It defaults to ``RemoteWriteDefaultFlushTimeout``.
+	FlushTimeout time.Duration
+	// Client defines the HTTP client sending the requests. It defaults to ``http.DefaultClient``.
+	Client *http.Client
+}
+
+func setRemoteWriteOptionsDefaults(options *RemoteWriteOptions) {
+	if options.URL == "" {
+		options.URL = os.Getenv(RemoteWriteURLEnvironmentVariable)
+	}
+
+	if options.Interval <= 0 {
+		options.Interval = RemoteWriteDefaultInterval
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = RemoteWriteDefaultTimeout
+	}
+
+	if options.Shards <= 0 {
+		options.Shards = RemoteWriteDefaultShards
+	}
+
+	if options.Capacity <= 0 {
+		options.Capacity = RemoteWriteDefaultCapacity
+	}
+
+	if options.MaxSamplesPerSend <= 0 {
+		options.MaxSamplesPerSend = RemoteWriteDefaultMaxSamplesPerSend
+	}
+
+	if options.BatchSendDeadline <= 0 {
+		options.BatchSendDeadline = RemoteWriteDefaultBatchSendDeadline
+	}
+
+	if options.MinBackoff <= 0 {
+		options.MinBackoff = RemoteWriteDefaultMinBackoff
+	}
+
+	if options.MaxBackoff < options.MinBackoff {
+		options.MaxBackoff = RemoteWriteDefaultMaxBackoff
+	}
+
+	if options.FlushTimeout <= 0 {
+		options.FlushTimeout = RemoteWriteDefaultFlushTimeout
+	}
+
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+}
+
+// RemoteWriter sends the samples of a registry to a Prometheus remote-write endpoint, i.e. Prometheus, Thanos or
+// Mimir, for the environments without scraper.
+//
+// The samples are collected every interval and queued in memory, without write-ahead log: the queued samples are
+// lost when the process exits. The queue is split in shards sending their samples concurrently. The requests failing
+// with a 5xx or 429 status, or without response, are retried with an exponential backoff until they succeed or the
+// writer stops: a shard retrying a request is blocked, and the samples collected meanwhile are dropped once its queue
+// is full, see ``RemoteWriteOptions.Capacity``.
+//
+// The remote writer registers its own metrics with the registry:
+//
+// - ``remote_write_samples_sent_total``, ``remote_write_samples_failed_total`` (rejected by the endpoint) and
+// ``remote_write_samples_dropped_total`` (queue full or shutdown) counters
+//
+// - ``remote_write_retries_total`` counter
+//
+// - ``remote_write_queue_length``, ``remote_write_queue_capacity`` and ``remote_write_shards`` gauges
+//
+// - ``remote_write_send_duration_seconds`` histogram
+type RemoteWriter struct {
+	registry *prometheus.Registry
+	options  RemoteWriteOptions
+	shards   []chan remoteWriteSample
+	run      sync.Once
+
+	collectors     []prometheus.Collector
+	samplesSent    prometheus.Counter
+	samplesFailed  prometheus.Counter
+	samplesDropped prometheus.Counter
+	retries        prometheus.Counter
+	sendDuration   prometheus.Histogram
+}
+
+// remoteWriteLabel is a label of a sample.
+type remoteWriteLabel struct {
+	name  string
+	value string
+}
+
+// remoteWriteSample is a sample with its labels, sorted by name.
+type remoteWriteSample struct {
+	labels    []remoteWriteLabel
+	value     float64
+	timestamp int64
+}
+
+// NewRemoteWriter creates a new remote writer of the samples of the specified registry, i.e. the registry of an
+// exporter, see ``Exporter.Registry``. The samples are sent once it runs, see ``Run``.
+func NewRemoteWriter(registry *prometheus.Registry, options RemoteWriteOptions) (*RemoteWriter, error) {
+	setRemoteWriteOptionsDefaults(&options)
+
+	if options.URL == "" {
+		return nil, errors.New("no remote-write URL")
+	}
+
+	w := &RemoteWriter{
+		registry: registry,
+		options:  options,
+		shards:   make([]chan remoteWriteSample, options.Shards),
+		samplesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "remote_write_samples_sent_total",
+			Help: "The number of samples sent to the remote-write endpoint.",
+		}),
+		samplesFailed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "remote_write_samples_failed_total",
+			Help: "The number of samples rejected by the remote-write endpoint.",
+		}),
+		samplesDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "remote_write_samples_dropped_total",
+			Help: "The number of samples dropped when the queue is full or on shutdown.",
+		}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "remote_write_retries_total",
+			Help: "The number of retried remote-write requests.",
+		}),
+		sendDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "remote_write_send_duration_seconds",
+			Help: "The duration of the remote-write requests, in seconds.",
+		}),
+	}
+
+	for i := range w.shards {
+		w.shards[i] = make(chan remoteWriteSample, options.Capacity)
+	}
+
+	w.collectors = []prometheus.Collector{
+		w.samplesSent,
+		w.samplesFailed,
+		w.samplesDropped,
+		w.retries,
+		w.sendDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "remote_write_queue_length",
+			Help: "The number of queued samples.",
+		}, func() float64 {
+			length := 0
+			for _, shard := range w.shards {
+				length += len(shard)
+			}
+			return float64(length)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "remote_write_queue_capacity",
+			Help: "The number of samples the queue can hold.",
+		}, func() float64 {
+			return float64(options.Shards * options.Capacity)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "remote_write_shards",
+			Help: "The number of shards sending the samples.",
+		}, func() float64 {
+			return float64(options.Shards)
+		}),
+	}
+
+	for i, c := range w.collectors {
+		if err := registry.Register(c); err != nil {
+			for _, registered := range w.collectors[:i] {
+				registry.Unregister(registered)
+			}
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// Run sends the samples until the context is done, then sends the last samples for ``FlushTimeout`` at most and
+// unregisters the remote writer metrics. The failed requests are reported as errors by the
+// ``metrics.remote_write`` logger.
+//
+// A remote writer runs once: Run returns an error when it is called again.
+func (w *RemoteWriter) Run(ctx context.Context) error {
+	first := false
+	w.run.Do(func() { first = true })
+	if !first {
+		return errors.New("the remote writer already ran")
+	}
+
+	defer func() {
+		for _, c := range w.collectors {
+			w.registry.Unregister(c)
+		}
+	}()
+
+	sendCtx, cancelSend := context.WithCancel(context.Background())
+	defer cancelSend()
+
+	var wg sync.WaitGroup
+	for _, shard := range w.shards {
+		wg.Add(1)
+		go func(shard chan remoteWriteSample) {
+			defer wg.Done()
+			w.runShard(sendCtx, shard)
+		}(shard)
+	}
+
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+
+	w.collect()
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-ticker.C:
+			w.collect()
+		}
+	}
+
+	// send the last samples
+	w.collect()
+	for _, shard := range w.shards {
+		close(shard)
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+	case <-time.After(w.options.FlushTimeout):
+		cancelSend()
+		<-flushed
+	}
+
+	return nil
+}
+
+// collect gathers the samples of the registry and queues them.
+func (w *RemoteWriter) collect() {
+	families, err := w.registry.Gather()
+	if err != nil {
+		remoteWriteLog.Error(err, "Failed to gather the metrics")
+	}
+
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	for _, family := range families {
+		for _, sample := range w.familySamples(family, timestamp) {
+			shard := w.shards[remoteWriteShard(sample.labels, len(w.shards))]
+			select {
+			case shard <- sample:
+			default:
+				w.samplesDropped.Inc()
+			}
+		}
+	}
+}
+
+// familySamples returns the samples of a metric family, the histograms and summaries being flattened as by the
+// Prometheus scrapes.
+func (w *RemoteWriter) familySamples(family *dto.MetricFamily, timestamp int64) []remoteWriteSample {
+	var samples []remoteWriteSample
+
+	for _, m := range family.GetMetric() {
+		ts := timestamp
+		if m.TimestampMs != nil {
+			ts = m.GetTimestampMs()
+		}
+
+		add := func(suffix string, value float64, extra ...string) {
+			labels := w.sampleLabels(family.GetName()+suffix, m.GetLabel(), extra...)
+			samples = append(samples, remoteWriteSample{labels: labels, value: value, timestamp: ts})
+		}
+
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			add("", m.GetCounter().GetValue())
+
+		case dto.MetricType_GAUGE:
+			add("", m.GetGauge().GetValue())
+
+		case dto.MetricType_SUMMARY:
+			summary := m.GetSummary()
+			for _, q := range summary.GetQuantile() {
+				add("", q.GetValue(), "quantile", formatRemoteWriteFloat(q.GetQuantile()))
+			}
+			add("_sum", summary.GetSampleSum())
+			add("_count", float64(summary.GetSampleCount()))
+
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			histogram := m.GetHistogram()
+			infSeen := false
+			for _, b := range histogram.GetBucket() {
+				if math.IsInf(b.GetUpperBound(), 1) {
+					infSeen = true
+				}
+				add("_bucket", float64(b.GetCumulativeCount()), "le", formatRemoteWriteFloat(b.GetUpperBound()))
+			}
+			if !infSeen {
+				add("_bucket", float64(histogram.GetSampleCount()), "le", "+Inf")
+			}
+			add("_sum", histogram.GetSampleSum())
+			add("_count", float64(histogram.GetSampleCount()))
+
+		default:
+			add("", m.GetUntyped().GetValue())
+		}
+	}
+
+	return samples
+}
+
+// sampleLabels returns the labels of a sample sorted by name, with the external labels.
+func (w *RemoteWriter) sampleLabels(name string, pairs []*dto.LabelPair, extra ...string) []remoteWriteLabel {
+	labels := make([]remoteWriteLabel, 0, 1+len(pairs)+len(extra)/2+len(w.options.ExternalLabels))
+	labels = append(labels, remoteWriteLabel{name: "__name__", value: name})
+
+	seen := make(map[string]bool, len(pairs)+len(extra)/2)
+	for _, pair := range pairs {
+		labels = append(labels, remoteWriteLabel{name: pair.GetName(), value: pair.GetValue()})
+		seen[pair.GetName()] = true
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		labels = append(labels, remoteWriteLabel{name: extra[i], value: extra[i+1]})
+		seen[extra[i]] = true
+	}
+
+	for labelName, value := range w.options.ExternalLabels {
+		if !seen[labelName] {
+			labels = append(labels, remoteWriteLabel{name: labelName, value: value})
+		}
+	}
+
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+// remoteWriteShard returns the shard of the series with the specified labels.
+func remoteWriteShard(labels []remoteWriteLabel, shards int) int {
+	h := fnv.New32a()
+	for _, l := range labels {
+		_, _ = h.Write([]byte(l.name))
+		_, _ = h.Write([]byte{0xff})
+		_, _ = h.Write([]byte(l.value))
+		_, _ = h.Write([]byte{0xff})
+	}
+	return int(h.Sum32() % uint32(shards))
+}
+
+// runShard sends the samples of a shard in batches, until the shard is closed.
+func (w *RemoteWriter) runShard(ctx context.Context, shard chan remoteWriteSample) {
+	batch := make([]remoteWriteSample, 0, w.options.MaxSamplesPerSend)
+
+	timer := time.NewTimer(w.options.BatchSendDeadline)
+	defer timer.Stop()
+
+	send := func() {
+		if len(batch) > 0 {
+			w.send(ctx, batch)
+			batch = batch[:0]
+		}
+
+		// The deadline of the next batch starts once the batch is sent, whatever triggered the send.
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(w.options.BatchSendDeadline)
+	}
+
+	for {
+		select {
+		case sample, ok := <-shard:
+			if !ok {
+				send()
+				return
+			}
+
+			batch = append(batch, sample)
+			if len(batch) >= w.options.MaxSamplesPerSend {
+				send()
+			}
+
+		case <-timer.C:
+			send()
+		}
+	}
+}
+
+// send sends a batch of samples, retrying the recoverable failures until the context is done.
+func (w *RemoteWriter) send(ctx context.Context, batch []remoteWriteSample) {
+	body := snappy.Encode(nil, encodeRemoteWriteRequest(batch))
+	backoff := w.options.MinBackoff
+
+	for {
+		start := time.Now()
+		retryAfter, err := w.post(ctx, body)
+		w.sendDuration.Observe(time.Since(start).Seconds())
+
+		if err == nil {
+			w.samplesSent.Add(float64(len(batch)))
+			return
+		}
+
+		var recoverable *remoteWriteRecoverableError
+		if !errors.As(err, &recoverable) {
+			w.samplesFailed.Add(float64(len(batch)))
+			remoteWriteLog.Error(err, "Failed to send the samples", "url", w.options.URL, "samples", len(batch))
+			return
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+
+		select {
+		case <-ctx.Done():
+			w.samplesDropped.Add(float64(len(batch)))
+			remoteWriteLog.Error(err, "Dropping the samples on shutdown", "url", w.options.URL, "samples", len(batch))
+			return
+		case <-time.After(wait):
+		}
+
+		w.retries.Inc()
+		if backoff *= 2; backoff > w.options.MaxBackoff {
+			backoff = w.options.MaxBackoff
+		}
+	}
+}
+
+// remoteWriteRecoverableError is the error of a request that can be retried.
+type remoteWriteRecoverableError struct {
+	err error
+}
+
+func (e *remoteWriteRecoverableError) Error() string { return e.err.Error() }
+
+func (e *remoteWriteRecoverableError) Unwrap() error { return e.err }
+
+// post posts a compressed write request, it returns the time to wait before retrying from the ``Retry-After``
+// header of a 429 response, in seconds or as an HTTP date.
+func (w *RemoteWriter) post(ctx context.Context, body []byte) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.options.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.options.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	for name, value := range w.options.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", remoteWriteVersion)
+
+	if w.options.Username != "" || w.options.Password != "" {
+		req.SetBasicAuth(w.options.Username, w.options.Password)
+	}
+
+	resp, err := w.options.Client.Do(req)
+	if err != nil {
+		return 0, &remoteWriteRecoverableError{err: err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if resp.StatusCode/100 == 2 {
+		return 0, nil
+	}
+
+	err = fmt.Errorf("remote-write request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return retryAfter, &remoteWriteRecoverableError{err: err}
+	case resp.StatusCode/100 == 5:
+		return 0, &remoteWriteRecoverableError{err: err}
+	default:
+		return 0, err
+	}
+}
+
+// parseRetryAfter returns the time to wait of a ``Retry-After`` header, either a number of seconds or an HTTP date.
+// It returns 0 when the header is missing, invalid or in the past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+// encodeRemoteWriteRequest encodes the samples as a remote-write ``WriteRequest`` protobuf message, a time series
+// per sample.
+func encodeRemoteWriteRequest(samples []remoteWriteSample) []byte {
+	var request, series, message []byte
+
+	for _, sample := range samples {
+		series = series[:0]
+		for _, l := range sample.labels {
+			message = message[:0]
+			message = protowire.AppendTag(message, 1, protowire.BytesType)
+			message = protowire.AppendString(message, l.name)
+			message = protowire.AppendTag(message, 2, protowire.BytesType)
+			message = protowire.AppendString(message, l.value)
+
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, message)
+		}
+
+		message = message[:0]
+		message = protowire.AppendTag(message, 1, protowire.Fixed64Type)
+		message = protowire.AppendFixed64(message, math.Float64bits(sample.value))
+		message = protowire.AppendTag(message, 2, protowire.VarintType)
+		message = protowire.AppendVarint(message, uint64(sample.timestamp))
+
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, message)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, series)
+	}
+
+	return request
+}
+
+func formatRemoteWriteFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,318 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteSeries is a time series decoded from a write request.
+type remoteWriteSeries struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// decodeRemoteWriteRequest decodes a snappy compressed ``WriteRequest`` protobuf message.
+func decodeRemoteWriteRequest(body []byte) ([]remoteWriteSeries, error) {
+	request, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var series []remoteWriteSeries
+	err = consumeMessages(request, func(number protowire.Number, b []byte) error {
+		if number != 1 {
+			return nil
+		}
+
+		s := remoteWriteSeries{labels: make(map[string]string)}
+		err := consumeMessages(b, func(number protowire.Number, b []byte) error {
+			switch number {
+			case 1:
+				var name, value string
+				err := consumeMessages(b, func(number protowire.Number, b []byte) error {
+					if number == 1 {
+						name = string(b)
+					} else {
+						value = string(b)
+					}
+					return nil
+				})
+				s.labels[name] = value
+				return err
+
+			case 2:
+				for len(b) > 0 {
+					number, typ, n := protowire.ConsumeTag(b)
+					if n < 0 {
+						return protowire.ParseError(n)
+					}
+					b = b[n:]
+
+					switch {
+					case number == 1 && typ == protowire.Fixed64Type:
+						v, n := protowire.ConsumeFixed64(b)
+						if n < 0 {
+							return protowire.ParseError(n)
+						}
+						s.value, b = math.Float64frombits(v), b[n:]
+					case number == 2 && typ == protowire.VarintType:
+						v, n := protowire.ConsumeVarint(b)
+						if n < 0 {
+							return protowire.ParseError(n)
+						}
+						s.timestamp, b = int64(v), b[n:]
+					default:
+						return fmt.Errorf("unexpected sample field %d", number)
+					}
+				}
+			}
+			return nil
+		})
+		series = append(series, s)
+		return err
+	})
+
+	return series, err
+}
+
+// consumeMessages calls the specified function with every length-delimited field of a protobuf message.
+func consumeMessages(b []byte, f func(protowire.Number, []byte) error) error {
+	for len(b) > 0 {
+		number, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if typ != protowire.BytesType {
+			return fmt.Errorf("unexpected wire type %d of field %d", typ, number)
+		}
+
+		value, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := f(number, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeRemoteWriteReceiver answers the write requests with the specified statuses, then with 204.
+type fakeRemoteWriteReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+	times    []time.Time
+}
+
+func (r *fakeRemoteWriteReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header)
+	r.times = append(r.times, time.Now())
+
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "0")
+	}
+	w.WriteHeader(status)
+}
+
+func (r *fakeRemoteWriteReceiver) requests() ([][]byte, []http.Header) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([][]byte(nil), r.bodies...), append([]http.Header(nil), r.headers...)
+}
+
+func TestRemoteWriterRetries(t *testing.T) {
+	receiver := &fakeRemoteWriteReceiver{
+		statuses: []int{http.StatusInternalServerError, http.StatusTooManyRequests},
+	}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "orders_total"}, []string{"status"})
+	registry.MustRegister(counter)
+	counter.WithLabelValues("paid").Add(3)
+
+	writer, err := NewRemoteWriter(registry, RemoteWriteOptions{
+		URL:               server.URL,
+		Username:          "user",
+		Password:          "secret",
+		ExternalLabels:    map[string]string{"instance": "worker-1"},
+		Interval:          time.Hour,
+		Shards:            1,
+		BatchSendDeadline: 10 * time.Millisecond,
+		MinBackoff:        time.Millisecond,
+		MaxBackoff:        time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewRemoteWriter() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- writer.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if bodies, _ := receiver.requests(); len(bodies) >= 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the failed request is retried on 500 and 429
+	if got := testutil.ToFloat64(writer.retries); got != 2 {
+		t.Errorf("retries = %v, want 2", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if err := writer.Run(context.Background()); err == nil {
+		t.Error("Run() runs again once done")
+	}
+
+	bodies, headers := receiver.requests()
+	if len(bodies) < 3 {
+		t.Fatalf("got %d requests, want at least 3", len(bodies))
+	}
+
+	for i, body := range bodies[:3] {
+		if string(body) != string(bodies[0]) {
+			t.Errorf("retried request %d differs from the first one", i)
+		}
+	}
+
+	header := headers[0]
+	if header.Get("Content-Encoding") != "snappy" || header.Get("Content-Type") != "application/x-protobuf" {
+		t.Errorf("unexpected headers %v", header)
+	}
+	if username, password, _ := (&http.Request{Header: header}).BasicAuth(); username != "user" || password != "secret" {
+		t.Errorf("basic auth = %s:%s, want user:secret", username, password)
+	}
+
+	series, err := decodeRemoteWriteRequest(bodies[0])
+	if err != nil {
+		t.Fatalf("failed to decode the write request: %v", err)
+	}
+
+	found := false
+	for _, s := range series {
+		if s.labels["__name__"] != "orders_total" {
+			continue
+		}
+
+		found = true
+		if s.labels["status"] != "paid" || s.labels["instance"] != "worker-1" {
+			t.Errorf("labels = %v, want status=paid and instance=worker-1", s.labels)
+		}
+		if s.value != 3 {
+			t.Errorf("value = %v, want 3", s.value)
+		}
+		if s.timestamp <= 0 {
+			t.Errorf("timestamp = %d, want a timestamp", s.timestamp)
+		}
+	}
+	if !found {
+		t.Errorf("orders_total not sent: %v", series)
+	}
+}
+
+func TestRemoteWriterBatchSendDeadline(t *testing.T) {
+	receiver := &fakeRemoteWriteReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	deadline := 300 * time.Millisecond
+	writer, err := NewRemoteWriter(prometheus.NewRegistry(), RemoteWriteOptions{
+		URL:               server.URL,
+		MaxSamplesPerSend: 2,
+		BatchSendDeadline: deadline,
+	})
+	if err != nil {
+		t.Fatalf("NewRemoteWriter() error = %v", err)
+	}
+
+	shard := make(chan remoteWriteSample, 3)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		writer.runShard(context.Background(), shard)
+	}()
+
+	// the full batch is sent before the deadline, the deadline of the next batch starts then
+	time.Sleep(deadline * 2 / 3)
+	for i := 0; i < 3; i++ {
+		shard <- remoteWriteSample{labels: []remoteWriteLabel{{name: "__name__", value: "test"}}, value: float64(i)}
+	}
+
+	for end := time.Now().Add(5 * time.Second); time.Now().Before(end); time.Sleep(time.Millisecond) {
+		if bodies, _ := receiver.requests(); len(bodies) >= 2 {
+			break
+		}
+	}
+	close(shard)
+	<-done
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if len(receiver.times) < 2 {
+		t.Fatalf("got %d requests, want 2", len(receiver.times))
+	}
+	if gap := receiver.times[1].Sub(receiver.times[0]); gap < deadline*5/6 {
+		t.Errorf("the next batch is sent %v after the full batch, want about %v", gap, deadline)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "5", want: 5 * time.Second},
+		{value: "-1", want: 0},
+		{value: "Mon, 01 Jan 2024 12:00:30 GMT", want: 30 * time.Second},
+		{value: "Mon, 01 Jan 2024 11:59:00 GMT", want: 0},
+		{value: "soon", want: 0},
+	} {
+		if got := parseRetryAfter(test.value, now); got != test.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
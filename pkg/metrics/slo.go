@@ -0,0 +1,379 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// SLODefaultPeriod defines the default period of the error budget of an SLO.
+	SLODefaultPeriod = 28 * 24 * time.Hour
+
+	// sloResolution is the resolution of the request counts of an SLO.
+	sloResolution = time.Minute
+)
+
+// SLODefaultWindows defines the default windows the burn rates are computed over. They are the windows of the
+// multi-window burn rate alerts, see ``SLOReport.Alerts``.
+var SLODefaultWindows = []time.Duration{
+	5 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	3 * 24 * time.Hour,
+}
+
+// sloAlerts are the multi-window burn rate alerts: an alert fires when the burn rates over both its long and short
+// windows exceed its threshold.
+var sloAlerts = []struct {
+	severity  string
+	long      time.Duration
+	short     time.Duration
+	threshold float64
+}{
+	{severity: "page", long: time.Hour, short: 5 * time.Minute, threshold: 14.4},
+	{severity: "page", long: 6 * time.Hour, short: 30 * time.Minute, threshold: 6},
+	{severity: "ticket", long: 24 * time.Hour, short: 2 * time.Hour, threshold: 3},
+	{severity: "ticket", long: 3 * 24 * time.Hour, short: 6 * time.Hour, threshold: 1},
+}
+
+// SLO defines a service level objective on a route, i.e. 99.9% of the ``/api/orders`` requests served without server
+// error in less than 300ms over 28 days.
+type SLO struct {
+	// Name defines the name of the SLO, it must be unique. It defaults to the route.
+	Name string
+	// Route defines the route of the requests, i.e. its path template ``/api/orders/{id}``.
+	Route string
+	// Methods lists the methods of the requests. The requests of all the methods are counted when empty.
+	Methods []string
+	// Objective defines the ratio of good requests, i.e. 0.999. A request is good when it is served without server
+	// error, with a status below 500, and within Latency.
+	Objective float64
+	// Latency defines the maximum duration of a good request. The duration is not part of the objective when 0.
+	Latency time.Duration
+	// Period defines the period of the error budget. It defaults to ``SLODefaultPeriod``.
+	Period time.Duration
+}
+
+// SLOTrackerOptions represents the SLO tracker configuration options.
+type SLOTrackerOptions struct {
+	// Windows defines the windows the burn rates are computed over. It defaults to ``SLODefaultWindows``.
+	Windows []time.Duration
+	// Registerer defines where the SLO metrics are registered, i.e. the registry of the Prometheus exporter, see
+	// ``Exporter.Registry``. It is required.
+	Registerer prometheus.Registerer
+}
+
+func setSLOTrackerOptionsDefaults(options *SLOTrackerOptions) {
+	if len(options.Windows) == 0 {
+		options.Windows = SLODefaultWindows
+	}
+}
+
+// SLOReport is the state of an SLO.
+type SLOReport struct {
+	Name      string   `json:"name"`
+	Route     string   `json:"route"`
+	Methods   []string `json:"methods,omitempty"`
+	Objective float64  `json:"objective"`
+	Latency   string   `json:"latency,omitempty"`
+	Period    string   `json:"period"`
+	// Total and Good are the numbers of requests and good requests over the period.
+	Total uint64 `json:"total"`
+	Good  uint64 `json:"good"`
+	// ErrorBudgetRemaining is the ratio of the error budget of the period that is not consumed, it is negative once
+	// the budget is exhausted.
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// BurnRates are the burn rates by window: the ratio of bad requests over the window divided by the error budget
+	// ratio. A burn rate of 1 consumes exactly the error budget over the period. The windows longer than the period
+	// are the period.
+	BurnRates map[string]float64 `json:"burn_rates"`
+	// Alerts are the multi-window burn rate alerts by severity: ``page`` when the budget burns 14.4 times too fast
+	// over 1h and 5m, or 6 times over 6h and 30m, ``ticket`` when it burns 3 times too fast over 1d and 2h, or 1
+	// time over 3d and 6h.
+	Alerts map[string]bool `json:"alerts"`
+}
+
+// SLOTracker tracks the SLOs of the routes of a server from its requests, see ``middlewares.SLOHandler``. The
+// requests are counted in memory per minute: the error budget of a process younger than the SLO period covers its
+// lifetime only.
+//
+// The tracker registers its metrics, labeled with the ``slo`` name:
+//
+// - ``slo_objective`` gauge
+//
+// - ``slo_burn_rate`` gauge, with a ``window`` label
+//
+// - ``slo_error_budget_remaining`` gauge
+//
+// - ``slo_alert`` gauge, with a ``severity`` label, 1 when the alert fires: alert on ``slo_alert > 0`` instead of
+// writing the burn rate recording rules
+//
+// It serves the SLO reports as a JSON array, see ``routes.AddSLO``.
+type SLOTracker struct {
+	slos    []*sloState
+	windows []time.Duration
+	// now returns the current time, it is replaced by the tests
+	now func() time.Time
+
+	objectiveDesc       *prometheus.Desc
+	burnRateDesc        *prometheus.Desc
+	budgetRemainingDesc *prometheus.Desc
+	alertDesc           *prometheus.Desc
+}
+
+// sloState holds the request counts of an SLO.
+type sloState struct {
+	slo     SLO
+	methods map[string]bool
+
+	mu      sync.Mutex
+	buckets []sloBucket
+}
+
+// sloBucket holds the request counts of a minute.
+type sloBucket struct {
+	minute int64
+	good   uint64
+	total  uint64
+}
+
+// NewSLOTracker creates a new tracker of the specified SLOs and registers its metrics.
+func NewSLOTracker(slos []SLO, options SLOTrackerOptions) (*SLOTracker, error) {
+	setSLOTrackerOptionsDefaults(&options)
+
+	if options.Registerer == nil {
+		return nil, errors.New("missing SLO tracker registerer")
+	}
+
+	t := &SLOTracker{
+		windows: append([]time.Duration(nil), options.Windows...),
+		now:     time.Now,
+		objectiveDesc: prometheus.NewDesc(
+			"slo_objective", "The ratio of good requests of the SLO.", []string{"slo"}, nil),
+		burnRateDesc: prometheus.NewDesc(
+			"slo_burn_rate", "The error budget burn rate of the SLO over the window.", []string{"slo", "window"}, nil),
+		budgetRemainingDesc: prometheus.NewDesc(
+			"slo_error_budget_remaining", "The ratio of the error budget of the SLO that is not consumed.",
+			[]string{"slo"}, nil),
+		alertDesc: prometheus.NewDesc(
+			"slo_alert", "1 when the burn rate alert of the SLO fires.", []string{"slo", "severity"}, nil),
+	}
+	sort.Slice(t.windows, func(i, j int) bool { return t.windows[i] < t.windows[j] })
+
+	names := make(map[string]bool, len(slos))
+	for _, slo := range slos {
+		if slo.Name == "" {
+			slo.Name = slo.Route
+		}
+
+		if slo.Period <= 0 {
+			slo.Period = SLODefaultPeriod
+		}
+
+		switch {
+		case slo.Route == "":
+			return nil, fmt.Errorf("SLO %q has no route", slo.Name)
+		case slo.Objective <= 0 || slo.Objective >= 1:
+			return nil, fmt.Errorf("SLO %q objective %v is not between 0 and 1", slo.Name, slo.Objective)
+		case names[slo.Name]:
+			return nil, fmt.Errorf("duplicate SLO %q", slo.Name)
+		}
+		names[slo.Name] = true
+
+		state := &sloState{
+			slo:     slo,
+			buckets: make([]sloBucket, (slo.Period+sloResolution-1)/sloResolution),
+		}
+
+		if len(slo.Methods) > 0 {
+			state.methods = make(map[string]bool, len(slo.Methods))
+			for _, method := range slo.Methods {
+				state.methods[method] = true
+			}
+		}
+
+		t.slos = append(t.slos, state)
+	}
+
+	if err := options.Registerer.Register(t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Observe counts a request of the specified route, i.e. its path template, in the SLOs of the route.
+func (t *SLOTracker) Observe(method, route string, status int, duration time.Duration) {
+	minute := t.now().Unix() / int64(sloResolution/time.Second)
+
+	for _, state := range t.slos {
+		if state.slo.Route != route || (state.methods != nil && !state.methods[method]) {
+			continue
+		}
+
+		good := status < http.StatusInternalServerError && (state.slo.Latency <= 0 || duration <= state.slo.Latency)
+		state.observe(minute, good)
+	}
+}
+
+func (s *sloState) observe(minute int64, good bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket := &s.buckets[minute%int64(len(s.buckets))]
+	if bucket.minute != minute {
+		*bucket = sloBucket{minute: minute}
+	}
+
+	bucket.total++
+	if good {
+		bucket.good++
+	}
+}
+
+// Reports returns the reports of the SLOs.
+func (t *SLOTracker) Reports() []SLOReport {
+	minute := t.now().Unix() / int64(sloResolution/time.Second)
+
+	reports := make([]SLOReport, len(t.slos))
+	for i, state := range t.slos {
+		reports[i] = state.report(minute, t.windows)
+	}
+	return reports
+}
+
+// report computes the report of the SLO, summing the request counts of the windows from the current minute.
+func (s *sloState) report(minute int64, windows []time.Duration) SLOReport {
+	budget := 1 - s.slo.Objective
+
+	report := SLOReport{
+		Name:      s.slo.Name,
+		Route:     s.slo.Route,
+		Methods:   s.slo.Methods,
+		Objective: s.slo.Objective,
+		Period:    s.slo.Period.String(),
+		BurnRates: make(map[string]float64, len(windows)),
+		Alerts:    make(map[string]bool, len(sloAlerts)),
+	}
+
+	if s.slo.Latency > 0 {
+		report.Latency = s.slo.Latency.String()
+	}
+
+	// the windows of the alerts are computed along with the exported ones
+	burnRates := make(map[time.Duration]float64)
+	all := append([]time.Duration(nil), windows...)
+	for _, alert := range sloAlerts {
+		all = append(all, alert.long, alert.short)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+	burnRate := func(good, total uint64) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(total-good) / float64(total) / budget
+	}
+
+	s.mu.Lock()
+	var good, total uint64
+	next := 0
+	for i := range s.buckets {
+		bucket := s.buckets[(minute-int64(i))%int64(len(s.buckets))]
+		if bucket.minute == minute-int64(i) {
+			good += bucket.good
+			total += bucket.total
+		}
+
+		for ; next < len(all) && all[next] <= time.Duration(i+1)*sloResolution; next++ {
+			burnRates[all[next]] = burnRate(good, total)
+		}
+	}
+	s.mu.Unlock()
+
+	// the windows longer than the period are the period
+	for ; next < len(all); next++ {
+		burnRates[all[next]] = burnRate(good, total)
+	}
+
+	report.Total = total
+	report.Good = good
+	report.ErrorBudgetRemaining = 1 - burnRate(good, total)
+
+	for _, window := range windows {
+		report.BurnRates[formatSLOWindow(window)] = burnRates[window]
+	}
+
+	for _, alert := range sloAlerts {
+		fires := burnRates[alert.long] > alert.threshold && burnRates[alert.short] > alert.threshold
+		report.Alerts[alert.severity] = report.Alerts[alert.severity] || fires
+	}
+
+	return report
+}
+
+// Describe implements ``prometheus.Collector``.
+func (t *SLOTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.objectiveDesc
+	ch <- t.burnRateDesc
+	ch <- t.budgetRemainingDesc
+	ch <- t.alertDesc
+}
+
+// Collect implements ``prometheus.Collector``, the SLO metrics are computed when collected.
+func (t *SLOTracker) Collect(ch chan<- prometheus.Metric) {
+	for _, report := range t.Reports() {
+		ch <- prometheus.MustNewConstMetric(t.objectiveDesc, prometheus.GaugeValue, report.Objective, report.Name)
+		ch <- prometheus.MustNewConstMetric(
+			t.budgetRemainingDesc, prometheus.GaugeValue, report.ErrorBudgetRemaining, report.Name)
+
+		for _, window := range t.windows {
+			w := formatSLOWindow(window)
+			ch <- prometheus.MustNewConstMetric(t.burnRateDesc, prometheus.GaugeValue, report.BurnRates[w], report.Name, w)
+		}
+
+		for _, severity := range []string{"page", "ticket"} {
+			value := 0.0
+			if report.Alerts[severity] {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(t.alertDesc, prometheus.GaugeValue, value, report.Name, severity)
+		}
+	}
+}
+
+// ServeHTTP returns the SLO reports as a JSON array.
+func (t *SLOTracker) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	body, err := json.Marshal(t.Reports())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(body, '\n'))
+}
+
+// formatSLOWindow formats a window as the Prometheus durations, i.e. ``5m``, ``6h`` or ``3d``.
+func formatSLOWindow(window time.Duration) string {
+	switch {
+	case window%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", window/(24*time.Hour))
+	case window%time.Hour == 0:
+		return fmt.Sprintf("%dh", window/time.Hour)
+	case window%time.Minute == 0:
+		return fmt.Sprintf("%dm", window/time.Minute)
+	default:
+		return window.String()
+	}
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestSLOTracker creates an SLO tracker of the specified SLO, with a clock set by the returned function.
+func newTestSLOTracker(t *testing.T, slo SLO) (*SLOTracker, func(time.Time)) {
+	t.Helper()
+
+	tracker, err := NewSLOTracker([]SLO{slo}, SLOTrackerOptions{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("NewSLOTracker() error = %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	return tracker, func(t time.Time) { now = t }
+}
+
+// observe counts the specified number of requests.
+func observe(tracker *SLOTracker, n int, method, route string, status int, duration time.Duration) {
+	for i := 0; i < n; i++ {
+		tracker.Observe(method, route, status, duration)
+	}
+}
+
+func checkFloat(t *testing.T, name string, got, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func TestSLOTrackerRegisterer(t *testing.T) {
+	if _, err := NewSLOTracker([]SLO{{Route: "/api", Objective: 0.99}}, SLOTrackerOptions{}); err == nil {
+		t.Error("NewSLOTracker() succeeds without registerer")
+	}
+}
+
+func TestSLOTrackerBurnRates(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker, setNow := newTestSLOTracker(t, SLO{
+		Route:     "/api/orders",
+		Methods:   []string{http.MethodGet},
+		Objective: 0.99,
+		Latency:   100 * time.Millisecond,
+	})
+
+	// 100 good requests 10 minutes ago, then 50 good and 50 bad requests
+	setNow(start.Add(-10 * time.Minute))
+	observe(tracker, 100, http.MethodGet, "/api/orders", http.StatusOK, 10*time.Millisecond)
+
+	setNow(start)
+	observe(tracker, 50, http.MethodGet, "/api/orders", http.StatusOK, 10*time.Millisecond)
+	observe(tracker, 25, http.MethodGet, "/api/orders", http.StatusInternalServerError, 10*time.Millisecond)
+	observe(tracker, 25, http.MethodGet, "/api/orders", http.StatusOK, time.Second)
+
+	// not part of the SLO
+	observe(tracker, 10, http.MethodPost, "/api/orders", http.StatusInternalServerError, 0)
+	observe(tracker, 10, http.MethodGet, "/api/users", http.StatusInternalServerError, 0)
+
+	report := tracker.Reports()[0]
+	if report.Total != 200 || report.Good != 150 {
+		t.Errorf("total = %d, good = %d, want 200 and 150", report.Total, report.Good)
+	}
+
+	// 50% of bad requests over 5m, 25% over 30m, for a 1% error budget
+	checkFloat(t, "5m burn rate", report.BurnRates["5m"], 50)
+	checkFloat(t, "30m burn rate", report.BurnRates["30m"], 25)
+	checkFloat(t, "3d burn rate", report.BurnRates["3d"], 25)
+	checkFloat(t, "error budget remaining", report.ErrorBudgetRemaining, -24)
+
+	if !report.Alerts["page"] || !report.Alerts["ticket"] {
+		t.Errorf("alerts = %v, want page and ticket", report.Alerts)
+	}
+
+	if got := testutil.CollectAndCount(tracker); got != 2+len(SLODefaultWindows)+2 {
+		t.Errorf("collected %d metrics, want %d", got, 2+len(SLODefaultWindows)+2)
+	}
+
+	// the bad requests leave the short windows
+	setNow(start.Add(2 * time.Hour))
+	report = tracker.Reports()[0]
+
+	for _, window := range []string{"5m", "30m", "1h", "2h"} {
+		checkFloat(t, window+" burn rate", report.BurnRates[window], 0)
+	}
+	checkFloat(t, "6h burn rate", report.BurnRates["6h"], 25)
+
+	if report.Alerts["page"] || !report.Alerts["ticket"] {
+		t.Errorf("alerts = %v, want ticket only", report.Alerts)
+	}
+}
+
+func TestSLOTrackerPeriod(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker, setNow := newTestSLOTracker(t, SLO{Route: "/api", Objective: 0.9, Period: time.Hour})
+
+	setNow(start)
+	observe(tracker, 5, http.MethodGet, "/api", http.StatusOK, 0)
+	observe(tracker, 5, http.MethodGet, "/api", http.StatusServiceUnavailable, 0)
+
+	// the windows longer than the period are the period
+	report := tracker.Reports()[0]
+	checkFloat(t, "3d burn rate", report.BurnRates["3d"], 5)
+	checkFloat(t, "error budget remaining", report.ErrorBudgetRemaining, -4)
+
+	// the requests older than the period are forgotten
+	setNow(start.Add(time.Hour))
+	report = tracker.Reports()[0]
+	if report.Total != 0 {
+		t.Errorf("total = %d, want 0 once the period is over", report.Total)
+	}
+	checkFloat(t, "error budget remaining", report.ErrorBudgetRemaining, 1)
+}
@@ -0,0 +1,642 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opencensus.io/stats"
+	ocview "go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/mojun2021/micro-server/pkg/logger"
+)
+
+// StatsDFormat defines the line format of the metrics sent to a StatsD agent.
+type StatsDFormat string
+
+const (
+	// StatsDFormatStatsD sends the metrics in the StatsD format, the tags are appended to the metric names with the
+	// Graphite syntax: ``name;key=value``.
+	StatsDFormatStatsD StatsDFormat = "statsd"
+	// StatsDFormatDogStatsD sends the metrics in the DogStatsD format: ``name:value|type|#key:value``.
+	StatsDFormatDogStatsD StatsDFormat = "dogstatsd"
+)
+
+const (
+	// StatsDAddressEnvironmentVariable defines the name of the environment variable holding the StatsD agent address.
+	StatsDAddressEnvironmentVariable = "USGO_STATSD_ADDRESS"
+
+	// StatsDDefaultAddress defines the default StatsD agent address.
+	StatsDDefaultAddress = "127.0.0.1:8125"
+	// StatsDDefaultInterval defines the default time between two sends.
+	StatsDDefaultInterval = 10 * time.Second
+	// StatsDDefaultUDPPacketSize defines the default maximum size of the UDP packets, fitting the Ethernet MTU.
+	StatsDDefaultUDPPacketSize = 1432
+	// StatsDDefaultUnixPacketSize defines the default maximum size of the Unix datagrams.
+	StatsDDefaultUnixPacketSize = 8192
+	// StatsDDefaultWriteTimeout defines the default timeout of a packet write.
+	StatsDDefaultWriteTimeout = 100 * time.Millisecond
+
+	// StatsDDropReasonTagKeyName defines the tag key name of the reason of a dropped packet.
+	StatsDDropReasonTagKeyName = "reason"
+
+	statsDUnixScheme = "unix://"
+	// statsDGathererScope is the instrumentation scope of the metrics of the gatherer, see ``StatsDOptions.Gatherer``.
+	statsDGathererScope = "github.com/prometheus/client_golang"
+)
+
+var statsDLog = logger.Log.WithName("metrics").WithName("statsd")
+
+var (
+	// Counts the number of packets dropped by the StatsD exporters.
+	mStatsDDroppedPackets = stats.Int64(
+		"micro-server/metrics_statsd_dropped_packets", "The number of packets dropped by the StatsD exporters", "1")
+
+	statsDDropReasonKey, _ = tag.NewKey(StatsDDropReasonTagKeyName)
+
+	// StatsDDroppedPacketCountView is the number of packets dropped by the StatsD exporters view. It has 1 tag,
+	// ``reason``: ``write`` when the packet cannot be written, i.e. the agent buffer is full, or ``size`` when a
+	// metric does not fit in a packet.
+	StatsDDroppedPacketCountView = &ocview.View{
+		Name:        "micro-server/metrics_statsd_dropped_packets",
+		Measure:     mStatsDDroppedPackets,
+		Description: "The number of packets dropped by the StatsD exporters",
+		Aggregation: ocview.Count(),
+		TagKeys:     []tag.Key{statsDDropReasonKey},
+	}
+)
+
+// StatsDOptions represents the StatsD metrics exporter configuration options.
+type StatsDOptions struct {
+	// Address defines the StatsD agent address: ``host:port`` for UDP, or ``unix:///path/to/socket`` for a Unix
+	// datagram socket. It defaults to the ``USGO_STATSD_ADDRESS`` environment variable, then to
+	// ``StatsDDefaultAddress``.
+	Address string
+	// Format defines the line format of the metrics. It defaults to ``StatsDFormatDogStatsD``.
+	Format StatsDFormat
+	// Prefix defines the prefix of the metric names, i.e. ``myservice.``.
+	Prefix string
+	// Tags defines the tags added to every metric.
+	Tags map[string]string
+	// Interval defines the time between two sends, the metrics being aggregated in between. It defaults to
+	// ``StatsDDefaultInterval``.
+	Interval time.Duration
+	// MaxPacketSize defines the maximum size of a packet, the metrics are batched up to it. It defaults to
+	// ``StatsDDefaultUDPPacketSize`` for UDP and to ``StatsDDefaultUnixPacketSize`` for Unix datagram sockets.
+	MaxPacketSize int
+	// WriteTimeout defines the timeout of a packet write, the packet is dropped on timeout. It defaults to
+	// ``StatsDDefaultWriteTimeout``.
+	WriteTimeout time.Duration
+	// Gatherer defines the Prometheus gatherer whose metrics are sent along with the ones of the exporter, i.e. the
+	// registry of the Prometheus exporter holding the HTTP server metrics, see ``Exporter.Registry`` and
+	// ``middlewares.RequestMetrics``. The counters, gauges and histograms are sent, the summaries are not. The metrics
+	// of the gatherer with the name of a metric of the exporter are skipped, i.e. the declared metrics and the views
+	// also exported by the Prometheus exporter.
+	Gatherer prometheus.Gatherer
+}
+
+func setStatsDOptionsDefaults(options *StatsDOptions) {
+	if options.Address == "" {
+		options.Address = os.Getenv(StatsDAddressEnvironmentVariable)
+	}
+
+	if options.Address == "" {
+		options.Address = StatsDDefaultAddress
+	}
+
+	if options.Format == "" {
+		options.Format = StatsDFormatDogStatsD
+	}
+
+	if options.Interval <= 0 {
+		options.Interval = StatsDDefaultInterval
+	}
+
+	if options.MaxPacketSize <= 0 {
+		options.MaxPacketSize = StatsDDefaultUDPPacketSize
+		if strings.HasPrefix(options.Address, statsDUnixScheme) {
+			options.MaxPacketSize = StatsDDefaultUnixPacketSize
+		}
+	}
+
+	if options.WriteTimeout <= 0 {
+		options.WriteTimeout = StatsDDefaultWriteTimeout
+	}
+}
+
+// StatsDExporter sends the metrics to a StatsD or DogStatsD agent.
+//
+// The metrics are recorded with the OpenTelemetry meters of ``MeterProvider``. As with the Prometheus exporter, the
+// OpenCensus views of the exporter are bridged to OpenTelemetry and sent along with them, with
+// ``StatsDDroppedPacketCountView``.
+//
+// The metrics are aggregated by the exporter and sent every interval:
+//
+// - the counters as ``c`` counters, incremented by the difference since the last send
+//
+// - the gauges, last values and up-down counters as ``g`` gauges
+//
+// - the histograms as ``.count`` and ``.sum`` counters, and ``.bucket`` counters tagged with their ``le`` upper
+// bound
+//
+// The HTTP server metrics, i.e. the request latency histogram of ``middlewares.RequestMetrics``, are Prometheus
+// metrics: set ``StatsDOptions.Gatherer`` to send them.
+type StatsDExporter struct {
+	provider *sdkmetric.MeterProvider
+	views    *viewSet
+	declared *declaredBinding
+}
+
+// NewStatsDExporter creates a new StatsD metrics exporter, sending the metrics periodically until it is shut down.
+// It is not registered as the global OpenTelemetry meter provider.
+func NewStatsDExporter(options StatsDOptions, views ...*ocview.View) (*StatsDExporter, error) {
+	setStatsDOptionsDefaults(&options)
+
+	if options.Format != StatsDFormatStatsD && options.Format != StatsDFormatDogStatsD {
+		return nil, fmt.Errorf("unsupported StatsD format %q", options.Format)
+	}
+
+	network, address := "udp", options.Address
+	if strings.HasPrefix(address, statsDUnixScheme) {
+		network, address = "unixgram", strings.TrimPrefix(address, statsDUnixScheme)
+	}
+
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	viewSet, err := registerViews(append(views, StatsDDroppedPacketCountView))
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	exporter := &statsDExporter{conn: conn, options: options}
+
+	readerOptions := []sdkmetric.PeriodicReaderOption{
+		sdkmetric.WithProducer(viewSet.producer()),
+		sdkmetric.WithInterval(options.Interval),
+	}
+	if options.Gatherer != nil {
+		readerOptions = append(readerOptions, sdkmetric.WithProducer(&gathererProducer{gatherer: options.Gatherer}))
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter, readerOptions...)
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	declared, err := bindDeclaredMetrics(provider)
+	if err != nil {
+		_ = provider.Shutdown(context.Background())
+		viewSet.unregister()
+		return nil, err
+	}
+
+	return &StatsDExporter{
+		provider: provider,
+		views:    viewSet,
+		declared: declared,
+	}, nil
+}
+
+// MeterProvider returns the OpenTelemetry meter provider of the exported metrics.
+func (e *StatsDExporter) MeterProvider() metric.MeterProvider {
+	return e.provider
+}
+
+// Meter returns the OpenTelemetry meter with the specified instrumentation name.
+func (e *StatsDExporter) Meter(name string, options ...metric.MeterOption) metric.Meter {
+	return e.provider.Meter(name, options...)
+}
+
+// ForceFlush sends the current metrics without waiting for the next interval.
+func (e *StatsDExporter) ForceFlush(ctx context.Context) error {
+	return e.provider.ForceFlush(ctx)
+}
+
+// Shutdown sends the current metrics then stops the exporter, closes its connection and unregisters its views.
+func (e *StatsDExporter) Shutdown(ctx context.Context) error {
+	defer e.views.unregister()
+	e.declared.release()
+	return e.provider.Shutdown(ctx)
+}
+
+// Close shuts the exporter down, see ``Shutdown``.
+func (e *StatsDExporter) Close() error {
+	return e.Shutdown(context.Background())
+}
+
+// statsDSeries identifies a series, to compute the increments of the cumulative metrics.
+type statsDSeries struct {
+	name       string
+	attributes attribute.Distinct
+}
+
+// statsDHistogram is the last cumulative value of a histogram series.
+type statsDHistogram struct {
+	count   uint64
+	sum     float64
+	buckets []uint64
+}
+
+// statsDExporter is the OpenTelemetry exporter writing the metrics to the StatsD agent.
+type statsDExporter struct {
+	conn    net.Conn
+	options StatsDOptions
+
+	mu sync.Mutex
+	// counters and histograms hold the values of the cumulative series of the last export, the series missing from
+	// an export are evicted
+	counters       map[statsDSeries]float64
+	histograms     map[statsDSeries]statsDHistogram
+	nextCounters   map[statsDSeries]float64
+	nextHistograms map[statsDSeries]statsDHistogram
+	packet         []byte
+	err            error
+}
+
+// Temporality returns the delta temporality for the counters and histograms, the metrics of the OpenCensus views
+// being cumulative whatever the temporality.
+func (e *statsDExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindUpDownCounter, sdkmetric.InstrumentKindObservableUpDownCounter:
+		return metricdata.CumulativeTemporality
+	default:
+		return metricdata.DeltaTemporality
+	}
+}
+
+func (e *statsDExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (e *statsDExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.err = nil
+	e.nextCounters = make(map[statsDSeries]float64, len(e.counters))
+	e.nextHistograms = make(map[statsDSeries]statsDHistogram, len(e.histograms))
+
+	// the metrics of the exporter win over the metrics of the gatherer with the same name
+	names := make(map[string]bool)
+	for _, sm := range rm.ScopeMetrics {
+		if sm.Scope.Name != statsDGathererScope {
+			for _, m := range sm.Metrics {
+				names[sanitizePrometheusName(m.Name)] = true
+			}
+		}
+	}
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sm.Scope.Name == statsDGathererScope && names[m.Name] {
+				continue
+			}
+
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				exportStatsDSum(e, m.Name, data)
+			case metricdata.Sum[float64]:
+				exportStatsDSum(e, m.Name, data)
+			case metricdata.Gauge[int64]:
+				exportStatsDGauge(e, m.Name, data)
+			case metricdata.Gauge[float64]:
+				exportStatsDGauge(e, m.Name, data)
+			case metricdata.Histogram[int64]:
+				exportStatsDHistogram(e, m.Name, data)
+			case metricdata.Histogram[float64]:
+				exportStatsDHistogram(e, m.Name, data)
+			}
+		}
+	}
+	e.flush()
+
+	e.counters, e.nextCounters = e.nextCounters, nil
+	e.histograms, e.nextHistograms = e.nextHistograms, nil
+
+	return e.err
+}
+
+func (e *statsDExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (e *statsDExporter) Shutdown(context.Context) error {
+	return e.conn.Close()
+}
+
+func exportStatsDSum[N int64 | float64](e *statsDExporter, name string, sum metricdata.Sum[N]) {
+	for _, dp := range sum.DataPoints {
+		if !sum.IsMonotonic {
+			e.gauge(name, float64(dp.Value), dp.Attributes)
+			continue
+		}
+
+		value := float64(dp.Value)
+		if sum.Temporality == metricdata.CumulativeTemporality {
+			series := statsDSeries{name: name, attributes: dp.Attributes.Equivalent()}
+			last, ok := e.counters[series]
+			e.nextCounters[series] = value
+			if ok && value >= last {
+				value -= last
+			}
+		}
+
+		if value != 0 {
+			e.write(name, formatStatsDValue(value), "c", dp.Attributes, nil)
+		}
+	}
+}
+
+func exportStatsDGauge[N int64 | float64](e *statsDExporter, name string, gauge metricdata.Gauge[N]) {
+	for _, dp := range gauge.DataPoints {
+		e.gauge(name, float64(dp.Value), dp.Attributes)
+	}
+}
+
+func exportStatsDHistogram[N int64 | float64](e *statsDExporter, name string, histogram metricdata.Histogram[N]) {
+	for _, dp := range histogram.DataPoints {
+		current := statsDHistogram{count: dp.Count, sum: float64(dp.Sum), buckets: dp.BucketCounts}
+		delta := current
+
+		if histogram.Temporality == metricdata.CumulativeTemporality {
+			series := statsDSeries{name: name, attributes: dp.Attributes.Equivalent()}
+			last, ok := e.histograms[series]
+			e.nextHistograms[series] = statsDHistogram{
+				count:   current.count,
+				sum:     current.sum,
+				buckets: append([]uint64(nil), current.buckets...),
+			}
+
+			if ok && current.count >= last.count && len(current.buckets) == len(last.buckets) {
+				delta = statsDHistogram{
+					count:   current.count - last.count,
+					sum:     current.sum - last.sum,
+					buckets: make([]uint64, len(current.buckets)),
+				}
+				for i := range current.buckets {
+					delta.buckets[i] = current.buckets[i] - last.buckets[i]
+				}
+			}
+		}
+
+		if delta.count == 0 {
+			continue
+		}
+
+		e.write(name+".count", strconv.FormatUint(delta.count, 10), "c", dp.Attributes, nil)
+		e.write(name+".sum", formatStatsDValue(delta.sum), "c", dp.Attributes, nil)
+
+		// the bucket counters are cumulative, as the Prometheus buckets
+		var bucketCount uint64
+		for i, count := range delta.buckets {
+			bucketCount += count
+
+			le := "+Inf"
+			if i < len(dp.Bounds) {
+				le = formatStatsDValue(dp.Bounds[i])
+			}
+
+			if bucketCount != 0 {
+				e.write(name+".bucket", strconv.FormatUint(bucketCount, 10), "c", dp.Attributes, []string{"le", le})
+			}
+		}
+	}
+}
+
+// gauge writes a gauge, a negative value is set from 0 as it would decrement the gauge otherwise.
+func (e *statsDExporter) gauge(name string, value float64, attributes attribute.Set) {
+	if value < 0 {
+		e.write(name, "0", "g", attributes, nil)
+	}
+	e.write(name, formatStatsDValue(value), "g", attributes, nil)
+}
+
+// write adds a metric line to the current packet, the packet being sent when the line does not fit in it.
+func (e *statsDExporter) write(name, value, metricType string, attributes attribute.Set, extraTag []string) {
+	tags := make([]string, 0, 2*(len(e.options.Tags)+attributes.Len())+len(extraTag))
+	for _, key := range sortedKeys(e.options.Tags) {
+		tags = append(tags, key, e.options.Tags[key])
+	}
+	for _, kv := range attributes.ToSlice() {
+		tags = append(tags, string(kv.Key), kv.Value.Emit())
+	}
+	tags = append(tags, extraTag...)
+
+	var line strings.Builder
+	line.WriteString(sanitizeStatsDName(e.options.Prefix + name))
+
+	if e.options.Format == StatsDFormatStatsD {
+		for i := 0; i < len(tags); i += 2 {
+			line.WriteString(";" + sanitizeStatsDName(tags[i]) + "=" + sanitizeStatsDTag(tags[i+1]))
+		}
+	}
+
+	line.WriteString(":" + value + "|" + metricType)
+
+	if e.options.Format == StatsDFormatDogStatsD && len(tags) > 0 {
+		line.WriteString("|#")
+		for i := 0; i < len(tags); i += 2 {
+			if i > 0 {
+				line.WriteString(",")
+			}
+			line.WriteString(sanitizeStatsDName(tags[i]) + ":" + sanitizeStatsDTag(tags[i+1]))
+		}
+	}
+
+	if line.Len() > e.options.MaxPacketSize {
+		e.drop("size", errors.New("the metric does not fit in a packet: "+name))
+		return
+	}
+
+	if len(e.packet) > 0 && len(e.packet)+1+line.Len() > e.options.MaxPacketSize {
+		e.flush()
+	}
+
+	if len(e.packet) > 0 {
+		e.packet = append(e.packet, '\n')
+	}
+	e.packet = append(e.packet, line.String()...)
+}
+
+// flush sends the current packet.
+func (e *statsDExporter) flush() {
+	if len(e.packet) == 0 {
+		return
+	}
+
+	_ = e.conn.SetWriteDeadline(time.Now().Add(e.options.WriteTimeout))
+	if _, err := e.conn.Write(e.packet); err != nil {
+		e.drop("write", err)
+	}
+	e.packet = e.packet[:0]
+}
+
+// drop counts a dropped packet, the first error of the export is returned by Export.
+func (e *statsDExporter) drop(reason string, err error) {
+	_ = stats.RecordWithTags(
+		context.Background(),
+		[]tag.Mutator{tag.Upsert(statsDDropReasonKey, reason)},
+		mStatsDDroppedPackets.M(1),
+	)
+
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// gathererProducer produces the metrics of a Prometheus gatherer as cumulative OpenTelemetry metrics, see
+// ``StatsDOptions.Gatherer``.
+type gathererProducer struct {
+	gatherer prometheus.Gatherer
+}
+
+// Produce returns the metrics gathered, a gathering error is logged rather than returned as it would fail the whole
+// export.
+func (p *gathererProducer) Produce(context.Context) ([]metricdata.ScopeMetrics, error) {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		statsDLog.Error(err, "Failed to gather the metrics")
+	}
+
+	sm := metricdata.ScopeMetrics{Scope: instrumentation.Scope{Name: statsDGathererScope}}
+	for _, family := range families {
+		m := metricdata.Metrics{Name: family.GetName(), Description: family.GetHelp()}
+
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := metricdata.Sum[float64]{Temporality: metricdata.CumulativeTemporality, IsMonotonic: true}
+			for _, metric := range family.GetMetric() {
+				sum.DataPoints = append(sum.DataPoints, metricdata.DataPoint[float64]{
+					Attributes: gathererAttributes(metric.GetLabel()),
+					Value:      metric.GetCounter().GetValue(),
+				})
+			}
+			m.Data = sum
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			var gauge metricdata.Gauge[float64]
+			for _, metric := range family.GetMetric() {
+				value := metric.GetGauge().GetValue()
+				if family.GetType() == dto.MetricType_UNTYPED {
+					value = metric.GetUntyped().GetValue()
+				}
+
+				gauge.DataPoints = append(gauge.DataPoints, metricdata.DataPoint[float64]{
+					Attributes: gathererAttributes(metric.GetLabel()),
+					Value:      value,
+				})
+			}
+			m.Data = gauge
+		case dto.MetricType_HISTOGRAM:
+			histogram := metricdata.Histogram[float64]{Temporality: metricdata.CumulativeTemporality}
+			for _, metric := range family.GetMetric() {
+				histogram.DataPoints = append(histogram.DataPoints, gathererHistogramDataPoint(metric))
+			}
+			m.Data = histogram
+		default:
+			continue
+		}
+
+		sm.Metrics = append(sm.Metrics, m)
+	}
+
+	return []metricdata.ScopeMetrics{sm}, nil
+}
+
+// gathererHistogramDataPoint converts a Prometheus histogram, with cumulative buckets, to an OpenTelemetry data point,
+// with the count of every bucket.
+func gathererHistogramDataPoint(metric *dto.Metric) metricdata.HistogramDataPoint[float64] {
+	h := metric.GetHistogram()
+	dp := metricdata.HistogramDataPoint[float64]{
+		Attributes: gathererAttributes(metric.GetLabel()),
+		Count:      h.GetSampleCount(),
+		Sum:        h.GetSampleSum(),
+	}
+
+	var previous uint64
+	for _, bucket := range h.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), +1) {
+			continue
+		}
+
+		dp.Bounds = append(dp.Bounds, bucket.GetUpperBound())
+		dp.BucketCounts = append(dp.BucketCounts, bucket.GetCumulativeCount()-previous)
+		previous = bucket.GetCumulativeCount()
+	}
+	dp.BucketCounts = append(dp.BucketCounts, dp.Count-previous)
+
+	return dp
+}
+
+func gathererAttributes(pairs []*dto.LabelPair) attribute.Set {
+	kvs := make([]attribute.KeyValue, len(pairs))
+	for i, pair := range pairs {
+		kvs[i] = attribute.String(pair.GetName(), pair.GetValue())
+	}
+	return attribute.NewSet(kvs...)
+}
+
+// sanitizePrometheusName returns the Prometheus name of an OpenTelemetry metric, i.e. ``myapp/requests`` is
+// ``myapp_requests``.
+func sanitizePrometheusName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func formatStatsDValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// sanitizeStatsDName replaces the characters of a metric or tag name that are not letters, digits, ``_``, ``-`` or
+// ``.`` by ``_``, the ``/`` separators by ``.``.
+func sanitizeStatsDName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		case r == '/':
+			return '.'
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// sanitizeStatsDTag replaces the characters of a tag value reserved by the StatsD formats by ``_``.
+func sanitizeStatsDTag(value string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(":|,#@;=\n", r) {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
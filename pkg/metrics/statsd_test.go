@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// newTestStatsDAgent listens to UDP packets, the returned function returns the packets received until then.
+func newTestStatsDAgent(t *testing.T) (string, func() []string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn.LocalAddr().String(), func() []string {
+		var packets []string
+		buf := make([]byte, 65536)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return packets
+			}
+			packets = append(packets, string(buf[:n]))
+		}
+	}
+}
+
+func newTestStatsDExporter(t *testing.T, options StatsDOptions) *StatsDExporter {
+	t.Helper()
+
+	options.Interval = time.Hour
+	exporter, err := NewStatsDExporter(options)
+	if err != nil {
+		t.Fatalf("NewStatsDExporter() error = %v", err)
+	}
+	t.Cleanup(func() { _ = exporter.Close() })
+	return exporter
+}
+
+// statsDLines returns the lines of the packets with the specified prefix.
+func statsDLines(packets []string, prefix string) []string {
+	var lines []string
+	for _, packet := range packets {
+		for _, line := range strings.Split(packet, "\n") {
+			if strings.HasPrefix(line, prefix) {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+func TestStatsDExporterFormats(t *testing.T) {
+	for _, tt := range []struct {
+		format StatsDFormat
+		want   []string
+	}{
+		{StatsDFormatDogStatsD, []string{
+			"svc.test.requests:3|c|#env:test,route:/users",
+			"svc.test.inflight:2|g|#env:test",
+			"svc.test.latency.count:1|c|#env:test",
+			"svc.test.latency.bucket:1|c|#env:test,le:1",
+		}},
+		{StatsDFormatStatsD, []string{
+			"svc.test.requests;env=test;route=/users:3|c",
+			"svc.test.inflight;env=test:2|g",
+			"svc.test.latency.count;env=test:1|c",
+			"svc.test.latency.bucket;env=test;le=1:1|c",
+		}},
+	} {
+		t.Run(string(tt.format), func(t *testing.T) {
+			address, received := newTestStatsDAgent(t)
+			exporter := newTestStatsDExporter(t, StatsDOptions{
+				Address: address,
+				Format:  tt.format,
+				Prefix:  "svc.",
+				Tags:    map[string]string{"env": "test"},
+			})
+
+			ctx := context.Background()
+			meter := exporter.Meter("test")
+			requests, _ := meter.Int64Counter("test/requests")
+			inflight, _ := meter.Int64UpDownCounter("test/inflight")
+			latency, _ := meter.Float64Histogram("test/latency", metric.WithExplicitBucketBoundaries(.1, 1))
+
+			requests.Add(ctx, 3, metric.WithAttributes(attribute.String("route", "/users")))
+			inflight.Add(ctx, 2)
+			latency.Record(ctx, .5)
+
+			if err := exporter.ForceFlush(ctx); err != nil {
+				t.Fatalf("ForceFlush() error = %v", err)
+			}
+
+			// the metrics are batched in a single packet
+			packets := received()
+			if got := len(statsDLines(packets, "svc.test.")); len(packets) != 1 || got != 6 {
+				t.Errorf("got %d lines in %d packets, want 6 lines in 1 packet: %q", got, len(packets), packets)
+			}
+			for _, want := range tt.want {
+				if lines := statsDLines(packets, want); len(lines) != 1 || lines[0] != want {
+					t.Errorf("the packets do not hold %q: %q", want, packets)
+				}
+			}
+
+			// the counters are sent by difference, the gauges every time
+			if err := exporter.ForceFlush(ctx); err != nil {
+				t.Fatalf("ForceFlush() error = %v", err)
+			}
+			packets = received()
+			if lines := statsDLines(packets, "svc.test."); len(lines) != 1 || !strings.HasPrefix(lines[0], "svc.test.inflight") {
+				t.Errorf("the second send got %q, want the gauge only", lines)
+			}
+		})
+	}
+}
+
+func TestStatsDExporterPacketSize(t *testing.T) {
+	address, received := newTestStatsDAgent(t)
+	exporter := newTestStatsDExporter(t, StatsDOptions{Address: address, MaxPacketSize: 64})
+
+	ctx := context.Background()
+	requests, _ := exporter.Meter("test").Int64Counter("test/requests")
+	for _, route := range []string{"/a", "/b", "/c", "/d", "/e", "/f"} {
+		requests.Add(ctx, 1, metric.WithAttributes(attribute.String("route", route)))
+	}
+	requests.Add(ctx, 1, metric.WithAttributes(attribute.String("route", strings.Repeat("x", 64))))
+
+	// the metric that does not fit in a packet is dropped
+	if err := exporter.ForceFlush(ctx); err == nil {
+		t.Error("ForceFlush() succeeds with a dropped metric")
+	}
+
+	packets := received()
+	for _, packet := range packets {
+		if len(packet) > 64 {
+			t.Errorf("the packet %q is larger than 64 bytes", packet)
+		}
+	}
+	if lines := statsDLines(packets, "test.requests:1|c"); len(packets) < 2 || len(lines) != 6 {
+		t.Errorf("got %d lines in %d packets, want 6 lines in several packets: %q", len(lines), len(packets), packets)
+	}
+
+	// the dropped packet is counted
+	if err := exporter.ForceFlush(ctx); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+	if lines := statsDLines(received(), "micro-server.metrics_statsd_dropped_packets:1|c|#reason:size"); len(lines) != 1 {
+		t.Errorf("the dropped packet is not counted: %q", lines)
+	}
+}
+
+func TestStatsDExporterGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Buckets: []float64{.1, 1},
+	}, []string{"route"})
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_gathered_requests_total"}, []string{"route"})
+	registry.MustRegister(duration, requests)
+
+	address, received := newTestStatsDAgent(t)
+	exporter := newTestStatsDExporter(t, StatsDOptions{Address: address, Gatherer: registry})
+
+	ctx := context.Background()
+	duration.WithLabelValues("/users").Observe(.5)
+	duration.WithLabelValues("/users").Observe(2)
+	requests.WithLabelValues("/users").Add(3)
+
+	if err := exporter.ForceFlush(ctx); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+
+	packets := received()
+	for _, want := range []string{
+		"http_server_request_duration_seconds.count:2|c|#route:/users",
+		"http_server_request_duration_seconds.sum:2.5|c|#route:/users",
+		"http_server_request_duration_seconds.bucket:1|c|#route:/users,le:1",
+		"http_server_request_duration_seconds.bucket:2|c|#route:/users,le:+Inf",
+		"test_gathered_requests_total:3|c|#route:/users",
+	} {
+		if lines := statsDLines(packets, want); len(lines) != 1 || lines[0] != want {
+			t.Errorf("the packets do not hold %q: %q", want, packets)
+		}
+	}
+
+	// the series missing from an export are evicted, they are sent in full when they reappear
+	duration.WithLabelValues("/users").Observe(.05)
+	requests.DeleteLabelValues("/users")
+	if err := exporter.ForceFlush(ctx); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+	packets = received()
+	if lines := statsDLines(packets, "http_server_request_duration_seconds.count:"); len(lines) != 1 || lines[0] != "http_server_request_duration_seconds.count:1|c|#route:/users" {
+		t.Errorf("the histogram difference is %q, want 1 request", lines)
+	}
+	if lines := statsDLines(packets, "test_gathered_requests_total"); len(lines) != 0 {
+		t.Errorf("the deleted series is sent: %q", lines)
+	}
+
+	requests.WithLabelValues("/users").Add(5)
+	if err := exporter.ForceFlush(ctx); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+	if lines := statsDLines(received(), "test_gathered_requests_total"); len(lines) != 1 || lines[0] != "test_gathered_requests_total:5|c|#route:/users" {
+		t.Errorf("the recreated series is sent as %q, want its full value", lines)
+	}
+}
+
+func TestStatsDExporterGathererDuplicates(t *testing.T) {
+	undeclareTestMetrics(t, "test/statsd_duplicates")
+
+	// the declared metrics are exported by the Prometheus exporter and the StatsD exporter
+	prometheusExporter, err := NewPrometheusExporterWithOptions(PrometheusOptions{DisableDefaultRegistry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prometheusExporter.Close()
+
+	address, received := newTestStatsDAgent(t)
+	exporter := newTestStatsDExporter(t, StatsDOptions{Address: address, Gatherer: prometheusExporter.Registry()})
+
+	counter, err := NewCounter("test/statsd_duplicates", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Inc(context.Background())
+
+	if err := exporter.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush() error = %v", err)
+	}
+
+	packets := received()
+	if lines := statsDLines(packets, "test.statsd_duplicates:1|c"); len(lines) != 1 {
+		t.Errorf("the declared metric is sent %d times, want once: %q", len(lines), packets)
+	}
+	if lines := statsDLines(packets, "test_statsd_duplicates"); len(lines) != 0 {
+		t.Errorf("the declared metric is sent from the gatherer: %q", lines)
+	}
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	ocview "go.opencensus.io/stats/view"
+	"go.opentelemetry.io/otel/bridge/opencensus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	advlogs "github.com/mojun2021/micro-server/pkg/logger/advanced"
+)
+
+// The OpenCensus views are registered globally: the views are counted per exporter owning them, a view is
+// unregistered when the last of its exporters is closed, unless it was registered by the application.
+var (
+	viewsMu       sync.Mutex
+	viewExporters = make(map[string]*viewRef)
+)
+
+// viewRef counts the exporters of a view.
+type viewRef struct {
+	exporters int
+	// registered is true when the view was registered by the exporters, not by the application
+	registered bool
+}
+
+// viewSet holds the OpenCensus views owned by an exporter.
+type viewSet struct {
+	views []*ocview.View
+	names map[string]bool
+
+	once sync.Once
+}
+
+// registerViews registers the specified OpenCensus views along with the log views. None of the views is registered
+// when one of them cannot be, i.e. when a view with the same name but a different definition is already registered.
+func registerViews(views []*ocview.View) (*viewSet, error) {
+	views = append(
+		views,
+		advlogs.LogCountView,
+		advlogs.LogSampledOutCountView,
+		advlogs.LogAsyncQueueDepthView,
+		advlogs.LogAsyncDroppedCountView,
+		//middlewares.ServerRequestBytesView,
+		//middlewares.ServerResponseCountView,
+		//middlewares.ServerResponseBytesView,
+		//middlewares.ServerLatencyView,
+	)
+
+	viewsMu.Lock()
+	defer viewsMu.Unlock()
+
+	set := &viewSet{names: make(map[string]bool, len(views))}
+	for _, v := range views {
+		if v == nil {
+			continue
+		}
+
+		// the view name defaults to its measure name once registered
+		name := v.Name
+		if name == "" && v.Measure != nil {
+			name = v.Measure.Name()
+		}
+
+		ref := viewExporters[name]
+		if ref == nil {
+			ref = &viewRef{registered: ocview.Find(name) == nil}
+		}
+
+		if err := ocview.Register(v); err != nil {
+			set.unregisterLocked()
+			return nil, fmt.Errorf("failed to register the views: %w", err)
+		}
+
+		if !set.names[name] {
+			set.names[name] = true
+			set.views = append(set.views, v)
+			ref.exporters++
+			viewExporters[name] = ref
+		}
+	}
+
+	return set, nil
+}
+
+// unregister releases the views of the set, it can be called several times.
+func (s *viewSet) unregister() {
+	s.once.Do(func() {
+		viewsMu.Lock()
+		defer viewsMu.Unlock()
+
+		s.unregisterLocked()
+	})
+}
+
+func (s *viewSet) unregisterLocked() {
+	for _, v := range s.views {
+		ref := viewExporters[v.Name]
+		if ref == nil {
+			continue
+		}
+
+		ref.exporters--
+		if ref.exporters <= 0 {
+			delete(viewExporters, v.Name)
+			if ref.registered {
+				ocview.Unregister(v)
+			}
+		}
+	}
+	s.views = nil
+}
+
+// producer returns the producer bridging the data of the views of the set to OpenTelemetry, without the data of the
+// views of the other exporters.
+func (s *viewSet) producer() sdkmetric.Producer {
+	return &viewProducer{producer: opencensus.NewMetricProducer(), names: s.names}
+}
+
+// viewProducer filters the metrics of the OpenCensus bridge by view name.
+type viewProducer struct {
+	producer sdkmetric.Producer
+	names    map[string]bool
+}
+
+func (p *viewProducer) Produce(ctx context.Context) ([]metricdata.ScopeMetrics, error) {
+	scopeMetrics, err := p.producer.Produce(ctx)
+
+	filtered := scopeMetrics[:0]
+	for _, sm := range scopeMetrics {
+		metrics := sm.Metrics[:0]
+		for _, m := range sm.Metrics {
+			if p.names[m.Name] {
+				metrics = append(metrics, m)
+			}
+		}
+
+		if len(metrics) > 0 {
+			sm.Metrics = metrics
+			filtered = append(filtered, sm)
+		}
+	}
+
+	return filtered, err
+}
@@ -9,6 +9,9 @@ import (
 
 	"github.com/Microsoft/go-winio"
 	"github.com/gorilla/handlers"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/mojun2021/micro-server/pkg/logger"
 )
 
 // CORSOptions contains all the CORS option used by the exposed APIs.
@@ -18,13 +21,15 @@ var CORSOptions = []handlers.CORSOption{
 	handlers.AllowCredentials(),
 }
 
-// NewServer instantiates a new HTTP server with appropriate defaults.
+// NewServer instantiates a new HTTP server with appropriate defaults. The server errors, i.e. TLS handshake errors
+// and recovered panics, are logged by the ``server.http`` logger.
 func NewServer(handler http.Handler, listener net.Listener) *http.Server {
 	return &http.Server{
 		Addr:         listener.Addr().String(),
 		Handler:      ConfigureHandler(handler),
 		ReadTimeout:  time.Second * 10,
 		WriteTimeout: time.Second * 10,
+		ErrorLog:     logger.NewStdLogger(logger.Log.WithName("server").WithName("http"), zapcore.ErrorLevel),
 	}
 }
 
@@ -0,0 +1,207 @@
+// Package middlewares contains the HTTP middlewares wrapping the server routes.
+package middlewares
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mojun2021/micro-server/pkg/logger"
+)
+
+const (
+	// DefaultDebugHeader defines the default header holding the token of a debugged request.
+	DefaultDebugHeader = "X-Debug"
+	// DebugTokenEnvironmentVariable defines the name of the environment variable holding the accepted debug tokens,
+	// separated by commas.
+	DebugTokenEnvironmentVariable = "USGO_DEBUG_TOKEN"
+	// DebugTraceIDHeader defines the response header holding the trace id of a debugged request.
+	DebugTraceIDHeader = "X-Debug-Trace-Id"
+
+	// debugTracerName defines the instrumentation name of the OpenTelemetry tracer of the debugged requests.
+	debugTracerName = "github.com/mojun2021/micro-server/pkg/server/middlewares"
+)
+
+var log = logger.Log.WithName("middlewares").WithName("debug")
+
+// RequestDebugOptions represents the request debug middleware configuration options.
+type RequestDebugOptions struct {
+	// Header defines the request header holding the debug token. It defaults to ``DefaultDebugHeader``.
+	Header string
+	// Tokens lists the accepted debug tokens. It defaults to the ``USGO_DEBUG_TOKEN`` environment variable. The
+	// requests are never debugged when empty.
+	Tokens []string
+	// Authenticate overrides the token check, i.e. to validate signed tokens.
+	Authenticate func(r *http.Request, token string) bool
+}
+
+func setRequestDebugOptionsDefaults(options *RequestDebugOptions) {
+	if options.Header == "" {
+		options.Header = DefaultDebugHeader
+	}
+
+	if options.Tokens == nil {
+		for _, token := range strings.Split(os.Getenv(DebugTokenEnvironmentVariable), ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				options.Tokens = append(options.Tokens, token)
+			}
+		}
+	}
+
+	if options.Authenticate == nil {
+		tokens := options.Tokens
+		options.Authenticate = func(_ *http.Request, token string) bool {
+			accepted := false
+			for _, t := range tokens {
+				if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+					accepted = true
+				}
+			}
+			return accepted
+		}
+	}
+}
+
+// RequestDebugHandler returns a handler debugging the requests holding an accepted debug token, without changing the
+// logger level or the trace sampling of the other requests. For a debugged request:
+//
+// - the request logger, see ``logger.FromContext``, writes its debug logs, see ``logger.WithDebug``
+//
+// - the request span is always sampled, its trace id is returned in the ``X-Debug-Trace-Id`` header. It is an
+// OpenTelemetry span when the request context holds one, as for the trace context of the logs, see
+// ``logger.WithTraceContext``, and an OpenCensus span otherwise
+//
+// - the response holds a ``Server-Timing`` header with the total duration and the steps timed by the handler, see
+// ``StartServerTiming``
+//
+// The debug header is removed from the request before it is handled.
+func RequestDebugHandler(next http.Handler, options RequestDebugOptions) http.Handler {
+	setRequestDebugOptionsDefaults(&options)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(options.Header)
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r.Header.Del(options.Header)
+
+		if !options.Authenticate(r, token) {
+			log.Info("Ignoring invalid debug token", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, traceID, end := startDebugSpan(r)
+		defer end()
+
+		requestLog := logger.FromContext(ctx, "method", r.Method, "path", r.URL.Path)
+		requestLog = logger.WithTraceContext(ctx, logger.WithDebug(requestLog))
+		ctx = logger.IntoContext(ctx, requestLog)
+
+		timings := &serverTimings{start: time.Now()}
+		ctx = context.WithValue(ctx, serverTimingsKey{}, timings)
+
+		// the Server-Timing header is added to the response of the debugged request
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		sw.beforeHeader = func() { sw.Header().Set("Server-Timing", timings.header()) }
+		sw.Header().Set(DebugTraceIDHeader, traceID)
+
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		if !sw.wroteHeader {
+			sw.WriteHeader(http.StatusOK)
+		}
+
+		requestLog.V(1).Info("Debugged request", "status", sw.status, "duration", time.Since(timings.start))
+	})
+}
+
+// startDebugSpan starts the sampled span of a debugged request, it returns the context holding the span, its trace id
+// and the function ending it.
+func startDebugSpan(r *http.Request) (context.Context, string, func()) {
+	name := fmt.Sprintf("debug %s %s", r.Method, r.URL.Path)
+
+	if parent := trace.SpanContextFromContext(r.Context()); parent.IsValid() {
+		// The OpenTelemetry samplers are set on the tracer provider: the parent is marked as sampled so that the
+		// parent based samplers, the default ones, sample the span.
+		ctx := trace.ContextWithRemoteSpanContext(r.Context(), parent.WithTraceFlags(parent.TraceFlags().WithSampled(true)))
+		ctx, span := otel.Tracer(debugTracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+		return ctx, span.SpanContext().TraceID().String(), func() { span.End() }
+	}
+
+	ctx, span := octrace.StartSpan(
+		r.Context(),
+		name,
+		octrace.WithSampler(octrace.AlwaysSample()),
+		octrace.WithSpanKind(octrace.SpanKindServer),
+	)
+	return ctx, span.SpanContext().TraceID.String(), span.End
+}
+
+type serverTimingsKey struct{}
+
+// serverTimings holds the steps timed while handling a debugged request.
+type serverTimings struct {
+	start time.Time
+
+	mu      sync.Mutex
+	metrics []string
+}
+
+func (t *serverTimings) add(name string, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.metrics = append(t.metrics, serverTimingMetric(name, duration))
+}
+
+// header returns the Server-Timing header value, ending with the total duration.
+func (t *serverTimings) header() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	metrics := append(t.metrics[:len(t.metrics):len(t.metrics)], serverTimingMetric("total", time.Since(t.start)))
+	return strings.Join(metrics, ", ")
+}
+
+// serverTimingMetric formats a Server-Timing metric, the duration is in milliseconds.
+func serverTimingMetric(name string, duration time.Duration) string {
+	name = strings.Map(func(r rune) rune {
+		if r > ' ' && r < 0x7f && !strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return r
+		}
+		return '_'
+	}, name)
+
+	return name + ";dur=" + strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// AddServerTiming adds a step to the ``Server-Timing`` header of the request of the specified context. It does
+// nothing when the request is not debugged, see ``RequestDebugHandler``.
+func AddServerTiming(ctx context.Context, name string, duration time.Duration) {
+	if timings, ok := ctx.Value(serverTimingsKey{}).(*serverTimings); ok {
+		timings.add(name, duration)
+	}
+}
+
+// StartServerTiming starts timing a step of the request of the specified context, call the returned function when
+// the step ends. The steps must end before the response is written to be part of the ``Server-Timing`` header.
+//
+// Example:
+//
+//    defer middlewares.StartServerTiming(ctx, "db")()
+func StartServerTiming(ctx context.Context, name string) func() {
+	start := time.Now()
+	return func() { AddServerTiming(ctx, name, time.Since(start)) }
+}
@@ -0,0 +1,192 @@
+package middlewares
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mojun2021/micro-server/pkg/logger"
+	logs "github.com/mojun2021/micro-server/pkg/logger/advanced"
+)
+
+// withLogger returns a handler serving the requests with the specified request logger, see ``logger.FromContext``.
+func withLogger(next http.Handler, log logr.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(logger.IntoContext(r.Context(), log)))
+	})
+}
+
+func TestRequestDebugHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := logs.NewProductionLoggerWithOptions(&buf, logs.Options{})
+
+	var gotHeader, gotTraceID string
+	var gotSampled bool
+	handler := RequestDebugHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get(DefaultDebugHeader)
+		gotTraceID, gotSampled = "", false
+		if span := octrace.FromContext(r.Context()); span != nil {
+			gotTraceID, gotSampled = span.SpanContext().TraceID.String(), span.SpanContext().IsSampled()
+		}
+
+		logger.FromContext(r.Context()).V(1).Info("Handling the request")
+		AddServerTiming(r.Context(), "db", 5*time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}), RequestDebugOptions{Tokens: []string{"secret"}})
+	handler = withLogger(handler, log)
+
+	for _, tt := range []struct {
+		name  string
+		token string
+		debug bool
+	}{
+		{"not debugged", "", false},
+		{"invalid token", "invalid", false},
+		{"debugged", "secret", true},
+		{"next request", "", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if tt.token != "" {
+				req.Header.Set(DefaultDebugHeader, tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if gotHeader != "" {
+				t.Errorf("the handler got the debug header %q, want it removed", gotHeader)
+			}
+			if rec.Code != http.StatusAccepted {
+				t.Errorf("got the status %d, want %d", rec.Code, http.StatusAccepted)
+			}
+
+			debugLogged := strings.Contains(buf.String(), `"msg":"Handling the request"`)
+			if debugLogged != tt.debug {
+				t.Errorf("the debug log is written: %v, want %v:\n%s", debugLogged, tt.debug, buf.String())
+			}
+
+			traceID := rec.Header().Get(DebugTraceIDHeader)
+			timing := rec.Header().Get("Server-Timing")
+			if !tt.debug {
+				if traceID != "" || timing != "" {
+					t.Errorf("got the debug headers %q and %q, want none", traceID, timing)
+				}
+				return
+			}
+
+			// the span of the debugged request is sampled and logged
+			if !gotSampled || traceID != gotTraceID {
+				t.Errorf("the request span is %q (sampled: %v), want the sampled span %q", gotTraceID, gotSampled, traceID)
+			}
+			if !strings.Contains(buf.String(), `"debug_request":true`) || !strings.Contains(buf.String(), `"trace_id":"`+traceID+`"`) {
+				t.Errorf("the debug log is not marked with the request trace:\n%s", buf.String())
+			}
+
+			// the timings are added before the header is written
+			if !strings.HasPrefix(timing, "db;dur=5.000, total;dur=") {
+				t.Errorf("got the Server-Timing header %q, want the db and total durations", timing)
+			}
+		})
+	}
+}
+
+func TestRequestDebugHandlerOpenTelemetry(t *testing.T) {
+	// the children of the unsampled spans are never sampled by the tracer provider
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.NeverSample())),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var buf bytes.Buffer
+	log := logs.NewProductionLoggerWithOptions(&buf, logs.Options{})
+
+	var got trace.SpanContext
+	handler := RequestDebugHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = trace.SpanContextFromContext(r.Context())
+		logger.FromContext(r.Context()).V(1).Info("Handling the request")
+	}), RequestDebugOptions{Tokens: []string{"secret"}})
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa},
+		Remote:  true,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req = req.WithContext(trace.ContextWithRemoteSpanContext(req.Context(), parent))
+	req.Header.Set(DefaultDebugHeader, "secret")
+	rec := httptest.NewRecorder()
+	withLogger(handler, log).ServeHTTP(rec, req)
+
+	// the debugged request span is a sampled child of the OpenTelemetry span
+	traceID := parent.TraceID().String()
+	if got.TraceID() != parent.TraceID() || got.SpanID() == parent.SpanID() || !got.IsSampled() {
+		t.Errorf("the request span is %v, want a sampled child of %v", got, parent)
+	}
+	if header := rec.Header().Get(DebugTraceIDHeader); header != traceID {
+		t.Errorf("got the trace id header %q, want %q", header, traceID)
+	}
+	if !strings.Contains(buf.String(), `"trace_id":"`+traceID+`"`) {
+		t.Errorf("the debug log is not marked with the request trace:\n%s", buf.String())
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "debug GET /users" || spans[0].SpanContext().TraceID() != parent.TraceID() {
+		t.Errorf("the tracer provider exported %d spans, want the debugged request span", len(spans))
+	}
+}
+
+func TestRequestDebugHandlerAuthenticate(t *testing.T) {
+	handler := RequestDebugHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), RequestDebugOptions{
+		Header: "X-Trace-Me",
+		Authenticate: func(r *http.Request, token string) bool {
+			return token == "signed:"+r.URL.Path
+		},
+	})
+
+	for token, want := range map[string]bool{"signed:/users": true, "signed:/orders": false} {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("X-Trace-Me", token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		// the response of the handler writing nothing holds the debug headers as well
+		if got := rec.Header().Get("Server-Timing") != ""; got != want {
+			t.Errorf("the request with the token %q is debugged: %v, want %v", token, got, want)
+		}
+	}
+}
+
+func TestRequestDebugHandlerMarker(t *testing.T) {
+	// the loggers that do not expose a zap logger get the marker as a string
+	var lines []string
+	log := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{})
+
+	handler := RequestDebugHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Info("Handling the request")
+	}), RequestDebugOptions{Tokens: []string{"secret"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(DefaultDebugHeader, "secret")
+	withLogger(handler, log).ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(lines) == 0 || !strings.Contains(lines[0], `"debug_request"="true"`) {
+		t.Errorf("the logger got %q, want the debug marker", lines)
+	}
+}
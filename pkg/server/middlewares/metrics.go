@@ -0,0 +1,186 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mojun2021/micro-server/pkg/metrics"
+)
+
+const (
+	// RequestDurationMetricName defines the name of the request latency histogram, in seconds. It has 3 labels:
+	// ``method``, ``route`` and ``code``.
+	RequestDurationMetricName = "http_server_request_duration_seconds"
+
+	// TraceIDExemplarKey defines the exemplar label holding the trace id of an observed request.
+	TraceIDExemplarKey = "trace_id"
+	// SpanIDExemplarKey defines the exemplar label holding the span id of an observed request.
+	SpanIDExemplarKey = "span_id"
+
+	// UnmatchedRoute defines the route label of the requests not matching any route.
+	UnmatchedRoute = "unmatched"
+)
+
+// RequestMetricsOptions represents the request metrics middleware configuration options.
+type RequestMetricsOptions struct {
+	// Registerer defines where the request metrics are registered, i.e. the registry of the Prometheus exporter, see
+	// ``metrics.Exporter.Registry``. It is required.
+	Registerer prometheus.Registerer
+	// Buckets defines the upper bounds of the latency histogram buckets, in seconds. It defaults to
+	// ``prometheus.DefBuckets``.
+	Buckets []float64
+	// MaxLabelValues defines the maximum number of values of every label, the values over the limit are folded into
+	// ``metrics.OverflowLabelValue``, see ``metrics.CardinalityGuard``. It defaults to
+	// ``metrics.DefaultMaxLabelValues``.
+	MaxLabelValues int
+}
+
+func setRequestMetricsOptionsDefaults(options *RequestMetricsOptions) {
+	if len(options.Buckets) == 0 {
+		options.Buckets = prometheus.DefBuckets
+	}
+}
+
+// RequestMetrics records the latency of the requests of the routes it handles.
+type RequestMetrics struct {
+	duration *prometheus.HistogramVec
+	guard    *metrics.CardinalityGuard
+}
+
+// NewRequestMetrics creates the request metrics and registers them.
+func NewRequestMetrics(options RequestMetricsOptions) (*RequestMetrics, error) {
+	setRequestMetricsOptionsDefaults(&options)
+
+	if options.Registerer == nil {
+		return nil, errors.New("missing request metrics registerer")
+	}
+
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    RequestDurationMetricName,
+		Help:    "The duration of the HTTP requests, in seconds.",
+		Buckets: options.Buckets,
+	}, []string{"method", "route", "code"})
+
+	guard, err := metrics.NewCardinalityGuard(RequestDurationMetricName, []string{"method", "route", "code"},
+		metrics.CardinalityGuardOptions{MaxValues: options.MaxLabelValues, Registerer: options.Registerer})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := options.Registerer.Register(duration); err != nil {
+		return nil, err
+	}
+
+	return &RequestMetrics{duration: duration, guard: guard}, nil
+}
+
+// Handler returns a handler recording the latency of the requests of the specified route, i.e. its path template.
+// The route is ``UnmatchedRoute`` when empty, i.e. for the not found handler of the router.
+//
+// The latency of a request with a sampled span, OpenTelemetry or OpenCensus, is observed with an exemplar holding its
+// trace and span ids. The exemplars are served when the scraper negotiates the OpenMetrics format, see
+// ``metrics.Exporter``.
+func (m *RequestMetrics) Handler(next http.Handler, route string) http.Handler {
+	if route == "" {
+		route = UnmatchedRoute
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		duration := time.Since(start).Seconds()
+		observer := m.duration.WithLabelValues(m.guard.Values(r.Method, route, strconv.Itoa(sw.status))...)
+
+		if exemplar := exemplarLabels(r); exemplar != nil {
+			if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok {
+				exemplarObserver.ObserveWithExemplar(duration, exemplar)
+				return
+			}
+		}
+
+		observer.Observe(duration)
+	})
+}
+
+// HandleUnmatched records the latency of the requests not matching any route of the specified router, with the
+// ``UnmatchedRoute`` route: the requests handled by its not found and method not allowed handlers.
+func (m *RequestMetrics) HandleUnmatched(router *mux.Router) {
+	notFound := router.NotFoundHandler
+	if notFound == nil {
+		notFound = http.NotFoundHandler()
+	}
+	router.NotFoundHandler = m.Handler(notFound, "")
+
+	methodNotAllowed := router.MethodNotAllowedHandler
+	if methodNotAllowed == nil {
+		methodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		})
+	}
+	router.MethodNotAllowedHandler = m.Handler(methodNotAllowed, "")
+}
+
+// exemplarLabels returns the exemplar labels of the sampled span of the specified request, the OpenTelemetry span or
+// else the OpenCensus span. It returns nil when the request has no sampled span.
+func exemplarLabels(r *http.Request) prometheus.Labels {
+	if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.IsValid() {
+		if !spanContext.IsSampled() {
+			return nil
+		}
+		return prometheus.Labels{
+			TraceIDExemplarKey: spanContext.TraceID().String(),
+			SpanIDExemplarKey:  spanContext.SpanID().String(),
+		}
+	}
+
+	if span := octrace.FromContext(r.Context()); span != nil && span.SpanContext().IsSampled() {
+		spanContext := span.SpanContext()
+		return prometheus.Labels{
+			TraceIDExemplarKey: spanContext.TraceID.String(),
+			SpanIDExemplarKey:  spanContext.SpanID.String(),
+		}
+	}
+	return nil
+}
+
+// statusWriter records the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	// beforeHeader is called once before the header is written, i.e. to complete it
+	beforeHeader func()
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+		if w.beforeHeader != nil {
+			w.beforeHeader()
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the wrapped response writer, see ``http.ResponseController``.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
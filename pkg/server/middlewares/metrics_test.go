@@ -0,0 +1,166 @@
+package middlewares
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mojun2021/micro-server/pkg/metrics"
+)
+
+// newTestRequestMetrics returns a router whose routes are wrapped with the request metrics, as by the server, and
+// the exporter of the metrics.
+func newTestRequestMetrics(t *testing.T) (*mux.Router, *metrics.Exporter) {
+	t.Helper()
+
+	exporter, err := metrics.NewPrometheusExporterWithOptions(metrics.PrometheusOptions{DisableDefaultRegistry: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = exporter.Close() })
+
+	requestMetrics, err := NewRequestMetrics(RequestMetricsOptions{Registerer: exporter.Registry()})
+	if err != nil {
+		t.Fatalf("NewRequestMetrics() error = %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, _ := route.GetPathTemplate()
+		route.Handler(requestMetrics.Handler(route.GetHandler(), tpl))
+		return nil
+	})
+	requestMetrics.HandleUnmatched(router)
+
+	return router, exporter
+}
+
+// scrape returns the metrics served by the exporter in the negotiated format.
+func scrape(t *testing.T, exporter *metrics.Exporter, accept string) string {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	exporter.ServeHTTP(rec, req)
+
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+// servedExemplars returns the label pairs, i.e. ``trace_id="..."``, of the exemplars served in the OpenMetrics format.
+func servedExemplars(body string) []map[string]bool {
+	var sets []map[string]bool
+	for _, line := range strings.Split(body, "\n") {
+		start := strings.Index(line, " # {")
+		if start < 0 {
+			continue
+		}
+		end := strings.Index(line[start:], "}")
+		if end < 0 {
+			continue
+		}
+
+		set := map[string]bool{}
+		for _, pair := range strings.Split(line[start+len(" # {"):start+end], ",") {
+			set[pair] = true
+		}
+		sets = append(sets, set)
+	}
+	return sets
+}
+
+func TestRequestMetricsRoutes(t *testing.T) {
+	router, exporter := newTestRequestMetrics(t)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/users/1", nil),
+		httptest.NewRequest(http.MethodGet, "/users/2", nil),
+		httptest.NewRequest(http.MethodGet, "/missing/1", nil),
+		httptest.NewRequest(http.MethodDelete, "/users/1", nil),
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// the requests are labelled with the route template, the unmatched requests are folded into a single route
+	body := scrape(t, exporter, "")
+	for _, want := range []string{
+		`http_server_request_duration_seconds_count{code="200",method="GET",route="/users/{id}"} 2`,
+		`http_server_request_duration_seconds_count{code="404",method="GET",route="unmatched"} 1`,
+		`http_server_request_duration_seconds_count{code="405",method="DELETE",route="unmatched"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("the exporter does not serve %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "/missing") {
+		t.Errorf("the exporter serves the path of an unmatched request:\n%s", body)
+	}
+}
+
+func TestRequestMetricsExemplars(t *testing.T) {
+	otelContext := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	ocContext, span := octrace.StartSpan(context.Background(), "test", octrace.WithSampler(octrace.AlwaysSample()))
+	defer span.End()
+
+	for name, tt := range map[string]struct {
+		ctx  context.Context
+		want []string
+	}{
+		"opentelemetry": {otelContext, []string{`trace_id="4bf92f35000000000000000000000000"`, `span_id="00f067aa00000000"`}},
+		"opencensus": {ocContext, []string{
+			`trace_id="` + span.SpanContext().TraceID.String() + `"`,
+			`span_id="` + span.SpanContext().SpanID.String() + `"`,
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			router, exporter := newTestRequestMetrics(t)
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil).WithContext(tt.ctx))
+
+			// the exemplars are only served in the OpenMetrics format
+			if body := scrape(t, exporter, ""); strings.Contains(body, tt.want[0]) {
+				t.Errorf("the exporter serves the exemplar in the Prometheus format:\n%s", body)
+			}
+
+			// the order of the exemplar labels is not defined
+			body := scrape(t, exporter, "application/openmetrics-text; version=1.0.0")
+			sets := servedExemplars(body)
+			if len(sets) == 0 {
+				t.Fatalf("the exporter does not serve exemplars in the OpenMetrics format:\n%s", body)
+			}
+			for _, want := range tt.want {
+				if !sets[0][want] {
+					t.Errorf("the exemplar labels are %v, want %s", sets[0], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestMetricsUnsampled(t *testing.T) {
+	unsampled := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa},
+	}))
+
+	router, exporter := newTestRequestMetrics(t)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil).WithContext(unsampled))
+
+	if body := scrape(t, exporter, "application/openmetrics-text; version=1.0.0"); strings.Contains(body, "trace_id") {
+		t.Errorf("the exporter serves the exemplar of an unsampled span:\n%s", body)
+	}
+}
@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mojun2021/micro-server/pkg/metrics"
+)
+
+// SLOHandler returns a handler counting the requests of the specified route, i.e. its path template, in the SLOs of
+// the route tracked by the tracker, see ``metrics.SLOTracker``.
+func SLOHandler(next http.Handler, route string, tracker *metrics.SLOTracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		tracker.Observe(r.Method, route, sw.status, time.Since(start))
+	})
+}
@@ -2,6 +2,9 @@ package server
 
 import (
 	"time"
+
+	"github.com/mojun2021/micro-server/pkg/metrics"
+	"github.com/mojun2021/micro-server/pkg/server/middlewares"
 )
 
 var (
@@ -18,6 +21,16 @@ type Options struct {
 	GracefulTimeout time.Duration
 	// EnableReplication enables header replication support on the server responses.
 	EnableReplication bool
+	// RequestDebug enables the debugging of the requests holding an accepted debug token, see
+	// ``middlewares.RequestDebugHandler``. The requests are never debugged when nil.
+	RequestDebug *middlewares.RequestDebugOptions
+	// RequestMetrics enables the request latency histogram of the routes, see ``middlewares.RequestMetrics``. Its
+	// registerer is required, i.e. the registry of the exporter of the server. The request metrics are not recorded
+	// when nil.
+	RequestMetrics *middlewares.RequestMetricsOptions
+	// SLOTracker counts the requests of the routes in their SLOs, see ``metrics.SLOTracker``. The SLOs are not tracked
+	// when nil.
+	SLOTracker *metrics.SLOTracker
 }
 
 func setOptionsDefaults(options *Options) {
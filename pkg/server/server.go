@@ -9,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	ocprometheus "contrib.go.opencensus.io/exporter/prometheus"
 	"github.com/Microsoft/go-winio"
 	"github.com/go-logr/logr"
 	"github.com/gorilla/mux"
@@ -18,14 +17,16 @@ import (
 	"github.com/mojun2021/micro-server/pkg/helpers/production"
 	"github.com/mojun2021/micro-server/pkg/helpers/routes"
 	"github.com/mojun2021/micro-server/pkg/logger"
+	"github.com/mojun2021/micro-server/pkg/metrics"
 	advserver "github.com/mojun2021/micro-server/pkg/server/advanced/server"
+	"github.com/mojun2021/micro-server/pkg/server/middlewares"
 )
 
 // Server is the HTTP server interface.
 type Server interface {
 	// Run launches the http server.
 	Run(ctx context.Context) error
-	// Shutdown closes all server used resources.
+	// Shutdown closes all server used resources, and writes the logs buffered by the asynchronous log sinks.
 	Shutdown() error
 	// Router gives you the server's router. This allows you to add your specific route.
 	Router() *mux.Router
@@ -47,6 +48,9 @@ type httpServer struct {
 	serverURL       *url.URL
 	logger          logr.Logger
 	runningServer   *http.Server
+	requestDebug    *middlewares.RequestDebugOptions
+	requestMetrics  *middlewares.RequestMetrics
+	sloTracker      *metrics.SLOTracker
 	// telemetryOptions  *middlewares.TelemetryOptions
 	// headerReplication *middlewares.ServerOptions
 }
@@ -60,6 +64,14 @@ func NewBaseServer(endpoint string, options Options) (Server, error) {
 		return nil, err
 	}
 
+	var requestMetrics *middlewares.RequestMetrics
+	if options.RequestMetrics != nil {
+		if requestMetrics, err = middlewares.NewRequestMetrics(*options.RequestMetrics); err != nil {
+			_ = listener.Close()
+			return nil, err
+		}
+	}
+
 	serverURL := url.URL{
 		Scheme: "http",
 		Host:   production.EndpointToHostname(listener.Addr().String(), production.InProduction()),
@@ -88,6 +100,9 @@ func NewBaseServer(endpoint string, options Options) (Server, error) {
 		serverURL:       &serverURL,
 		logger:          newLog,
 		runningServer:   nil,
+		requestDebug:    options.RequestDebug,
+		requestMetrics:  requestMetrics,
+		sloTracker:      options.SLOTracker,
 		//telemetryOptions:  middlewares.NewTelemetryOptions(enableTracing),
 		//headerReplication: middlewares.NewServerOptions(options.EnableReplication),
 	}
@@ -126,7 +141,7 @@ func NewMonitoringServer(
 	options Options,
 	liveness http.Handler,
 	readiness http.Handler,
-	prometheusExporter *ocprometheus.Exporter,
+	prometheusExporter http.Handler,
 ) (Server, error) {
 	server, err := NewBaseServer(endpoint, options)
 	if err != nil {
@@ -219,6 +234,15 @@ func (s *httpServer) Run(ctx context.Context) error {
 			// Append middlewares to handler
 			//handler = middlewares.TelemetryHandler(handler, t, s.telemetryOptions)
 			//handler = middlewares.HeaderReplicatorHandler(handler, s.headerReplication)
+			if s.requestMetrics != nil {
+				handler = s.requestMetrics.Handler(handler, t)
+			}
+			if s.sloTracker != nil {
+				handler = middlewares.SLOHandler(handler, t, s.sloTracker)
+			}
+			if s.requestDebug != nil {
+				handler = middlewares.RequestDebugHandler(handler, *s.requestDebug)
+			}
 
 			route.Handler(handler)
 		}
@@ -226,6 +250,11 @@ func (s *httpServer) Run(ctx context.Context) error {
 		return nil
 	})
 
+	// The requests not matching any route are recorded with the unmatched route.
+	if s.requestMetrics != nil {
+		s.requestMetrics.HandleUnmatched(s.router)
+	}
+
 	if err := advserver.RunServer(ctx, s.runningServer, s.listener, s.gracefulTimeout); err != nil {
 		s.logger.Error(err, "Failed to run the HTTP server")
 		return err
@@ -236,6 +265,9 @@ func (s *httpServer) Run(ctx context.Context) error {
 
 // Shutdown closes all server used resources.
 func (s *httpServer) Shutdown() error {
+	// The logs buffered by the asynchronous sinks are written once the server is stopped.
+	defer func() { _ = logger.Flush() }()
+
 	// If the server is currently running ... shut it down
 	if s.runningServer != nil {
 		err := s.runningServer.Shutdown(context.Background())